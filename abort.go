@@ -0,0 +1,86 @@
+package jpake
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AbortReason says why a peer gave up on an exchange. It is deliberately
+// coarse: a rejected message is reported without saying which check failed.
+type AbortReason byte
+
+const (
+	AbortUnspecified AbortReason = iota
+	AbortRejectedMessage
+	AbortMalformedMessage
+	AbortUnexpectedMessage
+	AbortSessionConfirmation
+)
+
+func (r AbortReason) String() string {
+	switch r {
+	case AbortUnspecified:
+		return "unspecified"
+	case AbortRejectedMessage:
+		return "rejected message"
+	case AbortMalformedMessage:
+		return "malformed message"
+	case AbortUnexpectedMessage:
+		return "unexpected message"
+	case AbortSessionConfirmation:
+		return "session confirmation failed"
+	}
+	return fmt.Sprintf("AbortReason(%d)", int(r))
+}
+
+// ErrPeerAborted is matched by the error returned when the peer sends an
+// AbortMessage.
+var ErrPeerAborted = errors.New("peer aborted the exchange")
+
+// PeerAbortedError carries the reason given by a peer which aborted.
+type PeerAbortedError struct {
+	Reason AbortReason
+}
+
+func (e *PeerAbortedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrPeerAborted, e.Reason)
+}
+
+func (e *PeerAbortedError) Unwrap() error {
+	return ErrPeerAborted
+}
+
+// AbortMessage tells the peer that the exchange has failed on this side, so
+// that it need not wait for a message which will never arrive.
+type AbortMessage struct {
+	Reason AbortReason
+}
+
+// NewAbortMessage returns the abort message reporting err.
+func NewAbortMessage(err error) *AbortMessage {
+	var rejectedErr *rejectedMessageError
+	reason := AbortUnspecified
+	switch {
+	case errors.As(err, &rejectedErr):
+		reason = AbortRejectedMessage
+	case errors.Is(err, ErrMalformedMessage):
+		reason = AbortMalformedMessage
+	case errors.Is(err, ErrUnexpectedMessage), errors.Is(err, ErrVariantMismatch), errors.Is(err, ErrUnsupportedVersion), errors.Is(err, ErrReplayedMessage):
+		reason = AbortUnexpectedMessage
+	case errors.Is(err, ErrSessionConfirmation):
+		reason = AbortSessionConfirmation
+	}
+	return &AbortMessage{Reason: reason}
+}
+
+// Frame returns the message as a FrameAbort frame.
+func (m *AbortMessage) Frame() *Frame {
+	return &Frame{Type: FrameAbort, Body: []byte{byte(m.Reason)}}
+}
+
+func DecodeAbortMessage(b []byte) (*AbortMessage, error) {
+	if len(b) != 1 {
+		return nil, fmt.Errorf("%w: abort message of %d bytes", ErrMalformedMessage, len(b))
+	}
+	return &AbortMessage{Reason: AbortReason(b[0])}, nil
+}
@@ -0,0 +1,288 @@
+package jpake
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Protocol messages are represented in CBOR (RFC 8949) as a definite length
+// array of their fields in declaration order. Byte fields, including points
+// and scalars, are byte strings, a Version is an unsigned integer and a
+// ZKPMsg is a nested array of T and R.
+// Decoding accepts only the shortest form of each header, so every message
+// has a single encoding, and points and scalars are validated as by
+// PointFromBytes and ScalarFromBytes.
+//
+// The MarshalCBOR and UnmarshalCBOR methods match the Marshaler and
+// Unmarshaler interfaces of github.com/fxamacker/cbor, so the messages can be
+// embedded in larger structures encoded with that package.
+
+const (
+	cborMajorUnsigned byte = 0
+	cborMajorBytes    byte = 2
+	cborMajorArray    byte = 4
+)
+
+func cborAppendHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(b, m|byte(n))
+	case n <= 0xff:
+		return append(b, m|24, byte(n))
+	case n <= 0xffff:
+		return append(b, m|25, byte(n>>8), byte(n))
+	case n <= 0xffffffff:
+		return append(b, m|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, m|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func cborAppendBytes(b, v []byte) []byte {
+	return append(cborAppendHead(b, cborMajorBytes, uint64(len(v))), v...)
+}
+
+func (c Codec[P, S]) cborAppendZKP(b []byte, zkp ZKPMsg[P, S]) []byte {
+	b = cborAppendHead(b, cborMajorArray, 2)
+	b = cborAppendBytes(b, zkp.T.Bytes())
+	return cborAppendBytes(b, zkp.R.Bytes())
+}
+
+type cborReader struct {
+	b []byte
+}
+
+func (r *cborReader) head(major byte, name string) (uint64, error) {
+	if len(r.b) == 0 {
+		return 0, fmt.Errorf("truncated message reading %s", name)
+	}
+	if r.b[0]>>5 != major {
+		return 0, fmt.Errorf("invalid %s: unexpected CBOR major type %d", name, r.b[0]>>5)
+	}
+	info := r.b[0] & 0x1f
+	r.b = r.b[1:]
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, fmt.Errorf("invalid %s: unsupported CBOR length encoding", name)
+	}
+	size := 1 << (info - 24)
+	if len(r.b) < size {
+		return 0, fmt.Errorf("truncated message reading %s", name)
+	}
+	var n uint64
+	for _, v := range r.b[:size] {
+		n = n<<8 | uint64(v)
+	}
+	r.b = r.b[size:]
+	// reject headers that a shorter form could have encoded
+	if len(cborAppendHead(nil, major, n)) != size+1 {
+		return 0, fmt.Errorf("invalid %s: non-canonical CBOR length", name)
+	}
+	return n, nil
+}
+
+func (r *cborReader) array(n uint64, name string) error {
+	l, err := r.head(cborMajorArray, name)
+	if err != nil {
+		return err
+	}
+	if l != n {
+		return fmt.Errorf("invalid %s: expected %d fields, got %d", name, n, l)
+	}
+	return nil
+}
+
+func (r *cborReader) bytes(name string) ([]byte, error) {
+	l, err := r.head(cborMajorBytes, name)
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(r.b)) {
+		return nil, fmt.Errorf("truncated message reading %s", name)
+	}
+	v := r.b[:l]
+	r.b = r.b[l:]
+	return v, nil
+}
+
+func (r *cborReader) uint8(name string) (uint8, error) {
+	v, err := r.head(cborMajorUnsigned, name)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0xff {
+		return 0, fmt.Errorf("invalid %s: %d out of range", name, v)
+	}
+	return uint8(v), nil
+}
+
+func (r *cborReader) done() error {
+	if len(r.b) != 0 {
+		return errors.New("trailing bytes in message")
+	}
+	return nil
+}
+
+func cborPoint[P CurvePoint[P, S], S CurveScalar[S]](r *cborReader, name string) (P, error) {
+	b, err := r.bytes(name)
+	if err != nil {
+		return *new(P), err
+	}
+	return Codec[P, S]{}.decodePoint(name, b)
+}
+
+func cborZKP[P CurvePoint[P, S], S CurveScalar[S]](r *cborReader, name string) (ZKPMsg[P, S], error) {
+	if err := r.array(2, name); err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	t, err := cborPoint[P, S](r, name+".T")
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	b, err := r.bytes(name + ".R")
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	s, err := Codec[P, S]{}.decodeScalar(name+".R", b)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	return ZKPMsg[P, S]{T: t, R: s}, nil
+}
+
+func (zkp ZKPMsg[P, S]) MarshalCBOR() ([]byte, error) {
+	return Codec[P, S]{}.cborAppendZKP(nil, zkp), nil
+}
+
+func (zkp *ZKPMsg[P, S]) UnmarshalCBOR(b []byte) error {
+	r := &cborReader{b}
+	decoded, err := cborZKP[P, S](r, "ZKP")
+	if err != nil {
+		return err
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	*zkp = decoded
+	return nil
+}
+
+func (msg ThreePassVariant1[P, S]) MarshalCBOR() ([]byte, error) {
+	c := Codec[P, S]{}
+	b := cborAppendHead(nil, cborMajorArray, 6)
+	b = cborAppendHead(b, cborMajorUnsigned, uint64(msg.Version))
+	b = cborAppendBytes(b, msg.UserID)
+	b = cborAppendBytes(b, msg.X1G.Bytes())
+	b = cborAppendBytes(b, msg.X2G.Bytes())
+	b = c.cborAppendZKP(b, msg.X1ZKP)
+	return c.cborAppendZKP(b, msg.X2ZKP), nil
+}
+
+func (msg *ThreePassVariant1[P, S]) UnmarshalCBOR(b []byte) error {
+	r := &cborReader{b}
+	if err := r.array(6, "pass 1"); err != nil {
+		return err
+	}
+	var decoded ThreePassVariant1[P, S]
+	var err error
+	if decoded.Version, err = r.uint8("Version"); err != nil {
+		return err
+	}
+	if decoded.UserID, err = r.bytes("UserID"); err != nil {
+		return err
+	}
+	decoded.UserID = append([]byte{}, decoded.UserID...)
+	if decoded.X1G, err = cborPoint[P, S](r, "X1G"); err != nil {
+		return err
+	}
+	if decoded.X2G, err = cborPoint[P, S](r, "X2G"); err != nil {
+		return err
+	}
+	if decoded.X1ZKP, err = cborZKP[P, S](r, "X1ZKP"); err != nil {
+		return err
+	}
+	if decoded.X2ZKP, err = cborZKP[P, S](r, "X2ZKP"); err != nil {
+		return err
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
+
+func (msg ThreePassVariant2[P, S]) MarshalCBOR() ([]byte, error) {
+	c := Codec[P, S]{}
+	b := cborAppendHead(nil, cborMajorArray, 7)
+	b = cborAppendBytes(b, msg.UserID)
+	b = cborAppendBytes(b, msg.X3G.Bytes())
+	b = cborAppendBytes(b, msg.X4G.Bytes())
+	b = cborAppendBytes(b, msg.B.Bytes())
+	b = c.cborAppendZKP(b, msg.XsZKP)
+	b = c.cborAppendZKP(b, msg.X3ZKP)
+	return c.cborAppendZKP(b, msg.X4ZKP), nil
+}
+
+func (msg *ThreePassVariant2[P, S]) UnmarshalCBOR(b []byte) error {
+	r := &cborReader{b}
+	if err := r.array(7, "pass 2"); err != nil {
+		return err
+	}
+	var decoded ThreePassVariant2[P, S]
+	var err error
+	if decoded.UserID, err = r.bytes("UserID"); err != nil {
+		return err
+	}
+	decoded.UserID = append([]byte{}, decoded.UserID...)
+	if decoded.X3G, err = cborPoint[P, S](r, "X3G"); err != nil {
+		return err
+	}
+	if decoded.X4G, err = cborPoint[P, S](r, "X4G"); err != nil {
+		return err
+	}
+	if decoded.B, err = cborPoint[P, S](r, "B"); err != nil {
+		return err
+	}
+	if decoded.XsZKP, err = cborZKP[P, S](r, "XsZKP"); err != nil {
+		return err
+	}
+	if decoded.X3ZKP, err = cborZKP[P, S](r, "X3ZKP"); err != nil {
+		return err
+	}
+	if decoded.X4ZKP, err = cborZKP[P, S](r, "X4ZKP"); err != nil {
+		return err
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
+
+func (msg ThreePassVariant3[P, S]) MarshalCBOR() ([]byte, error) {
+	b := cborAppendHead(nil, cborMajorArray, 2)
+	b = cborAppendBytes(b, msg.A.Bytes())
+	return Codec[P, S]{}.cborAppendZKP(b, msg.XsZKP), nil
+}
+
+func (msg *ThreePassVariant3[P, S]) UnmarshalCBOR(b []byte) error {
+	r := &cborReader{b}
+	if err := r.array(2, "pass 3"); err != nil {
+		return err
+	}
+	var decoded ThreePassVariant3[P, S]
+	var err error
+	if decoded.A, err = cborPoint[P, S](r, "A"); err != nil {
+		return err
+	}
+	if decoded.XsZKP, err = cborZKP[P, S](r, "XsZKP"); err != nil {
+		return err
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
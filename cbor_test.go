@@ -0,0 +1,125 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJpake3PassCBOR(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	b1, err := msg1.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("error marshalling pass1: %v", err)
+	}
+	var decoded1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded1.UnmarshalCBOR(b1); err != nil {
+		t.Fatalf("error unmarshalling pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(decoded1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	b2, err := msg2.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("error marshalling pass2: %v", err)
+	}
+	var decoded2 ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded2.UnmarshalCBOR(b2); err != nil {
+		t.Fatalf("error unmarshalling pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(decoded2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	b3, err := msg3.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("error marshalling pass3: %v", err)
+	}
+	var decoded3 ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded3.UnmarshalCBOR(b3); err != nil {
+		t.Fatalf("error unmarshalling pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(decoded3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if len(jpake1.SessionKey) == 0 || !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+
+	for _, msg := range []interface {
+		json.Marshaler
+		MarshalCBOR() ([]byte, error)
+	}{msg1, msg2, msg3} {
+		j, err := msg.MarshalJSON()
+		if err != nil {
+			t.Fatalf("error marshalling json: %v", err)
+		}
+		c, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("error marshalling cbor: %v", err)
+		}
+		t.Logf("%T: cbor %d bytes, json %d bytes", msg, len(c), len(j))
+		if len(c) >= len(j) {
+			t.Fatalf("expected cbor encoding (%d bytes) to be smaller than json (%d bytes)", len(c), len(j))
+		}
+	}
+}
+
+func TestJpake3PassCBORInvalid(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	b, err := msg1.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("error marshalling pass1: %v", err)
+	}
+	// array(6), uint(1) version, bstr(3) "one", bstr(32) X1G
+	if !bytes.Equal(b[:7], []byte{0x86, ProtocolVersion, 0x43, 'o', 'n', 'e', 0x58}) {
+		t.Fatalf("unexpected encoding prefix %x", b[:7])
+	}
+	var decoded ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded.UnmarshalCBOR(append(b, 0)); err == nil {
+		t.Fatalf("expected error with trailing bytes, instead got nil")
+	}
+	if err := decoded.UnmarshalCBOR(b[:len(b)-1]); err == nil {
+		t.Fatalf("expected error with truncated message, instead got nil")
+	}
+	// the user ID length in a one byte argument when it fits in the header
+	nonCanonical := append([]byte{0x86, ProtocolVersion, 0x58, 3}, b[3:]...)
+	if err := decoded.UnmarshalCBOR(nonCanonical); err == nil {
+		t.Fatalf("expected error with non-canonical length, instead got nil")
+	}
+	// X1G set to a y coordinate which is not on the curve
+	invalidPoint := append([]byte{}, b...)
+	notOnCurve := make([]byte, 32)
+	notOnCurve[0] = 2
+	copy(invalidPoint[8:40], notOnCurve)
+	if err := decoded.UnmarshalCBOR(invalidPoint); err == nil {
+		t.Fatalf("expected error with invalid point, instead got nil")
+	}
+}
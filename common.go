@@ -1,18 +1,111 @@
 package jpake
 
+import (
+	crypto_rand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Variant identifies the message flow a J-PAKE instance runs. It is sent as
+// the leading byte of the first message so that peers running different
+// variants are detected before any message is processed.
+type Variant byte
+
+const (
+	VariantTwoPass   Variant = 2
+	VariantThreePass Variant = 3
+)
+
+var ErrVariantMismatch = errors.New("peer is running a different protocol variant")
+
+// ProtocolVersion is the version of the message formats and flow, sent in
+// the first message of either variant. It changes only with a change that
+// would make peers of different versions fail, so that a mismatch is
+// reported as ErrUnsupportedVersion rather than as a failed proof or a
+// garbled message.
+const ProtocolVersion uint8 = 1
+
+var ErrUnsupportedVersion = errors.New("peer is running an unsupported protocol version")
+
+func checkVersion(v uint8) error {
+	if v != ProtocolVersion {
+		return fmt.Errorf("%w: received version %d, expected %d", ErrUnsupportedVersion, v, ProtocolVersion)
+	}
+	return nil
+}
+
 type HashFnType func(in []byte) []byte
 type MacFnType func(key, msg []byte) []byte
+
+// ZKPVerificationObserverFnType receives the non-secret parts of every
+// received ZKP as it is checked: the commitment T, the derived challenge c
+// (nil if verification failed before it was derived) and the result.
+type ZKPVerificationObserverFnType func(fieldName string, t, c []byte, ok bool)
+
+// Observer receives the progress of an exchange, for tracing handshakes that
+// fail in the field. It is never given secret values: keyID is a hash of the
+// session key under the config's hash function, equal on both sides when
+// the keys match.
+type Observer interface {
+	OnStageTransition(from, to Stage)
+	OnZKPVerify(name string, ok bool)
+	OnSessionKeyDerived(keyID []byte)
+}
+
+// ZKPFailureObserver may be implemented by an Observer to learn why a
+// received proof failed, as an error matching one of the ErrZKP reasons.
+// OnZKPFailure is called after OnZKPVerify.
+type ZKPFailureObserver interface {
+	OnZKPFailure(name string, err error)
+}
+
+// ZKPChallengeFnType serializes the inputs of a ZKP challenge, which is then
+// hashed with the config's hash function. userID is the prover's.
+type ZKPChallengeFnType func(generator, t, y, userID []byte) ([]byte, error)
+
+// ConfirmationFnType computes a key confirmation message from k, the
+// encoding of the shared point K. first is true for the confirmation the
+// responder sends after pass 3 and false for the initiator's reply.
+type ConfirmationFnType func(k []byte, first bool) []byte
+
+// PasswordStretchFnType is a slow key derivation function such as scrypt or
+// argon2id, applied to the password before the secret scalar is derived.
+type PasswordStretchFnType func(pw, salt []byte) []byte
 type ZKPMsg[P CurvePoint[P, S], S CurveScalar[S]] struct {
 	T P
 	R S
 }
 
 type Config struct {
-	sessionConfirmationBytes []byte
-	secretGenerationBytes    []byte
-	sessionGenerationBytes   []byte
-	hashFn                   HashFnType
-	macFn                    MacFnType
+	sessionConfirmationBytes    []byte
+	secretGenerationBytes       []byte
+	sessionGenerationBytes      []byte
+	hashFn                      HashFnType
+	macFn                       MacFnType
+	zkpVerificationObserver     ZKPVerificationObserverFnType
+	requireKeyConfirmation      bool
+	kmacSessionKey              bool
+	bindConfirmationKeyToPoints bool
+	autoZeroizeEphemerals       bool
+	kmacCustomization           []byte
+	rand                        io.Reader
+	passwordStretch             PasswordStretchFnType
+	passwordSalt                []byte
+	sessionID                   []byte
+	associatedData              []byte
+	confirmationContext         []byte
+	pointEncoding               *PointEncoding
+	sessionKeyLength            int
+	observer                    Observer
+	maxUserIDLength             int
+	zkpChallengeFn              ZKPChallengeFnType
+	confirmationFn              ConfirmationFnType
+	salt                        []byte
+	blindUserIDs                bool
+	allowEmptyPassword          bool
+	blindingSalt                []byte
 }
 
 func NewConfig() *Config {
@@ -22,7 +115,36 @@ func NewConfig() *Config {
 		sessionGenerationBytes:   []byte("SESSION"),
 		hashFn:                   sha256HashFn,
 		macFn:                    hmacsha256KDF,
+		rand:                     crypto_rand.Reader,
+		maxUserIDLength:          256,
+	}
+}
+
+// Validate reports a config which cannot run an exchange: a nil hash, mac
+// or randomness source, an empty label or an incomplete PointEncoding. The
+// Init and Restore functions call it, so that misconfiguration is an error
+// rather than a panic or a weak derivation later.
+func (c *Config) Validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: nil config", ErrInvalidConfig)
+	}
+	switch {
+	case c.hashFn == nil:
+		return fmt.Errorf("%w: hash function is nil", ErrInvalidConfig)
+	case c.macFn == nil:
+		return fmt.Errorf("%w: mac function is nil", ErrInvalidConfig)
+	case c.rand == nil:
+		return fmt.Errorf("%w: randomness source is nil", ErrInvalidConfig)
+	case len(c.sessionConfirmationBytes) == 0:
+		return fmt.Errorf("%w: session confirmation label is empty", ErrInvalidConfig)
+	case len(c.secretGenerationBytes) == 0:
+		return fmt.Errorf("%w: secret generation label is empty", ErrInvalidConfig)
+	case len(c.sessionGenerationBytes) == 0:
+		return fmt.Errorf("%w: session generation label is empty", ErrInvalidConfig)
+	case c.pointEncoding != nil && (c.pointEncoding.Marshal == nil || c.pointEncoding.Unmarshal == nil):
+		return fmt.Errorf("%w: point encoding is missing a function", ErrInvalidConfig)
 	}
+	return nil
 }
 
 func (c *Config) SetSessionConfirmationBytes(scb []byte) *Config {
@@ -35,11 +157,22 @@ func (c *Config) SetSecretGenerationBytes(s []byte) *Config {
 	return c
 }
 
+// SetSessionGenerationBytes sets the label the session key is derived under,
+// "SESSION" by default. Independent deployments can set distinct labels so
+// that their keys are domain separated even for the same shared point.
 func (c *Config) SetSessionGenerationBytes(s []byte) *Config {
 	c.sessionGenerationBytes = s
 	return c
 }
 
+// SetRand sets the source of randomness for ephemeral scalars and ZKP nonces,
+// crypto/rand.Reader by default. Anything other than a cryptographically
+// secure source is only suitable for tests.
+func (c *Config) SetRand(r io.Reader) *Config {
+	c.rand = r
+	return c
+}
+
 func (c *Config) SetHashFn(h HashFnType) *Config {
 	c.hashFn = h
 	return c
@@ -50,14 +183,282 @@ func (c *Config) SetMacFn(f MacFnType) *Config {
 	return c
 }
 
+func (c *Config) SetZKPVerificationObserver(o ZKPVerificationObserverFnType) *Config {
+	c.zkpVerificationObserver = o
+	return c
+}
+
+// SetRequireKeyConfirmation withholds the session key from the caller until
+// key confirmation has completed for that side.
+func (c *Config) SetRequireKeyConfirmation(r bool) *Config {
+	c.requireKeyConfirmation = r
+	return c
+}
+
+// SetKMACSessionKeyDerivation derives the session key with KMAC256 (NIST SP
+// 800-185) keyed with the shared point, in place of the mac function. The
+// customization string provides domain separation and must match on both
+// sides.
+func (c *Config) SetKMACSessionKeyDerivation(customization []byte) *Config {
+	c.kmacSessionKey = true
+	c.kmacCustomization = customization
+	return c
+}
+
+// SetBindConfirmationKeyToPoints derives the key confirmation key from both
+// the session key and a hash of the four exchanged points, so substituting any
+// point changes the confirmation key itself. Both sides must enable it.
+func (c *Config) SetBindConfirmationKeyToPoints(b bool) *Config {
+	c.bindConfirmationKeyToPoints = b
+	return c
+}
+
+// SetAutoZeroizeEphemerals wipes the private scalars x1, x2, s and x2s once
+// key confirmation completes, leaving only the session key.
+func (c *Config) SetAutoZeroizeEphemerals(z bool) *Config {
+	c.autoZeroizeEphemerals = z
+	return c
+}
+
+// SetPasswordStretch runs the password through f with the given salt before
+// the secret scalar is derived, raising the cost of an offline guessing
+// attack on captured transcripts. The secret is fixed when the exchange is
+// initialised, before the peer's user ID is known, so the salt cannot be
+// derived from the user IDs; it must be agreed out of band, for example the
+// pair of user IDs both sides expect. Both sides must use the same function
+// and salt.
+func (c *Config) SetPasswordStretch(f PasswordStretchFnType, salt []byte) *Config {
+	c.passwordStretch = f
+	c.passwordSalt = salt
+	return c
+}
+
+// SetSalt mixes salt into the derivation of the secret scalar s, so that
+// pairings with the same static password in different contexts, for example
+// with a nonce exchanged before pass 1, do not share s. Both sides must use
+// the same salt. An empty salt leaves the derivation unchanged. It is
+// independent of the salt given to SetPasswordStretch.
+func (c *Config) SetSalt(salt []byte) *Config {
+	c.salt = salt
+	return c
+}
+
+// SetSessionID binds every ZKP challenge and key confirmation MAC to id, so
+// that proofs from one session cannot be replayed into another by a peer with
+// the same user ID. Both sides must agree on id out of band. An empty id
+// leaves the exchange unchanged.
+func (c *Config) SetSessionID(id []byte) *Config {
+	c.sessionID = id
+	return c
+}
+
+// SetAllowEmptyPassword permits an empty password, which the Init functions
+// otherwise reject with ErrEmptyPassword. With an empty password any peer
+// can complete the exchange, so this is only suitable where the channel is
+// authenticated by other means.
+func (c *Config) SetAllowEmptyPassword(allow bool) *Config {
+	c.allowEmptyPassword = allow
+	return c
+}
+
+// checkPassword rejects an empty password unless the config allows it.
+func (c *Config) checkPassword(pw []byte) error {
+	if len(pw) == 0 && !c.allowEmptyPassword {
+		return ErrEmptyPassword
+	}
+	return nil
+}
+
+// SetBlindedUserIDs replaces the user IDs given to the three pass Init
+// functions with hash("JPAKE_BLINDED_USER_ID" || role || salt), so that an
+// observer does not learn stable device identifiers. The two roles always
+// derive different IDs, and the ZKPs are bound to the blinded IDs as they
+// would be to plain ones. Both sides must use the same salt, ideally one
+// exchanged for this session so that IDs are not linkable across sessions.
+func (c *Config) SetBlindedUserIDs(salt []byte) *Config {
+	c.blindUserIDs = true
+	c.blindingSalt = salt
+	return c
+}
+
+// blindedUserID returns the user ID role uses when user IDs are blinded.
+func (c *Config) blindedUserID(role Role) []byte {
+	return c.hashFn(concat([]byte("JPAKE_BLINDED_USER_ID"), []byte(role.String()), c.blindingSalt))
+}
+
+// SetAssociatedData mixes ad into the session key derivation, binding the key
+// to context such as a device serial number. Both sides must supply the same
+// ad to derive the same key, and so to pass key confirmation. The shared
+// point itself is unaffected.
+func (c *Config) SetAssociatedData(ad []byte) *Config {
+	c.associatedData = ad
+	return c
+}
+
+// SetConfirmationContext folds ctx, such as an application protocol version
+// and negotiated cipher suite, into both key confirmation MACs, so that a
+// peer which saw a different context fails confirmation and a downgrade is
+// noticed. Both sides must supply the same ctx. Unlike SetAssociatedData the
+// session key is unaffected.
+func (c *Config) SetConfirmationContext(ctx []byte) *Config {
+	c.confirmationContext = ctx
+	return c
+}
+
+// SetPointEncoding sets the wire encoding of points in frames, and so in
+// RunInitiator and RunResponder, see PointEncoding. Both sides must use the
+// same encoding. A nil e restores the encoding of Bytes.
+func (c *Config) SetPointEncoding(e *PointEncoding) *Config {
+	c.pointEncoding = e
+	return c
+}
+
+// SetSessionKeyLength sets the length of the derived session key in bytes.
+// A key shorter than the mac function's output is a prefix of it; a longer
+// key is extended by chaining further mac blocks. With KMAC derivation the
+// length is passed to KMAC itself. Both sides must use the same length. Zero
+// or less restores the default, a single mac output.
+func (c *Config) SetSessionKeyLength(n int) *Config {
+	c.sessionKeyLength = n
+	return c
+}
+
+// SetObserver installs o to be notified of stage transitions, ZKP checks and
+// the derivation of the session key.
+func (c *Config) SetObserver(o Observer) *Config {
+	c.observer = o
+	return c
+}
+
+// SetMaxUserIDLength bounds the length of the peer's user ID, which is
+// hashed into every ZKP challenge and confirmation MAC, so that a peer cannot
+// make each of them arbitrarily expensive. The default is 256 bytes; zero or
+// less removes the limit. An empty peer user ID is always rejected.
+func (c *Config) SetMaxUserIDLength(n int) *Config {
+	c.maxUserIDLength = n
+	return c
+}
+
+// SetZKPChallengeFn replaces the serialization of ZKP challenge inputs, by
+// default the length prefixed concatenation of generator, T, y, user ID and
+// session ID. A custom serialization is responsible for any session binding:
+// SetSessionID does not apply to it. Both sides must use the same function.
+func (c *Config) SetZKPChallengeFn(f ZKPChallengeFnType) *Config {
+	c.zkpChallengeFn = f
+	return c
+}
+
+// SetConfirmationFn replaces the key confirmation MACs with messages computed
+// by f from the shared point, for peers which confirm keys another way. The
+// session ID, confirmation context and SetBindConfirmationKeyToPoints do not
+// apply to it. The shared point is not part of a restored exchange, so
+// confirmation fails with ErrConfirmationUnavailable after
+// RestoreThreePassJpake. Both sides must use the same function.
+func (c *Config) SetConfirmationFn(f ConfirmationFnType) *Config {
+	c.confirmationFn = f
+	return c
+}
+
+// zkpChallenge hashes the inputs of a ZKP challenge.
+func (c *Config) zkpChallenge(generator, t, y, userID []byte) ([]byte, error) {
+	if c.zkpChallengeFn == nil {
+		return c.hashFn(concat(c.withSessionID(generator, t, y, userID)...)), nil
+	}
+	chal, err := c.zkpChallengeFn(generator, t, y, userID)
+	if err != nil {
+		return nil, err
+	}
+	return c.hashFn(chal), nil
+}
+
+// checkPeerUserID rejects an empty peer user ID, which would make the user
+// ID collision check meaningless, and one longer than the configured limit.
+func (c *Config) checkPeerUserID(id []byte) error {
+	if len(id) == 0 {
+		return fmt.Errorf("%w: empty", ErrUserIDLength)
+	}
+	if c.maxUserIDLength > 0 && len(id) > c.maxUserIDLength {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrUserIDLength, len(id), c.maxUserIDLength)
+	}
+	return nil
+}
+
+// withSessionID appends the session ID, if any, as a final part for concat.
+func (c *Config) withSessionID(parts ...[]byte) [][]byte {
+	if len(c.sessionID) == 0 {
+		return parts
+	}
+	return append(parts, c.sessionID)
+}
+
+// DeriveSessionKeyFromSharedPoint derives the session key from the encoded
+// shared point k exactly as the handshake does, allowing a key to be
+// recomputed from a captured shared point.
+func DeriveSessionKeyFromSharedPoint(k []byte, config *Config) []byte {
+	return config.generateSessionKey(k)
+}
+
 func (c *Config) generateSecret(pw []byte) []byte {
-	return c.hashFn(c.macFn(pw, c.secretGenerationBytes))
+	if c.passwordStretch != nil {
+		pw = c.passwordStretch(pw, c.passwordSalt)
+	}
+	msg := c.secretGenerationBytes
+	if len(c.salt) != 0 {
+		msg = concat(c.secretGenerationBytes, c.salt)
+	}
+	return c.hashFn(c.macFn(pw, msg))
 }
 
 func (c *Config) generateConfirmationMac(k, msg []byte) []byte {
 	return c.macFn(c.macFn(k, c.sessionConfirmationBytes), msg)
 }
 
+// sessionKeyID identifies a session key to an Observer without revealing it.
+func (c *Config) sessionKeyID(key []byte) []byte {
+	return c.hashFn(concat([]byte("JPAKE_KEY_ID"), key))
+}
+
 func (c *Config) generateSessionKey(k []byte) []byte {
-	return c.macFn(k, c.sessionGenerationBytes)
+	msg := c.sessionGenerationBytes
+	if len(c.associatedData) != 0 {
+		msg = concat(c.sessionGenerationBytes, c.associatedData)
+	}
+	return c.deriveKey(k, msg)
+}
+
+// ratchetSessionKey derives the key following key at step counter of a key
+// ratchet, with the same KDF and length as the session key.
+func (c *Config) ratchetSessionKey(key []byte, counter uint64) []byte {
+	return c.deriveKey(key, binary.BigEndian.AppendUint64([]byte("JPAKE_RATCHET"), counter))
+}
+
+func (c *Config) deriveKey(k, msg []byte) []byte {
+	if c.kmacSessionKey {
+		l := 32
+		if c.sessionKeyLength > 0 {
+			l = c.sessionKeyLength
+		}
+		return kmac256(k, msg, l, c.kmacCustomization)
+	}
+	key := c.macFn(k, msg)
+	if c.sessionKeyLength <= 0 {
+		return key
+	}
+	if c.sessionKeyLength <= len(key) {
+		return key[:c.sessionKeyLength]
+	}
+	return c.expandSessionKey(key, msg)
+}
+
+// expandSessionKey extends prk to the configured length with blocks
+// T(i) = mac(prk, T(i-1) || msg || i), as in the HKDF expand step but with a
+// four byte counter so that the length is not limited to 255 blocks.
+func (c *Config) expandSessionKey(prk, msg []byte) []byte {
+	out := make([]byte, 0, c.sessionKeyLength+len(prk))
+	var block []byte
+	for i := uint32(1); len(out) < c.sessionKeyLength; i++ {
+		block = c.macFn(prk, append(append(append([]byte{}, block...), msg...), byte(i>>24), byte(i>>16), byte(i>>8), byte(i)))
+		out = append(out, block...)
+	}
+	return out[:c.sessionKeyLength]
 }
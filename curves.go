@@ -2,7 +2,12 @@ package jpake
 
 import (
 	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"sync"
 
 	"filippo.io/edwards25519"
 )
@@ -11,7 +16,24 @@ type CurveParams struct {
 	N *big.Int
 }
 
+// secretInRange reduces b into [l, N-1]. l must be at least 1, so that a
+// secret which is a multiple of N can never become the zero scalar.
+func (p *CurveParams) secretInRange(l int, b []byte) (*big.Int, error) {
+	if l < 1 || big.NewInt(int64(l)).Cmp(p.N) >= 0 {
+		return nil, fmt.Errorf("invalid lower bound %d for a secret scalar", l)
+	}
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Sub(p.N, lower)
+	n := new(big.Int).SetBytes(b)
+	n.Mod(n, upper)
+	n.Add(n, lower)
+	return n, nil
+}
+
 type CurvePoint[P any, S any] interface {
+	// Size returns the length of the encoding produced by Bytes, which
+	// decoders use to validate field boundaries.
+	Size() int
 	Add(r1, r2 P) P
 	Subtract(r1, r2 P) P
 	ScalarBaseMult(scalar S) (P, error)
@@ -22,24 +44,141 @@ type CurvePoint[P any, S any] interface {
 }
 
 type CurveScalar[S any] interface {
+	// Size returns the length of the encoding produced by Bytes.
+	Size() int
 	SetBigInt(*big.Int) (S, error)
 	BigInt() *big.Int
 	Multiply(S, S) (S, error)
+	// Add, Subtract and Negate reduce modulo N, so that callers need not
+	// round trip through BigInt.
+	Add(S, S) (S, error)
+	Subtract(S, S) (S, error)
+	Negate(S) (S, error)
 	Bytes() []byte
 	SetBytes(b []byte) (S, error)
 	Zero() bool
+	// Equal returns 1 if the scalars are equal and 0 otherwise, in constant
+	// time.
+	Equal(S) int
 }
 
 type Curve[P CurvePoint[P, S], S CurveScalar[S]] interface {
+	Name() string
+	// PointByteLen and ScalarByteLen are the lengths of the encodings
+	// returned by Bytes.
+	PointByteLen() int
+	ScalarByteLen() int
 	Params() *CurveParams
 	NewGeneratorPoint() P
-	NewRandomScalar(int) (S, error)
+	// NewRandomScalar returns a scalar uniformly distributed in [l, N-1],
+	// reading randomness from rand.
+	NewRandomScalar(rand io.Reader, l int) (S, error)
 	NewScalarFromSecret(int, []byte) (S, error)
 	NewPoint() P
 	NewScalar() S
 	Infinity(P) bool
 }
 
+var ErrUnknownCurve = errors.New("unknown curve")
+
+// Handshake is a three pass exchange driven through frames, allowing the
+// curve to be chosen at runtime without naming its point and scalar types.
+// *ThreePassJpake satisfies it for every curve.
+type Handshake interface {
+	Pass1Frame() (*Frame, error)
+	ProcessFrame(typeTag byte, body []byte) (*Frame, error)
+	Key() ([]byte, error)
+}
+
+// NamedCurve is a type-erased curve as returned by CurveByName.
+type NamedCurve interface {
+	Name() string
+	PointByteLen() int
+	ScalarByteLen() int
+	NewThreePassJpake(role Role, userID, pw []byte, config *Config) (Handshake, error)
+}
+
+type namedCurve[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	curve Curve[P, S]
+}
+
+func (n namedCurve[P, S]) Name() string {
+	return n.curve.Name()
+}
+
+func (n namedCurve[P, S]) PointByteLen() int {
+	return n.curve.PointByteLen()
+}
+
+func (n namedCurve[P, S]) ScalarByteLen() int {
+	return n.curve.ScalarByteLen()
+}
+
+func (n namedCurve[P, S]) NewThreePassJpake(role Role, userID, pw []byte, config *Config) (Handshake, error) {
+	jp, err := InitThreePassJpakeWithConfigAndCurve[P, S](role, userID, pw, n.curve, config)
+	if err != nil {
+		return nil, err
+	}
+	return jp, nil
+}
+
+func (n namedCurve[P, S]) newPAKE(variant Variant, role Role, userID, pw []byte, config *Config) (PAKE, error) {
+	switch variant {
+	case VariantThreePass:
+		jp, err := InitThreePassJpakeWithConfigAndCurve[P, S](role, userID, pw, n.curve, config)
+		if err != nil {
+			return nil, err
+		}
+		return jp, nil
+	case VariantTwoPass:
+		jp, err := InitTwoPassJpakeWithConfigAndCurve[P, S](userID, pw, n.curve, config)
+		if err != nil {
+			return nil, err
+		}
+		return jp, nil
+	}
+	return nil, fmt.Errorf("unknown variant %d", variant)
+}
+
+var (
+	curveRegistryMu sync.RWMutex
+	curveRegistry   = map[string]NamedCurve{}
+)
+
+func init() {
+	RegisterCurve[*Curve25519Point, *Curve25519Scalar](Curve25519Curve{})
+	RegisterCurve[*P256Point, *P256Scalar](P256Curve{})
+	RegisterCurve[*P384Point, *P384Scalar](P384Curve{})
+	RegisterCurve[*Secp256k1Point, *Secp256k1Scalar](Secp256k1Curve{})
+	RegisterCurve[*Ristretto255Point, *Ristretto255Scalar](Ristretto255Curve{})
+	RegisterCurve[*Ed448Point, *Ed448Scalar](Ed448Curve{})
+}
+
+// RegisterCurve makes curve available to CurveByName under curve.Name(),
+// replacing any curve previously registered with that name.
+func RegisterCurve[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S]) {
+	curveRegistryMu.Lock()
+	defer curveRegistryMu.Unlock()
+	curveRegistry[curve.Name()] = namedCurve[P, S]{curve: curve}
+}
+
+// CurveByName returns the registered curve with the given name.
+func CurveByName(name string) (NamedCurve, error) {
+	curveRegistryMu.RLock()
+	defer curveRegistryMu.RUnlock()
+	c, ok := curveRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurve, name)
+	}
+	return c, nil
+}
+
+// Encoded sizes of Curve25519 points and scalars.
+const (
+	Curve25519PointSize  = 32
+	Curve25519ScalarSize = 32
+)
+
 var Curve25519Params = &CurveParams{
 	N: bigFromHex("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed"),
 }
@@ -51,6 +190,18 @@ type Curve25519Curve struct {
 	Curve[*Curve25519Point, *Curve25519Scalar]
 }
 
+func (c Curve25519Curve) Name() string {
+	return "curve25519"
+}
+
+func (c Curve25519Curve) PointByteLen() int {
+	return Curve25519PointSize
+}
+
+func (c Curve25519Curve) ScalarByteLen() int {
+	return Curve25519ScalarSize
+}
+
 func (c Curve25519Curve) Params() *CurveParams {
 	return Curve25519Params
 }
@@ -67,11 +218,11 @@ func (c Curve25519Curve) NewScalar() *Curve25519Scalar {
 	return (*Curve25519Scalar)(edwards25519.NewScalar())
 }
 
-func (c Curve25519Curve) NewRandomScalar(l int) (*Curve25519Scalar, error) {
+func (c Curve25519Curve) NewRandomScalar(rand io.Reader, l int) (*Curve25519Scalar, error) {
 	lower := new(big.Int).SetInt64(int64(l))
 	upper := new(big.Int).Set(c.Params().N)
 	upper.Sub(upper, lower)
-	n, err := crypto_rand.Int(crypto_rand.Reader, upper)
+	n, err := crypto_rand.Int(rand, upper)
 	if err != nil {
 		return nil, err
 	}
@@ -79,13 +230,14 @@ func (c Curve25519Curve) NewRandomScalar(l int) (*Curve25519Scalar, error) {
 	return c.NewScalar().SetBigInt(n)
 }
 
+// NewScalarFromSecret maps b into [l, N-1]. b may be of any length, such as
+// the 64 byte output of a SHA512 based hash function, as it is reduced before
+// being offset by l.
 func (c Curve25519Curve) NewScalarFromSecret(l int, b []byte) (*Curve25519Scalar, error) {
-	lower := new(big.Int).SetInt64(int64(l))
-	upper := new(big.Int).Set(c.Params().N)
-	upper.Sub(upper, lower)
-	n := new(big.Int).SetBytes(b)
-	n.Mod(n, upper)
-	n.Add(n, lower)
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
 	return c.NewScalar().SetBigInt(n)
 }
 
@@ -102,10 +254,30 @@ func (c Curve25519Curve) MultiplyScalar(a, b []byte) ([]byte, error) {
 	return sa.Bytes(), nil
 }
 
+// IsClamped reports whether a 32 byte little-endian scalar has been clamped
+// the way X25519 clamps private keys: the low three bits cleared, the top bit
+// cleared and bit 254 set. J-PAKE here uses unclamped scalars reduced mod N,
+// which are always below 2^253 and so never appear clamped; peers that clamp
+// their scalars will not interoperate.
+func IsClamped(scalarBytes []byte) bool {
+	if len(scalarBytes) != 32 {
+		return false
+	}
+	return scalarBytes[0]&7 == 0 && scalarBytes[31]&0xc0 == 0x40
+}
+
 func (c Curve25519Curve) Infinity(p *Curve25519Point) bool {
 	return p.Equal(c.NewPoint()) == 1
 }
 
+// IsSmallOrder reports whether p is in the subgroup of order 8, including the
+// identity. A peer sending such a point could confine the shared key to a
+// handful of values.
+func (c Curve25519Curve) IsSmallOrder(p *Curve25519Point) bool {
+	q := edwards25519.NewIdentityPoint().MultByCofactor((*edwards25519.Point)(p))
+	return c.Infinity((*Curve25519Point)(q))
+}
+
 func (p *Curve25519Point) Add(r1, r2 *Curve25519Point) *Curve25519Point {
 	return (*Curve25519Point)((*edwards25519.Point)(p).Add((*edwards25519.Point)(r1), (*edwards25519.Point)(r2)))
 }
@@ -127,10 +299,20 @@ func (p *Curve25519Point) SetBytes(b []byte) (*Curve25519Point, error) {
 	return (*Curve25519Point)(p1), err
 }
 
+func (p *Curve25519Point) Size() int {
+	return Curve25519PointSize
+}
+
 func (p *Curve25519Point) Bytes() []byte {
 	return ((*edwards25519.Point)(p).Bytes())
 }
 
+// BytesMontgomery returns the u-coordinate of the point on Curve25519 in
+// Montgomery form, as used by X25519.
+func (p *Curve25519Point) BytesMontgomery() []byte {
+	return (*edwards25519.Point)(p).BytesMontgomery()
+}
+
 func (p *Curve25519Point) Equal(q *Curve25519Point) int {
 	return (*edwards25519.Point)(p).Equal((*edwards25519.Point)(q))
 }
@@ -162,11 +344,27 @@ func (s *Curve25519Scalar) Multiply(t *Curve25519Scalar, u *Curve25519Scalar) (*
 	return (*Curve25519Scalar)((*edwards25519.Scalar)(s).Multiply((*edwards25519.Scalar)(t), (*edwards25519.Scalar)(u))), nil
 }
 
+func (s *Curve25519Scalar) Add(t *Curve25519Scalar, u *Curve25519Scalar) (*Curve25519Scalar, error) {
+	return (*Curve25519Scalar)((*edwards25519.Scalar)(s).Add((*edwards25519.Scalar)(t), (*edwards25519.Scalar)(u))), nil
+}
+
+func (s *Curve25519Scalar) Subtract(t *Curve25519Scalar, u *Curve25519Scalar) (*Curve25519Scalar, error) {
+	return (*Curve25519Scalar)((*edwards25519.Scalar)(s).Subtract((*edwards25519.Scalar)(t), (*edwards25519.Scalar)(u))), nil
+}
+
+func (s *Curve25519Scalar) Negate(t *Curve25519Scalar) (*Curve25519Scalar, error) {
+	return (*Curve25519Scalar)((*edwards25519.Scalar)(s).Negate((*edwards25519.Scalar)(t))), nil
+}
+
 func (s *Curve25519Scalar) SetBytes(b []byte) (*Curve25519Scalar, error) {
 	s1, err := ((*edwards25519.Scalar)(s).SetCanonicalBytes(b))
 	return (*Curve25519Scalar)(s1), err
 }
 
+func (s *Curve25519Scalar) Size() int {
+	return Curve25519ScalarSize
+}
+
 func (s *Curve25519Scalar) Bytes() []byte {
 	return ((*edwards25519.Scalar)(s).Bytes())
 }
@@ -174,3 +372,11 @@ func (s *Curve25519Scalar) Bytes() []byte {
 func (s *Curve25519Scalar) Zero() bool {
 	return s.BigInt().BitLen() == 0
 }
+
+func (s *Curve25519Scalar) Equal(t *Curve25519Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+func (s *Curve25519Scalar) Zeroize() {
+	*s = Curve25519Scalar{}
+}
@@ -0,0 +1,299 @@
+package jpake
+
+import (
+	"bytes"
+	crypto_rand "crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestCurve25519NewScalarFromLongSecret(t *testing.T) {
+	curve := Curve25519Curve{}
+	secrets := [][]byte{
+		bytes.Repeat([]byte{0xff}, 64),
+		bytes.Repeat([]byte{0x00}, 64),
+		append(bytes.Repeat([]byte{0x00}, 32), curve.Params().N.Bytes()...),
+	}
+	for _, secret := range secrets {
+		s, err := curve.NewScalarFromSecret(1, secret)
+		if err != nil {
+			t.Fatalf("error creating scalar from %x: %v", secret, err)
+		}
+		if _, err := curve.NewScalar().SetBytes(s.Bytes()); err != nil {
+			t.Fatalf("expected canonical scalar for %x: %v", secret, err)
+		}
+		n := s.BigInt()
+		if n.Sign() <= 0 || n.Cmp(curve.Params().N) >= 0 {
+			t.Fatalf("expected scalar for %x to be within [1, N-1], was %x", secret, n)
+		}
+	}
+}
+
+func TestCurve25519NewScalarFromSHA512Secret(t *testing.T) {
+	config := NewConfig().SetHashFn(func(in []byte) []byte {
+		hash := sha512.Sum512(in)
+		return hash[:]
+	})
+	secret := config.generateSecret([]byte("password"))
+	if len(secret) != 64 {
+		t.Fatalf("expected 64 byte secret, was %d", len(secret))
+	}
+	s, err := Curve25519Curve{}.NewScalarFromSecret(1, secret)
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	if s.Zero() {
+		t.Fatalf("expected non-zero scalar")
+	}
+}
+
+func TestIsClamped(t *testing.T) {
+	curve := Curve25519Curve{}
+	for i := 0; i < 64; i++ {
+		s, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+		if err != nil {
+			t.Fatalf("error creating scalar: %v", err)
+		}
+		if IsClamped(s.Bytes()) {
+			t.Fatalf("expected generated scalar %x to be unclamped", s.Bytes())
+		}
+	}
+	clamped := bytes.Repeat([]byte{0xff}, 32)
+	clamped[0] &= 248
+	clamped[31] &= 127
+	clamped[31] |= 64
+	if !IsClamped(clamped) {
+		t.Fatalf("expected %x to be detected as clamped", clamped)
+	}
+	if IsClamped(clamped[:31]) {
+		t.Fatalf("expected short input to not be clamped")
+	}
+}
+
+func TestInitThreePassJpakeNamed(t *testing.T) {
+	for _, name := range []string{"curve25519", "p256", "p384", "secp256k1", "ristretto255", "ed448"} {
+		jpake1, err := InitThreePassJpakeNamed(name, Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1 on %s: %v", name, err)
+		}
+		jpake2, err := InitThreePassJpakeNamed(name, Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2 on %s: %v", name, err)
+		}
+		frame, err := jpake1.Pass1Frame()
+		if err != nil {
+			t.Fatalf("error getting pass1 frame on %s: %v", name, err)
+		}
+		sides := []Handshake{jpake2, jpake1}
+		for i := 0; frame != nil; i++ {
+			frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+			if err != nil {
+				t.Fatalf("error processing frame on %s: %v", name, err)
+			}
+		}
+		key1, err := jpake1.Key()
+		if err != nil {
+			t.Fatalf("error getting key1 on %s: %v", name, err)
+		}
+		key2, err := jpake2.Key()
+		if err != nil {
+			t.Fatalf("error getting key2 on %s: %v", name, err)
+		}
+		if !bytes.Equal(key1, key2) {
+			t.Fatalf("expected session key %x to be equal to %x on %s", key1, key2, name)
+		}
+	}
+}
+
+func TestCurveByNameUnknown(t *testing.T) {
+	if _, err := CurveByName("p521"); !errors.Is(err, ErrUnknownCurve) {
+		t.Fatalf("expected ErrUnknownCurve, instead got: %v", err)
+	}
+	if _, err := InitThreePassJpakeNamed("p521", Initiator, []byte("one"), []byte("password")); !errors.Is(err, ErrUnknownCurve) {
+		t.Fatalf("expected ErrUnknownCurve, instead got: %v", err)
+	}
+}
+
+func BenchmarkHandshake(b *testing.B) {
+	for _, name := range []string{"curve25519", "p256", "p384", "secp256k1", "ristretto255", "ed448"} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				jpake1, err := InitThreePassJpakeNamed(name, Initiator, []byte("one"), []byte("password"))
+				if err != nil {
+					b.Fatalf("error init jpake1: %v", err)
+				}
+				jpake2, err := InitThreePassJpakeNamed(name, Responder, []byte("two"), []byte("password"))
+				if err != nil {
+					b.Fatalf("error init jpake2: %v", err)
+				}
+				frame, err := jpake1.Pass1Frame()
+				if err != nil {
+					b.Fatalf("error getting pass1: %v", err)
+				}
+				for sender, receiver := jpake1, jpake2; frame != nil; sender, receiver = receiver, sender {
+					if frame, err = receiver.ProcessFrame(frame.Type, frame.Body); err != nil {
+						b.Fatalf("error processing frame: %v", err)
+					}
+				}
+				if _, err := jpake1.Key(); err != nil {
+					b.Fatalf("error getting key: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func testSecretNeverZero[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	n := curve.Params().N
+	for _, secret := range []*big.Int{
+		big.NewInt(0),
+		n,
+		new(big.Int).Mul(n, big.NewInt(2)),
+		new(big.Int).Sub(n, big.NewInt(1)),
+		new(big.Int).Lsh(n, 256),
+	} {
+		s, err := curve.NewScalarFromSecret(1, secret.Bytes())
+		if err != nil {
+			t.Fatalf("%s: error mapping secret %x: %v", curve.Name(), secret, err)
+		}
+		if s.Zero() {
+			t.Fatalf("%s: expected secret %x to map to a non-zero scalar", curve.Name(), secret)
+		}
+	}
+	if _, err := curve.NewScalarFromSecret(0, n.Bytes()); err == nil {
+		t.Fatalf("%s: expected error for a lower bound of 0, instead got nil", curve.Name())
+	}
+}
+
+func TestNewScalarFromSecretNeverZero(t *testing.T) {
+	testSecretNeverZero[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testSecretNeverZero[*P256Point, *P256Scalar](t, P256Curve{})
+	testSecretNeverZero[*P384Point, *P384Scalar](t, P384Curve{})
+	testSecretNeverZero[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testSecretNeverZero[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testSecretNeverZero[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+}
+
+func testByteLen[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	s, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+	}
+	p, err := curve.NewPoint().ScalarBaseMult(s)
+	if err != nil {
+		t.Fatalf("%s: error multiplying generator: %v", curve.Name(), err)
+	}
+	if l := len(p.Bytes()); l != curve.PointByteLen() {
+		t.Fatalf("%s: expected point length %d, got %d", curve.Name(), curve.PointByteLen(), l)
+	}
+	if l := len(s.Bytes()); l != curve.ScalarByteLen() {
+		t.Fatalf("%s: expected scalar length %d, got %d", curve.Name(), curve.ScalarByteLen(), l)
+	}
+	named, err := CurveByName(curve.Name())
+	if err != nil {
+		t.Fatalf("%s: error looking up curve: %v", curve.Name(), err)
+	}
+	if named.PointByteLen() != curve.PointByteLen() || named.ScalarByteLen() != curve.ScalarByteLen() {
+		t.Fatalf("%s: expected the registered curve to report the same lengths", curve.Name())
+	}
+}
+
+func TestCurveByteLen(t *testing.T) {
+	if c := (Curve25519Curve{}); c.PointByteLen() != 32 || c.ScalarByteLen() != 32 {
+		t.Fatalf("expected curve25519 lengths 32/32, got %d/%d", c.PointByteLen(), c.ScalarByteLen())
+	}
+	testByteLen[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testByteLen[*P256Point, *P256Scalar](t, P256Curve{})
+	testByteLen[*P384Point, *P384Scalar](t, P384Curve{})
+	testByteLen[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testByteLen[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testByteLen[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+}
+
+func testScalarAddSubtract[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	n := curve.Params().N
+	for i := 0; i < 16; i++ {
+		a, err := curve.NewRandomScalar(crypto_rand.Reader, 0)
+		if err != nil {
+			t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+		}
+		b, err := curve.NewRandomScalar(crypto_rand.Reader, 0)
+		if err != nil {
+			t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+		}
+		sum, err := curve.NewScalar().Add(a, b)
+		if err != nil {
+			t.Fatalf("%s: error adding: %v", curve.Name(), err)
+		}
+		expected := new(big.Int).Add(a.BigInt(), b.BigInt())
+		if expected.Mod(expected, n).Cmp(sum.BigInt()) != 0 {
+			t.Fatalf("%s: expected a+b = %x, got %x", curve.Name(), expected, sum.BigInt())
+		}
+		diff, err := curve.NewScalar().Subtract(a, b)
+		if err != nil {
+			t.Fatalf("%s: error subtracting: %v", curve.Name(), err)
+		}
+		expected = new(big.Int).Sub(a.BigInt(), b.BigInt())
+		if expected.Mod(expected, n).Cmp(diff.BigInt()) != 0 {
+			t.Fatalf("%s: expected a-b = %x, got %x", curve.Name(), expected, diff.BigInt())
+		}
+		neg, err := curve.NewScalar().Negate(a)
+		if err != nil {
+			t.Fatalf("%s: error negating: %v", curve.Name(), err)
+		}
+		expected = new(big.Int).Neg(a.BigInt())
+		if expected.Mod(expected, n).Cmp(neg.BigInt()) != 0 {
+			t.Fatalf("%s: expected -a = %x, got %x", curve.Name(), expected, neg.BigInt())
+		}
+		if _, err := neg.Add(neg, a); err != nil || !neg.Zero() {
+			t.Fatalf("%s: expected -a+a to be zero, got %x (%v)", curve.Name(), neg.BigInt(), err)
+		}
+		// the receiver may alias an operand
+		if _, err := a.Subtract(a, a); err != nil || !a.Zero() {
+			t.Fatalf("%s: expected a-a to be zero, got %x (%v)", curve.Name(), a.BigInt(), err)
+		}
+	}
+	if neg, err := curve.NewScalar().Negate(curve.NewScalar()); err != nil || !neg.Zero() || neg.Equal(curve.NewScalar()) != 1 {
+		t.Fatalf("%s: expected -0 to be canonical zero, got %x (%v)", curve.Name(), neg.Bytes(), err)
+	}
+}
+
+func testScalarEqual[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	a, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+	}
+	b, err := curve.NewScalar().SetBytes(a.Bytes())
+	if err != nil {
+		t.Fatalf("%s: error copying scalar: %v", curve.Name(), err)
+	}
+	if a.Equal(b) != 1 {
+		t.Fatalf("%s: expected equal scalars to report 1", curve.Name())
+	}
+	if _, err := b.Negate(b); err != nil {
+		t.Fatalf("%s: error negating: %v", curve.Name(), err)
+	}
+	if a.Equal(b) != 0 || a.Equal(curve.NewScalar()) != 0 {
+		t.Fatalf("%s: expected unequal scalars to report 0", curve.Name())
+	}
+}
+
+func TestScalarEqual(t *testing.T) {
+	testScalarEqual[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testScalarEqual[*P256Point, *P256Scalar](t, P256Curve{})
+	testScalarEqual[*P384Point, *P384Scalar](t, P384Curve{})
+	testScalarEqual[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testScalarEqual[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testScalarEqual[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+}
+
+func TestScalarAddSubtract(t *testing.T) {
+	testScalarAddSubtract[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testScalarAddSubtract[*P256Point, *P256Scalar](t, P256Curve{})
+	testScalarAddSubtract[*P384Point, *P384Scalar](t, P384Curve{})
+	testScalarAddSubtract[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testScalarAddSubtract[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testScalarAddSubtract[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+}
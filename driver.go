@@ -0,0 +1,151 @@
+package jpake
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// maxFrameBodySize bounds the body length accepted by readFrame so that a
+// peer cannot make us allocate arbitrarily large buffers.
+const maxFrameBodySize = 1 << 16
+
+// writeFrame writes f as its type tag followed by the length prefixed body.
+func writeFrame(w io.Writer, f *Frame) error {
+	b := append([]byte{f.Type}, concat(f.Body)...)
+	_, err := w.Write(b)
+	return err
+}
+
+func readFrame(r io.Reader) (*Frame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint64(header[1:])
+	if l > maxFrameBodySize {
+		return nil, fmt.Errorf("frame body of %d bytes exceeds limit of %d", l, maxFrameBodySize)
+	}
+	body := make([]byte, l)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &Frame{Type: header[0], Body: body}, nil
+}
+
+// RunInitiator performs the whole exchange as the initiator over rw, including
+// key confirmation, and returns the confirmed session key. Deadlines set on
+// rw, such as those of a net.Conn, apply to every read and write. If a
+// received message fails, an AbortMessage is sent to the peer before
+// returning; one received from the peer is returned as a PeerAbortedError.
+func RunInitiator[P CurvePoint[P, S], S CurveScalar[S]](rw io.ReadWriter, jp *ThreePassJpake[P, S]) ([]byte, error) {
+	return RunInitiatorContext(context.Background(), rw, jp)
+}
+
+// RunResponder performs the whole exchange as the responder over rw, see
+// RunInitiator.
+func RunResponder[P CurvePoint[P, S], S CurveScalar[S]](rw io.ReadWriter, jp *ThreePassJpake[P, S]) ([]byte, error) {
+	return RunResponderContext(context.Background(), rw, jp)
+}
+
+// RunInitiatorContext is RunInitiator, aborting with an error wrapping
+// ctx.Err() once ctx is done. A read or write blocked at that point is
+// interrupted by setting a past deadline if rw supports SetDeadline,
+// otherwise it is abandoned and rw should be closed by the caller.
+func RunInitiatorContext[P CurvePoint[P, S], S CurveScalar[S]](ctx context.Context, rw io.ReadWriter, jp *ThreePassJpake[P, S]) ([]byte, error) {
+	frame, err := jp.Pass1Frame()
+	if err != nil {
+		return nil, err
+	}
+	if err := withContext(ctx, rw, func() error { return writeFrame(rw, frame) }); err != nil {
+		return nil, err
+	}
+	return run(ctx, rw, jp)
+}
+
+// RunResponderContext is RunResponder, see RunInitiatorContext.
+func RunResponderContext[P CurvePoint[P, S], S CurveScalar[S]](ctx context.Context, rw io.ReadWriter, jp *ThreePassJpake[P, S]) ([]byte, error) {
+	return run(ctx, rw, jp)
+}
+
+func run[P CurvePoint[P, S], S CurveScalar[S]](ctx context.Context, rw io.ReadWriter, jp *ThreePassJpake[P, S]) ([]byte, error) {
+	for !jp.confirmed() {
+		var frame *Frame
+		err := withContext(ctx, rw, func() (err error) {
+			frame, err = readFrame(rw)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		reply, err := jp.ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			if !errors.Is(err, ErrPeerAborted) {
+				// best effort, the exchange has failed either way
+				_ = withContext(ctx, rw, func() error { return writeFrame(rw, NewAbortMessage(err).Frame()) })
+			}
+			return nil, err
+		}
+		if reply != nil {
+			if err := withContext(ctx, rw, func() error { return writeFrame(rw, reply) }); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return jp.Key()
+}
+
+// ConfirmWithTimeout completes key confirmation over rw for an exchange
+// whose passes have been run by other means, and returns the confirmed
+// session key. The initiator must be awaiting the responder's confirmation
+// and the responder must already have sent its own. If the peer does not
+// complete confirmation within timeout, an error matching
+// ErrConfirmationTimeout is returned, so that a half open connection cannot
+// block the caller indefinitely. The deadline is enforced as by
+// RunInitiatorContext.
+func ConfirmWithTimeout[P CurvePoint[P, S], S CurveScalar[S]](rw io.ReadWriter, jp *ThreePassJpake[P, S], timeout time.Duration) ([]byte, error) {
+	if jp.Stage != StageAwaitingConfirmation1 && jp.Stage != StageAwaitingConfirmation2 {
+		return nil, fmt.Errorf("cannot confirm at stage %s: %w", jp.Stage, ErrStage)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	key, err := run(ctx, rw, jp)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s: %w", ErrConfirmationTimeout, timeout, err)
+	}
+	return key, err
+}
+
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// withContext runs the blocking io operation f, returning early if ctx is
+// done first. Only f touches rw, so the exchange itself is never mutated
+// concurrently.
+func withContext(ctx context.Context, rw io.ReadWriter, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("handshake aborted: %w", err)
+	}
+	if ctx.Done() == nil {
+		return f()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if d, ok := rw.(deadliner); ok {
+			_ = d.SetDeadline(time.Unix(1, 0))
+			<-done
+		}
+		return fmt.Errorf("handshake aborted: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,245 @@
+package jpake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJpake3PassRunOverPipe(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	type result struct {
+		key []byte
+		err error
+	}
+	responder := make(chan result)
+	go func() {
+		key, err := RunResponder(conn2, jpake2)
+		responder <- result{key, err}
+	}()
+	key1, err := RunInitiator(conn1, jpake1)
+	if err != nil {
+		t.Fatalf("error running initiator: %v", err)
+	}
+	r := <-responder
+	if r.err != nil {
+		t.Fatalf("error running responder: %v", r.err)
+	}
+	if len(key1) == 0 || !bytes.Equal(key1, r.key) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, r.key)
+	}
+}
+
+func TestJpake3PassRunDifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("wrong"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	responder := make(chan error)
+	go func() {
+		_, err := RunResponder(conn2, jpake2)
+		responder <- err
+	}()
+	if _, err := RunInitiator(conn1, jpake1); !errors.Is(err, ErrSessionConfirmation) {
+		t.Fatalf("expected ErrSessionConfirmation, instead got: %v", err)
+	}
+	// the initiator is the first to notice, and tells the responder
+	err = <-responder
+	var aborted *PeerAbortedError
+	if !errors.Is(err, ErrPeerAborted) || !errors.As(err, &aborted) || aborted.Reason != AbortSessionConfirmation {
+		t.Fatalf("expected responder to observe an abort for session confirmation, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassRunResponderAborts(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	// the responder rejects a peer using its own user id
+	jpake2, err := InitThreePassJpake(Responder, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	responder := make(chan error)
+	go func() {
+		_, err := RunResponder(conn2, jpake2)
+		responder <- err
+	}()
+	_, err = RunInitiator(conn1, jpake1)
+	var aborted *PeerAbortedError
+	if !errors.As(err, &aborted) || aborted.Reason != AbortRejectedMessage {
+		t.Fatalf("expected initiator to observe an abort for a rejected message, instead got: %v", err)
+	}
+	if err := <-responder; !errors.Is(err, ErrUserIDCollision) {
+		t.Fatalf("expected ErrUserIDCollision, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassRunDeadline(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	// the peer never reads, so the first write blocks until the deadline
+	if err := conn1.SetDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatalf("error setting deadline: %v", err)
+	}
+	if _, err := RunInitiator(conn1, jpake1); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline to be exceeded, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassRunContextCanceled(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// the peer reads pass 1 and then goes silent
+	go func() {
+		if _, err := readFrame(conn2); err == nil {
+			cancel()
+		}
+	}()
+	start := time.Now()
+	if _, err := RunInitiatorContext(ctx, conn1, jpake1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, instead got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt return after cancel, took %s", elapsed)
+	}
+}
+
+func TestJpake3PassRunContextWithoutDeadline(t *testing.T) {
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	r, w := io.Pipe()
+	defer w.Close()
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{r, io.Discard}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := RunResponderContext(ctx, rw, jpake2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, instead got: %v", err)
+	}
+}
+
+func TestConfirmWithTimeout(t *testing.T) {
+	pair := func() (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar], []byte) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if _, err := ConfirmWithTimeout(nil, jpake1, time.Second); !errors.Is(err, ErrStage) {
+			t.Fatalf("expected ErrStage, instead got: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		return jpake1, jpake2, conf1
+	}
+
+	jpake1, jpake2, conf1 := pair()
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+	type result struct {
+		key []byte
+		err error
+	}
+	responder := make(chan result)
+	go func() {
+		if err := writeFrame(conn2, &Frame{Type: FrameConfirmation1, Body: conf1}); err != nil {
+			responder <- result{nil, err}
+			return
+		}
+		key, err := ConfirmWithTimeout(conn2, jpake2, time.Second)
+		responder <- result{key, err}
+	}()
+	key1, err := ConfirmWithTimeout(conn1, jpake1, time.Second)
+	if err != nil {
+		t.Fatalf("error confirming initiator: %v", err)
+	}
+	r := <-responder
+	if r.err != nil {
+		t.Fatalf("error confirming responder: %v", r.err)
+	}
+	if !bytes.Equal(key1, r.key) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, r.key)
+	}
+
+	// the peer never sends its confirmation
+	jpake1, _, _ = pair()
+	stalled1, stalled2 := net.Pipe()
+	defer stalled1.Close()
+	defer stalled2.Close()
+	start := time.Now()
+	if _, err := ConfirmWithTimeout(stalled1, jpake1, 20*time.Millisecond); !errors.Is(err, ErrConfirmationTimeout) {
+		t.Fatalf("expected ErrConfirmationTimeout, instead got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt return after the timeout, took %s", elapsed)
+	}
+	if jpake1.Stage != StageAwaitingConfirmation1 {
+		t.Fatalf("expected the stage to remain %s, was %s", StageAwaitingConfirmation1, jpake1.Stage)
+	}
+}
@@ -0,0 +1,245 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/cloudflare/circl/ecc/goldilocks"
+)
+
+// Encoded sizes of Ed448 points and scalars, as in RFC 8032 section 5.2.
+const (
+	Ed448PointSize  = 57
+	Ed448ScalarSize = 56
+)
+
+// Ed448Params holds the order of the prime subgroup of edwards448,
+// 2^446 - 13818066809895115352007386748515426880336692474882178609894547503885.
+var Ed448Params = &CurveParams{
+	N: func() *big.Int {
+		n, _ := new(big.Int).SetString("3fffffffffffffffffffffffffffffffffffffffffffffffffffffff7cca23e9c44edb49aed63690216cc2728dc58f552378c292ab5844f3", 16)
+		return n
+	}(),
+}
+
+// Ed448Point is a point on edwards448, which has cofactor 4. SetBytes only
+// accepts points in the prime order subgroup, so every Ed448Point is in that
+// subgroup and there are no small order points for a peer to send. The
+// scalar multiplications rely on this, as they are computed through an
+// isogeny which discards any torsion component.
+type Ed448Point goldilocks.Point
+
+// Ed448Scalar is a scalar modulo the subgroup order, stored little-endian.
+type Ed448Scalar goldilocks.Scalar
+
+type Ed448Curve struct {
+	Curve[*Ed448Point, *Ed448Scalar]
+}
+
+func (c Ed448Curve) Name() string {
+	return "ed448"
+}
+
+func (c Ed448Curve) PointByteLen() int {
+	return Ed448PointSize
+}
+
+func (c Ed448Curve) ScalarByteLen() int {
+	return Ed448ScalarSize
+}
+
+func (c Ed448Curve) Params() *CurveParams {
+	return Ed448Params
+}
+
+func (c Ed448Curve) NewGeneratorPoint() *Ed448Point {
+	return (*Ed448Point)(goldilocks.Curve{}.Generator())
+}
+
+func (c Ed448Curve) NewPoint() *Ed448Point {
+	return (*Ed448Point)(goldilocks.Curve{}.Identity())
+}
+
+func (c Ed448Curve) NewScalar() *Ed448Scalar {
+	return new(Ed448Scalar)
+}
+
+func (c Ed448Curve) NewRandomScalar(rand io.Reader, l int) (*Ed448Scalar, error) {
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Set(c.Params().N)
+	upper.Sub(upper, lower)
+	n, err := crypto_rand.Int(rand, upper)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, lower)
+	return c.NewScalar().SetBigInt(n)
+}
+
+// NewScalarFromSecret maps b into [l, N-1], see Curve25519Curve.
+func (c Ed448Curve) NewScalarFromSecret(l int, b []byte) (*Ed448Scalar, error) {
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewScalar().SetBigInt(n)
+}
+
+func (c Ed448Curve) Infinity(p *Ed448Point) bool {
+	return (*goldilocks.Point)(p).IsIdentity()
+}
+
+func (p *Ed448Point) Add(r1, r2 *Ed448Point) *Ed448Point {
+	*p = Ed448Point(*goldilocks.Curve{}.Add((*goldilocks.Point)(r1), (*goldilocks.Point)(r2)))
+	return p
+}
+
+func (p *Ed448Point) Subtract(r1, r2 *Ed448Point) *Ed448Point {
+	neg := *(*goldilocks.Point)(r2)
+	neg.Neg()
+	*p = Ed448Point(*goldilocks.Curve{}.Add((*goldilocks.Point)(r1), &neg))
+	return p
+}
+
+func (p *Ed448Point) ScalarBaseMult(s *Ed448Scalar) (*Ed448Point, error) {
+	*p = Ed448Point(*goldilocks.Curve{}.ScalarBaseMult((*goldilocks.Scalar)(s)))
+	return p, nil
+}
+
+func (p *Ed448Point) ScalarMult(q *Ed448Point, s *Ed448Scalar) (*Ed448Point, error) {
+	*p = Ed448Point(*goldilocks.Curve{}.ScalarMult((*goldilocks.Scalar)(s), (*goldilocks.Point)(q)))
+	return p, nil
+}
+
+// inPrimeOrderSubgroup reports whether N * q is the identity. It uses plain
+// double and add, since the isogeny based ScalarMult would discard the
+// torsion component being tested for. As N is odd, N * q is the identity
+// only when q has no torsion component. It runs in variable time and is only
+// used on received points.
+func inPrimeOrderSubgroup(q *goldilocks.Point) bool {
+	n := Ed448Params.N
+	acc := goldilocks.Curve{}.Identity()
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		acc.Double()
+		if n.Bit(i) == 1 {
+			acc.Add(q)
+		}
+	}
+	return acc.IsIdentity()
+}
+
+// SetBytes decodes a canonical RFC 8032 encoding of a point in the prime
+// order subgroup, rejecting anything else.
+func (p *Ed448Point) SetBytes(b []byte) (*Ed448Point, error) {
+	if len(b) != Ed448PointSize || b[Ed448PointSize-1]&0x7f != 0 {
+		return nil, errors.New("invalid ed448 point encoding")
+	}
+	q, err := goldilocks.FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if !inPrimeOrderSubgroup(q) {
+		return nil, errors.New("ed448 point is not in the prime order subgroup")
+	}
+	*p = Ed448Point(*q)
+	return p, nil
+}
+
+func (p *Ed448Point) Size() int {
+	return Ed448PointSize
+}
+
+func (p *Ed448Point) Bytes() []byte {
+	// ToBytes normalizes its receiver, so encode a copy
+	q := goldilocks.Point(*p)
+	b := make([]byte, Ed448PointSize)
+	_ = q.ToBytes(b)
+	return b
+}
+
+func (p *Ed448Point) Equal(q *Ed448Point) int {
+	if (*goldilocks.Point)(p).IsEqual((*goldilocks.Point)(q)) {
+		return 1
+	}
+	return 0
+}
+
+func (s *Ed448Scalar) BigInt() *big.Int {
+	b := s.Bytes()
+	for i := 0; i < Ed448ScalarSize/2; i++ {
+		b[i], b[Ed448ScalarSize-i-1] = b[Ed448ScalarSize-i-1], b[i]
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (s *Ed448Scalar) SetBigInt(i *big.Int) (*Ed448Scalar, error) {
+	if i.Sign() < 0 || i.Cmp(Ed448Params.N) >= 0 {
+		return nil, errors.New("ed448 scalar out of range")
+	}
+	b := make([]byte, Ed448ScalarSize)
+	i.FillBytes(b)
+	for j := 0; j < Ed448ScalarSize/2; j++ {
+		b[j], b[Ed448ScalarSize-j-1] = b[Ed448ScalarSize-j-1], b[j]
+	}
+	copy(s[:], b)
+	return s, nil
+}
+
+func (s *Ed448Scalar) Multiply(t *Ed448Scalar, u *Ed448Scalar) (*Ed448Scalar, error) {
+	(*goldilocks.Scalar)(s).Mul((*goldilocks.Scalar)(t), (*goldilocks.Scalar)(u))
+	return s, nil
+}
+
+func (s *Ed448Scalar) Add(t *Ed448Scalar, u *Ed448Scalar) (*Ed448Scalar, error) {
+	(*goldilocks.Scalar)(s).Add((*goldilocks.Scalar)(t), (*goldilocks.Scalar)(u))
+	return s, nil
+}
+
+func (s *Ed448Scalar) Subtract(t *Ed448Scalar, u *Ed448Scalar) (*Ed448Scalar, error) {
+	(*goldilocks.Scalar)(s).Sub((*goldilocks.Scalar)(t), (*goldilocks.Scalar)(u))
+	return s, nil
+}
+
+func (s *Ed448Scalar) Negate(t *Ed448Scalar) (*Ed448Scalar, error) {
+	*s = *t
+	(*goldilocks.Scalar)(s).Neg()
+	return s, nil
+}
+
+// SetBytes decodes a canonical little-endian scalar, rejecting values of N
+// or more.
+func (s *Ed448Scalar) SetBytes(b []byte) (*Ed448Scalar, error) {
+	if len(b) != Ed448ScalarSize {
+		return nil, errors.New("invalid ed448 scalar encoding")
+	}
+	le := make([]byte, Ed448ScalarSize)
+	for i := range b {
+		le[Ed448ScalarSize-i-1] = b[i]
+	}
+	return s.SetBigInt(new(big.Int).SetBytes(le))
+}
+
+func (s *Ed448Scalar) Size() int {
+	return Ed448ScalarSize
+}
+
+func (s *Ed448Scalar) Bytes() []byte {
+	r := goldilocks.Scalar(*s)
+	r.Red()
+	return append([]byte{}, r[:]...)
+}
+
+func (s *Ed448Scalar) Zero() bool {
+	return s.BigInt().BitLen() == 0
+}
+
+func (s *Ed448Scalar) Equal(t *Ed448Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+func (s *Ed448Scalar) Zeroize() {
+	*s = Ed448Scalar{}
+}
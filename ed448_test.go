@@ -0,0 +1,154 @@
+package jpake
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/cloudflare/circl/ecc/goldilocks"
+)
+
+func TestJpake3PassEd448(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Ed448Point, *Ed448Scalar](Initiator, []byte("one"), []byte("password"), Ed448Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Ed448Point, *Ed448Scalar](Responder, []byte("two"), []byte("password"), Ed448Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if len(jpake1.SessionKey) == 0 || !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassEd448DifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpakeNamed("ed448", Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeNamed("ed448", Responder, []byte("two"), []byte("wrong"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []Handshake{jpake2, jpake1}
+	for i := 0; frame != nil && err == nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+	}
+	if err == nil {
+		t.Fatalf("expected error with mismatched passwords, instead got nil")
+	}
+}
+
+func TestEd448RejectsTorsionPoints(t *testing.T) {
+	// (0, -1), the point of order 2
+	p := new(big.Int).Lsh(big.NewInt(1), 448)
+	p.Sub(p, new(big.Int).Lsh(big.NewInt(1), 224))
+	p.Sub(p, big.NewInt(2))
+	order2 := make([]byte, Ed448PointSize)
+	p.FillBytes(order2[:56])
+	for i := 0; i < 28; i++ {
+		order2[i], order2[55-i] = order2[55-i], order2[i]
+	}
+	torsion, err := goldilocks.FromBytes(order2)
+	if err != nil {
+		t.Fatalf("error decoding the order 2 point: %v", err)
+	}
+	if _, err := new(Ed448Point).SetBytes(order2); err == nil {
+		t.Fatalf("expected error decoding a point of order 2, instead got nil")
+	}
+
+	x, err := Ed448Curve{}.NewScalarFromSecret(1, []byte("secret"))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	point, err := Ed448Curve{}.NewPoint().ScalarBaseMult(x)
+	if err != nil {
+		t.Fatalf("error computing point: %v", err)
+	}
+	decoded, err := new(Ed448Point).SetBytes(point.Bytes())
+	if err != nil {
+		t.Fatalf("error decoding a subgroup point: %v", err)
+	}
+	if decoded.Equal(point) != 1 {
+		t.Fatalf("expected point to round trip")
+	}
+	// the same point shifted out of the prime order subgroup
+	shifted := goldilocks.Curve{}.Add((*goldilocks.Point)(point), torsion)
+	if _, err := new(Ed448Point).SetBytes((*Ed448Point)(shifted).Bytes()); err == nil {
+		t.Fatalf("expected error decoding a point with a torsion component, instead got nil")
+	}
+	nonCanonical := point.Bytes()
+	nonCanonical[Ed448PointSize-1] |= 1
+	if _, err := new(Ed448Point).SetBytes(nonCanonical); err == nil {
+		t.Fatalf("expected error decoding a non-canonical encoding, instead got nil")
+	}
+}
+
+func TestEd448Scalar(t *testing.T) {
+	n := Ed448Curve{}.Params().N
+	if _, err := new(Ed448Scalar).SetBigInt(n); err == nil {
+		t.Fatalf("expected error setting a scalar to N, instead got nil")
+	}
+	x, err := Ed448Curve{}.NewScalarFromSecret(1, []byte("secret"))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	decoded, err := new(Ed448Scalar).SetBytes(x.Bytes())
+	if err != nil {
+		t.Fatalf("error decoding scalar: %v", err)
+	}
+	if decoded.BigInt().Cmp(x.BigInt()) != 0 {
+		t.Fatalf("expected scalar %x to round trip, got %x", x.BigInt(), decoded.BigInt())
+	}
+	product, err := Ed448Curve{}.NewScalar().Multiply(x, x)
+	if err != nil {
+		t.Fatalf("error multiplying: %v", err)
+	}
+	expected := new(big.Int).Mul(x.BigInt(), x.BigInt())
+	expected.Mod(expected, n)
+	if product.BigInt().Cmp(expected) != 0 {
+		t.Fatalf("expected product %x, got %x", expected, product.BigInt())
+	}
+	// the generator has order N
+	g := Ed448Curve{}.NewGeneratorPoint()
+	nMinusOne, err := new(Ed448Scalar).SetBigInt(new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	p, err := Ed448Curve{}.NewPoint().ScalarMult(g, nMinusOne)
+	if err != nil {
+		t.Fatalf("error computing point: %v", err)
+	}
+	curve := Ed448Curve{}
+	if !curve.Infinity(curve.NewPoint().Add(p, g)) {
+		t.Fatalf("expected (N-1)G + G to be the identity")
+	}
+}
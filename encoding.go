@@ -0,0 +1,455 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Encoding determines how point and scalar fields are represented within an
+// encoded message.
+type Encoding int
+
+const (
+	EncodingRaw Encoding = iota
+	EncodingHex
+	EncodingBase64
+	EncodingBase64URL
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingRaw:
+		return "raw"
+	case EncodingHex:
+		return "hex"
+	case EncodingBase64:
+		return "base64"
+	case EncodingBase64URL:
+		return "base64url"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+func (e Encoding) encode(b []byte) []byte {
+	switch e {
+	case EncodingHex:
+		return []byte(hex.EncodeToString(b))
+	case EncodingBase64:
+		return []byte(base64.StdEncoding.EncodeToString(b))
+	case EncodingBase64URL:
+		return []byte(base64.RawURLEncoding.EncodeToString(b))
+	}
+	return b
+}
+
+func (e Encoding) decode(b []byte) ([]byte, error) {
+	switch e {
+	case EncodingRaw:
+		return b, nil
+	case EncodingHex:
+		return hex.DecodeString(string(b))
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(string(b))
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(string(b))
+	}
+	return nil, fmt.Errorf("unknown encoding %d", int(e))
+}
+
+// Codec encodes and decodes the three pass messages. Each field is length
+// prefixed using the same framing as concat, with point and scalar fields
+// represented according to Encoding. The zero value uses raw binary fields.
+type Codec[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	Encoding Encoding
+	// Points, if set, replaces the encoding of points before Encoding is
+	// applied, see PointEncoding.
+	Points *PointEncoding
+}
+
+// PointEncoding converts between the encoding of a point returned by Bytes
+// and an alternative wire encoding, such as the SEC 1 uncompressed form, for
+// peers which expect it. Only the wire encoding changes: ZKP challenges, key
+// confirmation, the session key and Transcript always hash Bytes, so both
+// sides agree whatever encoding each received. Unmarshal must return the
+// encoding Bytes would, which is checked like any received point.
+type PointEncoding struct {
+	Marshal   func(canonical []byte) []byte
+	Unmarshal func(wire []byte) ([]byte, error)
+}
+
+// splitConcat reverses concat, returning exactly n parts or an error if the
+// input is truncated or has trailing bytes.
+func splitConcat(b []byte, n int) ([][]byte, error) {
+	parts := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if len(b) < 8 {
+			return nil, errors.New("truncated message")
+		}
+		l := binary.BigEndian.Uint64(b)
+		b = b[8:]
+		if l > uint64(len(b)) {
+			return nil, errors.New("truncated message")
+		}
+		parts = append(parts, b[:l])
+		b = b[l:]
+	}
+	if len(b) != 0 {
+		return nil, errors.New("trailing bytes in message")
+	}
+	return parts, nil
+}
+
+// newElement allocates the value behind a pointer type parameter such as
+// *Curve25519Point so that decoders can call SetBytes on it.
+func newElement[T any]() T {
+	var t T
+	return reflect.New(reflect.TypeOf(t).Elem()).Interface().(T)
+}
+
+func (c Codec[P, S]) encodePoint(p P) []byte {
+	if c.Points != nil {
+		return c.Encoding.encode(c.Points.Marshal(p.Bytes()))
+	}
+	return c.Encoding.encode(p.Bytes())
+}
+
+// PointFromBytes decodes an untrusted point encoding, as returned by Bytes,
+// into a point on curve. It returns ErrInvalidPoint if b has the wrong length,
+// is not on the curve or is not the canonical encoding of its point. Every
+// codec in this package decodes points this way.
+func PointFromBytes[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], b []byte) (P, error) {
+	return setPoint(curve.NewPoint(), b)
+}
+
+// ScalarFromBytes decodes an untrusted scalar encoding, as returned by Bytes.
+// It returns ErrNonCanonicalScalar if b has the wrong length or is not the
+// canonical encoding of a value reduced modulo N.
+func ScalarFromBytes[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], b []byte) (S, error) {
+	return setCanonicalScalar(curve.NewScalar(), b)
+}
+
+// setPoint sets p from raw, see PointFromBytes.
+func setPoint[P CurvePoint[P, S], S CurveScalar[S]](p P, raw []byte) (P, error) {
+	if len(raw) != p.Size() {
+		return *new(P), fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidPoint, p.Size(), len(raw))
+	}
+	p, err := p.SetBytes(raw)
+	if err != nil {
+		return *new(P), fmt.Errorf("%w: %v", ErrInvalidPoint, err)
+	}
+	if !bytes.Equal(p.Bytes(), raw) {
+		return *new(P), fmt.Errorf("%w: non-canonical encoding", ErrInvalidPoint)
+	}
+	return p, nil
+}
+
+func (c Codec[P, S]) decodePoint(name string, b []byte) (P, error) {
+	raw, err := c.Encoding.decode(b)
+	if err != nil {
+		return *new(P), fmt.Errorf("invalid %s encoding of %s: %w", c.Encoding, name, err)
+	}
+	if c.Points != nil {
+		if raw, err = c.Points.Unmarshal(raw); err != nil {
+			return *new(P), fmt.Errorf("invalid %s: %w: %v", name, ErrInvalidPoint, err)
+		}
+	}
+	p, err := setPoint(newElement[P](), raw)
+	if err != nil {
+		return *new(P), fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return p, nil
+}
+
+func (c Codec[P, S]) encodeScalar(s S) []byte {
+	return c.Encoding.encode(s.Bytes())
+}
+
+// setCanonicalScalar sets s from raw, which must be the canonical encoding of
+// a reduced scalar. The backends all reject out of range values, each with
+// its own error, and this reports them uniformly as ErrNonCanonicalScalar.
+func setCanonicalScalar[S CurveScalar[S]](s S, raw []byte) (S, error) {
+	if len(raw) != s.Size() {
+		return *new(S), fmt.Errorf("%w: expected %d bytes, got %d", ErrNonCanonicalScalar, s.Size(), len(raw))
+	}
+	s, err := s.SetBytes(raw)
+	if err != nil {
+		return *new(S), fmt.Errorf("%w: %v", ErrNonCanonicalScalar, err)
+	}
+	if !bytes.Equal(s.Bytes(), raw) {
+		return *new(S), ErrNonCanonicalScalar
+	}
+	return s, nil
+}
+
+func (c Codec[P, S]) decodeScalar(name string, b []byte) (S, error) {
+	raw, err := c.Encoding.decode(b)
+	if err != nil {
+		return *new(S), fmt.Errorf("invalid %s encoding of %s: %w", c.Encoding, name, err)
+	}
+	s, err := setCanonicalScalar(newElement[S](), raw)
+	if err != nil {
+		return *new(S), fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return s, nil
+}
+
+func (c Codec[P, S]) encodeZKP(zkp ZKPMsg[P, S]) []byte {
+	return concat(c.encodePoint(zkp.T), c.encodeScalar(zkp.R))
+}
+
+func (c Codec[P, S]) decodeZKP(name string, b []byte) (ZKPMsg[P, S], error) {
+	parts, err := splitConcat(b, 2)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	t, err := c.decodePoint(name+".T", parts[0])
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	r, err := c.decodeScalar(name+".R", parts[1])
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	return ZKPMsg[P, S]{T: t, R: r}, nil
+}
+
+// EncodePass1 encodes the first message, prefixed with VariantThreePass and
+// its Version.
+func (c Codec[P, S]) EncodePass1(msg *ThreePassVariant1[P, S]) []byte {
+	return c.encodeVariant1(VariantThreePass, msg)
+}
+
+func (c Codec[P, S]) DecodePass1(b []byte) (*ThreePassVariant1[P, S], error) {
+	return c.decodeVariant1(VariantThreePass, b)
+}
+
+func (c Codec[P, S]) encodeVariant1(variant Variant, msg *ThreePassVariant1[P, S]) []byte {
+	return append([]byte{byte(variant), msg.Version}, concat(msg.UserID, c.encodePoint(msg.X1G), c.encodePoint(msg.X2G), c.encodeZKP(msg.X1ZKP), c.encodeZKP(msg.X2ZKP))...)
+}
+
+// decodeVariant1 decodes a first message, which has the same fields in both
+// variants, checking its variant and version prefix. The fields of another
+// version may be laid out differently, so it is rejected before they are read.
+func (c Codec[P, S]) decodeVariant1(variant Variant, b []byte) (*ThreePassVariant1[P, S], error) {
+	if len(b) < 2 {
+		return nil, errors.New("truncated message")
+	}
+	if Variant(b[0]) != variant {
+		return nil, fmt.Errorf("%w: received variant %d", ErrVariantMismatch, b[0])
+	}
+	if err := checkVersion(b[1]); err != nil {
+		return nil, err
+	}
+	parts, err := splitConcat(b[2:], 5)
+	if err != nil {
+		return nil, err
+	}
+	msg := &ThreePassVariant1[P, S]{Version: b[1], UserID: parts[0]}
+	if msg.X1G, err = c.decodePoint("X1G", parts[1]); err != nil {
+		return nil, err
+	}
+	if msg.X2G, err = c.decodePoint("X2G", parts[2]); err != nil {
+		return nil, err
+	}
+	if msg.X1ZKP, err = c.decodeZKP("X1ZKP", parts[3]); err != nil {
+		return nil, err
+	}
+	if msg.X2ZKP, err = c.decodeZKP("X2ZKP", parts[4]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c Codec[P, S]) EncodePass2(msg *ThreePassVariant2[P, S]) []byte {
+	return concat(msg.UserID, c.encodePoint(msg.X3G), c.encodePoint(msg.X4G), c.encodePoint(msg.B), c.encodeZKP(msg.XsZKP), c.encodeZKP(msg.X3ZKP), c.encodeZKP(msg.X4ZKP))
+}
+
+func (c Codec[P, S]) DecodePass2(b []byte) (*ThreePassVariant2[P, S], error) {
+	parts, err := splitConcat(b, 7)
+	if err != nil {
+		return nil, err
+	}
+	msg := &ThreePassVariant2[P, S]{UserID: parts[0]}
+	if msg.X3G, err = c.decodePoint("X3G", parts[1]); err != nil {
+		return nil, err
+	}
+	if msg.X4G, err = c.decodePoint("X4G", parts[2]); err != nil {
+		return nil, err
+	}
+	if msg.B, err = c.decodePoint("B", parts[3]); err != nil {
+		return nil, err
+	}
+	if msg.XsZKP, err = c.decodeZKP("XsZKP", parts[4]); err != nil {
+		return nil, err
+	}
+	if msg.X3ZKP, err = c.decodeZKP("X3ZKP", parts[5]); err != nil {
+		return nil, err
+	}
+	if msg.X4ZKP, err = c.decodeZKP("X4ZKP", parts[6]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c Codec[P, S]) EncodePass3(msg *ThreePassVariant3[P, S]) []byte {
+	return concat(c.encodePoint(msg.A), c.encodeZKP(msg.XsZKP))
+}
+
+func (c Codec[P, S]) DecodePass3(b []byte) (*ThreePassVariant3[P, S], error) {
+	parts, err := splitConcat(b, 2)
+	if err != nil {
+		return nil, err
+	}
+	msg := &ThreePassVariant3[P, S]{}
+	if msg.A, err = c.decodePoint("A", parts[0]); err != nil {
+		return nil, err
+	}
+	if msg.XsZKP, err = c.decodeZKP("XsZKP", parts[1]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// EncodeTwoPass1 encodes the two pass first message, prefixed with
+// VariantTwoPass and its Version.
+func (c Codec[P, S]) EncodeTwoPass1(msg *TwoPassVariant1[P, S]) []byte {
+	return c.encodeVariant1(VariantTwoPass, (*ThreePassVariant1[P, S])(msg))
+}
+
+func (c Codec[P, S]) DecodeTwoPass1(b []byte) (*TwoPassVariant1[P, S], error) {
+	msg, err := c.decodeVariant1(VariantTwoPass, b)
+	if err != nil {
+		return nil, err
+	}
+	return (*TwoPassVariant1[P, S])(msg), nil
+}
+
+func (c Codec[P, S]) EncodeTwoPass2(msg *TwoPassVariant2[P, S]) []byte {
+	return c.EncodePass3((*ThreePassVariant3[P, S])(msg))
+}
+
+func (c Codec[P, S]) DecodeTwoPass2(b []byte) (*TwoPassVariant2[P, S], error) {
+	msg, err := c.DecodePass3(b)
+	if err != nil {
+		return nil, err
+	}
+	return (*TwoPassVariant2[P, S])(msg), nil
+}
+
+// MarshalBinary encodes the proof as its length prefixed T and R fields.
+func (zkp ZKPMsg[P, S]) MarshalBinary() ([]byte, error) {
+	return Codec[P, S]{}.encodeZKP(zkp), nil
+}
+
+func (zkp *ZKPMsg[P, S]) UnmarshalBinary(b []byte) error {
+	decoded, err := Codec[P, S]{}.decodeZKP("ZKP", b)
+	if err != nil {
+		return err
+	}
+	*zkp = decoded
+	return nil
+}
+
+// MarshalBinary encodes the message as the zero value Codec would.
+func (msg ThreePassVariant1[P, S]) MarshalBinary() ([]byte, error) {
+	return Codec[P, S]{}.EncodePass1(&msg), nil
+}
+
+func (msg *ThreePassVariant1[P, S]) UnmarshalBinary(b []byte) error {
+	decoded, err := Codec[P, S]{}.DecodePass1(b)
+	if err != nil {
+		return err
+	}
+	*msg = *decoded
+	return nil
+}
+
+// MarshalBinary encodes the message as the zero value Codec would.
+func (msg ThreePassVariant2[P, S]) MarshalBinary() ([]byte, error) {
+	return Codec[P, S]{}.EncodePass2(&msg), nil
+}
+
+func (msg *ThreePassVariant2[P, S]) UnmarshalBinary(b []byte) error {
+	decoded, err := Codec[P, S]{}.DecodePass2(b)
+	if err != nil {
+		return err
+	}
+	*msg = *decoded
+	return nil
+}
+
+// MarshalBinary encodes the message as the zero value Codec would.
+func (msg ThreePassVariant3[P, S]) MarshalBinary() ([]byte, error) {
+	return Codec[P, S]{}.EncodePass3(&msg), nil
+}
+
+func (msg *ThreePassVariant3[P, S]) UnmarshalBinary(b []byte) error {
+	decoded, err := Codec[P, S]{}.DecodePass3(b)
+	if err != nil {
+		return err
+	}
+	*msg = *decoded
+	return nil
+}
+
+// errNonCanonical is returned by the Parse functions when a message decodes
+// but does not re-encode to the same bytes. Points and scalars are already
+// rejected by the decoders if not canonical, so this guards the framing.
+var errNonCanonical = fmt.Errorf("%w: non-canonical encoding", ErrMalformedMessage)
+
+// ParseThreePassVariant1 strictly decodes a first message received from an
+// untrusted peer in the raw binary encoding. Beyond what DecodePass1 checks,
+// every field must be present and the message must re-encode to b.
+func ParseThreePassVariant1[P CurvePoint[P, S], S CurveScalar[S]](b []byte) (*ThreePassVariant1[P, S], error) {
+	c := Codec[P, S]{}
+	msg, err := c.DecodePass1(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(c.EncodePass1(msg), b) {
+		return nil, errNonCanonical
+	}
+	return msg, nil
+}
+
+// ParseThreePassVariant2 strictly decodes a second message, see
+// ParseThreePassVariant1.
+func ParseThreePassVariant2[P CurvePoint[P, S], S CurveScalar[S]](b []byte) (*ThreePassVariant2[P, S], error) {
+	c := Codec[P, S]{}
+	msg, err := c.DecodePass2(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(c.EncodePass2(msg), b) {
+		return nil, errNonCanonical
+	}
+	return msg, nil
+}
+
+// ParseThreePassVariant3 strictly decodes a third message, see
+// ParseThreePassVariant1.
+func ParseThreePassVariant3[P CurvePoint[P, S], S CurveScalar[S]](b []byte) (*ThreePassVariant3[P, S], error) {
+	c := Codec[P, S]{}
+	msg, err := c.DecodePass3(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(c.EncodePass3(msg), b) {
+		return nil, errNonCanonical
+	}
+	return msg, nil
+}
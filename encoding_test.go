@@ -0,0 +1,434 @@
+package jpake
+
+import (
+	"bytes"
+	crypto_rand "crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+type curve25519Codec = Codec[*Curve25519Point, *Curve25519Scalar]
+
+func TestCodecRoundTrip(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	for _, encoding := range []Encoding{EncodingRaw, EncodingHex, EncodingBase64, EncodingBase64URL} {
+		codec := curve25519Codec{Encoding: encoding}
+
+		decoded1, err := codec.DecodePass1(codec.EncodePass1(msg1))
+		if err != nil {
+			t.Fatalf("error decoding %s pass1: %v", encoding, err)
+		}
+		if !bytes.Equal(codec.EncodePass1(decoded1), codec.EncodePass1(msg1)) {
+			t.Fatalf("expected %s pass1 to round trip", encoding)
+		}
+		decoded2, err := codec.DecodePass2(codec.EncodePass2(msg2))
+		if err != nil {
+			t.Fatalf("error decoding %s pass2: %v", encoding, err)
+		}
+		if !bytes.Equal(codec.EncodePass2(decoded2), codec.EncodePass2(msg2)) {
+			t.Fatalf("expected %s pass2 to round trip", encoding)
+		}
+		decoded3, err := codec.DecodePass3(codec.EncodePass3(msg3))
+		if err != nil {
+			t.Fatalf("error decoding %s pass3: %v", encoding, err)
+		}
+		if !bytes.Equal(codec.EncodePass3(decoded3), codec.EncodePass3(msg3)) {
+			t.Fatalf("expected %s pass3 to round trip", encoding)
+		}
+	}
+}
+
+func TestCodecWrongEncoding(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	encoded := curve25519Codec{Encoding: EncodingBase64}.EncodePass1(msg1)
+	if _, err := (curve25519Codec{Encoding: EncodingHex}).DecodePass1(encoded); err == nil {
+		t.Fatalf("expected error decoding base64 message as hex, instead got nil")
+	}
+	if _, err := (curve25519Codec{}).DecodePass1(encoded); err == nil {
+		t.Fatalf("expected error decoding base64 message as raw, instead got nil")
+	}
+}
+
+func TestCodecMessageSizes(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	// every field carries an 8 byte length prefix
+	point := 8 + Curve25519PointSize
+	zkp := 8 + point + 8 + Curve25519ScalarSize
+	userID := 8 + len("one")
+	codec := curve25519Codec{}
+	// pass1 is prefixed with its variant and version
+	if l, expected := len(codec.EncodePass1(msg1)), 2+userID+2*point+2*zkp; l != expected {
+		t.Fatalf("expected pass1 to be %d bytes, was %d", expected, l)
+	}
+	if l, expected := len(codec.EncodePass2(msg2)), userID+3*point+3*zkp; l != expected {
+		t.Fatalf("expected pass2 to be %d bytes, was %d", expected, l)
+	}
+	if l, expected := len(codec.EncodePass3(msg3)), point+zkp; l != expected {
+		t.Fatalf("expected pass3 to be %d bytes, was %d", expected, l)
+	}
+}
+
+func TestCodecRejectsWrongFieldSize(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	zkp := concat(msg1.X1ZKP.T.Bytes(), msg1.X1ZKP.R.Bytes())
+	body := concat(msg1.UserID, append(msg1.X1G.Bytes(), 0), msg1.X2G.Bytes(), zkp, zkp)
+	if _, err := (curve25519Codec{}).DecodePass1(append([]byte{byte(VariantThreePass), ProtocolVersion}, body...)); err == nil {
+		t.Fatalf("expected error decoding an oversized point, instead got nil")
+	}
+}
+
+func zkpEqual[P CurvePoint[P, S], S CurveScalar[S]](a, b ZKPMsg[P, S]) bool {
+	return a.T.Equal(b.T) == 1 && a.R.Equal(b.R) == 1
+}
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+
+	b, err := msg1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling pass1: %v", err)
+	}
+	var decoded1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded1.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error unmarshalling pass1: %v", err)
+	}
+	if !bytes.Equal(decoded1.UserID, msg1.UserID) || decoded1.X1G.Equal(msg1.X1G) != 1 || decoded1.X2G.Equal(msg1.X2G) != 1 ||
+		!zkpEqual(decoded1.X1ZKP, msg1.X1ZKP) || !zkpEqual(decoded1.X2ZKP, msg1.X2ZKP) {
+		t.Fatalf("expected pass1 to round trip")
+	}
+
+	b, err = msg2.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling pass2: %v", err)
+	}
+	var decoded2 ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded2.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error unmarshalling pass2: %v", err)
+	}
+	if !bytes.Equal(decoded2.UserID, msg2.UserID) || decoded2.X3G.Equal(msg2.X3G) != 1 || decoded2.X4G.Equal(msg2.X4G) != 1 || decoded2.B.Equal(msg2.B) != 1 ||
+		!zkpEqual(decoded2.XsZKP, msg2.XsZKP) || !zkpEqual(decoded2.X3ZKP, msg2.X3ZKP) || !zkpEqual(decoded2.X4ZKP, msg2.X4ZKP) {
+		t.Fatalf("expected pass2 to round trip")
+	}
+
+	b, err = msg3.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling pass3: %v", err)
+	}
+	var decoded3 ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	if err := decoded3.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error unmarshalling pass3: %v", err)
+	}
+	if decoded3.A.Equal(msg3.A) != 1 || !zkpEqual(decoded3.XsZKP, msg3.XsZKP) {
+		t.Fatalf("expected pass3 to round trip")
+	}
+
+	b, err = msg3.XsZKP.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling zkp: %v", err)
+	}
+	var zkp ZKPMsg[*Curve25519Point, *Curve25519Scalar]
+	if err := zkp.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error unmarshalling zkp: %v", err)
+	}
+	if !zkpEqual(zkp, msg3.XsZKP) {
+		t.Fatalf("expected zkp to round trip")
+	}
+	if err := zkp.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatalf("expected error unmarshalling truncated zkp, instead got nil")
+	}
+	if err := decoded3.UnmarshalBinary(nil); err == nil {
+		t.Fatalf("expected error unmarshalling empty pass3, instead got nil")
+	}
+}
+
+func TestParseThreePassVariants(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	codec := curve25519Codec{}
+	if _, err := ParseThreePassVariant1[*Curve25519Point, *Curve25519Scalar](codec.EncodePass1(msg1)); err != nil {
+		t.Fatalf("error parsing pass1: %v", err)
+	}
+	if _, err := ParseThreePassVariant2[*Curve25519Point, *Curve25519Scalar](codec.EncodePass2(msg2)); err != nil {
+		t.Fatalf("error parsing pass2: %v", err)
+	}
+	if _, err := ParseThreePassVariant3[*Curve25519Point, *Curve25519Scalar](codec.EncodePass3(msg3)); err != nil {
+		t.Fatalf("error parsing pass3: %v", err)
+	}
+
+	// y = p + 1 is a non-canonical encoding of the identity
+	nonCanonical := append([]byte{0xee}, bytes.Repeat([]byte{0xff}, 30)...)
+	nonCanonical = append(nonCanonical, 0x7f)
+	if _, err := new(Curve25519Point).SetBytes(nonCanonical); err != nil {
+		t.Fatalf("expected the point decoder to accept the non-canonical encoding: %v", err)
+	}
+	zkp1 := codec.encodeZKP(msg1.X1ZKP)
+	zkp2 := codec.encodeZKP(msg1.X2ZKP)
+	body := append([]byte{byte(VariantThreePass), ProtocolVersion}, concat(msg1.UserID, nonCanonical, msg1.X2G.Bytes(), zkp1, zkp2)...)
+	if _, err := codec.DecodePass1(body); !errors.Is(err, ErrInvalidPoint) {
+		t.Fatalf("expected ErrInvalidPoint decoding a non-canonical point, instead got: %v", err)
+	}
+	if _, err := ParseThreePassVariant1[*Curve25519Point, *Curve25519Scalar](body); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage for a non-canonical point, instead got: %v", err)
+	}
+	if _, err := ParseThreePassVariant3[*Curve25519Point, *Curve25519Scalar](codec.EncodePass3(msg3)[1:]); err == nil {
+		t.Fatalf("expected error parsing truncated pass3, instead got nil")
+	}
+}
+
+// nonCanonicalScalar returns the group order N in the curve's scalar byte
+// order, the smallest value every backend must reject.
+func nonCanonicalScalar[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S]) []byte {
+	b := curve.Params().N.FillBytes(make([]byte, curve.ScalarByteLen()))
+	one, _ := curve.NewScalar().SetBigInt(big.NewInt(1))
+	if one.Bytes()[0] == 1 {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	return b
+}
+
+func testNonCanonicalR[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	r, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+	}
+	b, err := ZKPMsg[P, S]{T: curve.NewGeneratorPoint(), R: r}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: error marshalling zkp: %v", curve.Name(), err)
+	}
+	copy(b[len(b)-curve.ScalarByteLen():], nonCanonicalScalar(curve))
+	var zkp ZKPMsg[P, S]
+	err = zkp.UnmarshalBinary(b)
+	if !errors.Is(err, ErrNonCanonicalScalar) || !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("%s: expected ErrNonCanonicalScalar, instead got: %v", curve.Name(), err)
+	}
+}
+
+func TestNonCanonicalZKPR(t *testing.T) {
+	testNonCanonicalR[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testNonCanonicalR[*P256Point, *P256Scalar](t, P256Curve{})
+	testNonCanonicalR[*P384Point, *P384Scalar](t, P384Curve{})
+	testNonCanonicalR[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testNonCanonicalR[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testNonCanonicalR[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+
+	// a whole pass 3 message, through the strict parser and the stream reader
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	b := curve25519Codec{}.EncodePass3(msg3)
+	copy(b[len(b)-Curve25519ScalarSize:], nonCanonicalScalar[*Curve25519Point, *Curve25519Scalar](Curve25519Curve{}))
+	if _, err := ParseThreePassVariant3[*Curve25519Point, *Curve25519Scalar](b); !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("expected ErrNonCanonicalScalar, instead got: %v", err)
+	}
+	var streamed ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	if _, err := streamed.ReadFrom(bytes.NewReader(b)); !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("expected ErrNonCanonicalScalar from ReadFrom, instead got: %v", err)
+	}
+}
+
+func fuzzParse[P CurvePoint[P, S], S CurveScalar[S]](b []byte) {
+	_, _ = ParseThreePassVariant1[P, S](b)
+	_, _ = ParseThreePassVariant2[P, S](b)
+	_, _ = ParseThreePassVariant3[P, S](b)
+}
+
+func FuzzParseThreePassVariants(f *testing.F) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		f.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		f.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		f.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		f.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		f.Fatalf("error getting pass3: %v", err)
+	}
+	codec := curve25519Codec{}
+	f.Add(codec.EncodePass1(msg1))
+	f.Add(codec.EncodePass2(msg2))
+	f.Add(codec.EncodePass3(msg3))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		fuzzParse[*Curve25519Point, *Curve25519Scalar](b)
+		fuzzParse[*P256Point, *P256Scalar](b)
+		fuzzParse[*P384Point, *P384Scalar](b)
+		fuzzParse[*Secp256k1Point, *Secp256k1Scalar](b)
+		fuzzParse[*Ristretto255Point, *Ristretto255Scalar](b)
+		fuzzParse[*Ed448Point, *Ed448Scalar](b)
+	})
+}
+
+func testFromBytes[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	s, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("%s: error generating scalar: %v", curve.Name(), err)
+	}
+	p, err := curve.NewPoint().ScalarBaseMult(s)
+	if err != nil {
+		t.Fatalf("%s: error multiplying generator: %v", curve.Name(), err)
+	}
+	decodedP, err := PointFromBytes(curve, p.Bytes())
+	if err != nil || decodedP.Equal(p) != 1 {
+		t.Fatalf("%s: expected point to round trip, got %v", curve.Name(), err)
+	}
+	decodedS, err := ScalarFromBytes(curve, s.Bytes())
+	if err != nil || decodedS.Equal(s) != 1 {
+		t.Fatalf("%s: expected scalar to round trip, got %v", curve.Name(), err)
+	}
+
+	for name, b := range map[string][]byte{
+		"empty":     nil,
+		"truncated": p.Bytes()[1:],
+		"extended":  append(p.Bytes(), 0),
+		"garbage":   bytes.Repeat([]byte{0xff}, curve.PointByteLen()),
+	} {
+		if _, err := PointFromBytes(curve, b); !errors.Is(err, ErrInvalidPoint) || !errors.Is(err, ErrMalformedMessage) {
+			t.Fatalf("%s: expected ErrInvalidPoint for %s point, instead got: %v", curve.Name(), name, err)
+		}
+	}
+	for name, b := range map[string][]byte{
+		"empty":     nil,
+		"truncated": s.Bytes()[1:],
+		"extended":  append(s.Bytes(), 0),
+		"order":     nonCanonicalScalar(curve),
+	} {
+		if _, err := ScalarFromBytes(curve, b); !errors.Is(err, ErrNonCanonicalScalar) {
+			t.Fatalf("%s: expected ErrNonCanonicalScalar for %s scalar, instead got: %v", curve.Name(), name, err)
+		}
+	}
+}
+
+func TestFromBytes(t *testing.T) {
+	testFromBytes[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testFromBytes[*P256Point, *P256Scalar](t, P256Curve{})
+	testFromBytes[*P384Point, *P384Scalar](t, P384Curve{})
+	testFromBytes[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testFromBytes[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testFromBytes[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+
+	// y = p + 1 decodes with SetBytes but is not canonical
+	nonCanonical := append([]byte{0xee}, bytes.Repeat([]byte{0xff}, 30)...)
+	nonCanonical = append(nonCanonical, 0x7f)
+	if _, err := PointFromBytes[*Curve25519Point, *Curve25519Scalar](Curve25519Curve{}, nonCanonical); !errors.Is(err, ErrInvalidPoint) {
+		t.Fatalf("expected ErrInvalidPoint for a non-canonical point, instead got: %v", err)
+	}
+}
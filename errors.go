@@ -0,0 +1,103 @@
+package jpake
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAwaitingConfirmation    = errors.New("session key is withheld until key confirmation completes")
+	ErrSessionAlreadyConfirmed = errors.New("session has already been confirmed")
+	ErrMalformedMessage        = errors.New("malformed message")
+	ErrWeakSessionKey          = errors.New("derived shared key is degenerate")
+
+	// ErrInvalidConfig is returned by Config.Validate, and so by the Init
+	// and Restore functions, for a config which cannot run an exchange.
+	ErrInvalidConfig = errors.New("invalid config")
+
+	// ErrEmptyPassword is returned by the Init functions for an empty
+	// password unless the config allows one, see SetAllowEmptyPassword. An
+	// empty password derives a fixed s, so the exchange authenticates
+	// nothing: anyone may complete it.
+	ErrEmptyPassword = errors.New("empty password: the exchange would not be authenticated")
+
+	// ErrStage is returned when a method is called at the wrong stage.
+	ErrStage = errors.New("wrong protocol stage")
+	// ErrRole is returned when a method belonging to the other side of the
+	// exchange is called.
+	ErrRole = errors.New("wrong protocol role")
+	// ErrZKPVerification is returned when a received zero knowledge proof does
+	// not verify.
+	ErrZKPVerification = errors.New("zero knowledge proof verification failed")
+	// The reasons a single proof fails verification. Each matches
+	// ErrZKPVerification. A rejected message reports them only through
+	// errors.Is, and to an Observer implementing ZKPFailureObserver.
+	ErrZKPInfinityGenerator = fmt.Errorf("%w: generator at infinity", ErrZKPVerification)
+	ErrZKPInfinityY         = fmt.Errorf("%w: proven point at infinity", ErrZKPVerification)
+	ErrZKPInfinityT         = fmt.Errorf("%w: commitment at infinity", ErrZKPVerification)
+	ErrZKPSmallOrder        = fmt.Errorf("%w: point of small order", ErrZKPVerification)
+	ErrZKPZeroR             = fmt.Errorf("%w: zero response", ErrZKPVerification)
+	ErrZKPZeroChallenge     = fmt.Errorf("%w: zero challenge", ErrZKPVerification)
+	ErrZKPEquationMismatch  = fmt.Errorf("%w: verification equation does not hold", ErrZKPVerification)
+	// ErrPointAtInfinity is returned when a received or derived point is the
+	// point at infinity.
+	ErrPointAtInfinity = errors.New("point at infinity")
+	// ErrSmallOrderPoint is returned when a received point is in a small
+	// subgroup of a curve with a cofactor.
+	ErrSmallOrderPoint = errors.New("point of small order")
+	// ErrInvalidPoint is returned when a received point encoding has the
+	// wrong length, is not on the curve or is not canonical. It matches
+	// ErrMalformedMessage.
+	ErrInvalidPoint = fmt.Errorf("%w: invalid point", ErrMalformedMessage)
+	// ErrNonCanonicalScalar is returned when a received scalar is not the
+	// canonical encoding of a value reduced modulo the group order. It matches
+	// ErrMalformedMessage.
+	ErrNonCanonicalScalar = fmt.Errorf("%w: non-canonical scalar", ErrMalformedMessage)
+	// ErrReplayedMessage is returned when a message already processed in
+	// this exchange is received again, or the peer echoes back our own
+	// points.
+	ErrReplayedMessage = errors.New("replayed message")
+	// ErrUserIDCollision is returned when the peer uses our own user ID.
+	ErrUserIDCollision = errors.New("peer user id matches our own")
+	// ErrUserIDLength is returned when the peer's user ID is empty or longer
+	// than the config allows, see SetMaxUserIDLength.
+	ErrUserIDLength = errors.New("peer user id is empty or too long")
+	// ErrSessionConfirmation is returned when the peer's confirmation MAC does
+	// not match.
+	ErrSessionConfirmation = errors.New("cannot confirm session")
+	// ErrPasswordMismatch is returned when the peer's confirmation MAC is well
+	// formed but does not match, which is what differing passwords produce. A
+	// MAC corrupted in transit without changing its length is
+	// indistinguishable from this. It matches ErrSessionConfirmation.
+	ErrPasswordMismatch = fmt.Errorf("%w: passwords do not match", ErrSessionConfirmation)
+	// ErrMalformedConfirmation is returned when the peer's confirmation is not
+	// the length of a MAC. It matches ErrSessionConfirmation and
+	// ErrMalformedMessage.
+	ErrMalformedConfirmation = fmt.Errorf("%w: %w", ErrSessionConfirmation, ErrMalformedMessage)
+	// ErrConfirmationUnavailable is returned when a confirmation function set
+	// with SetConfirmationFn needs the shared point, which a restored exchange
+	// does not hold. It matches ErrSessionConfirmation.
+	ErrConfirmationUnavailable = fmt.Errorf("%w: confirmation cannot be computed without the shared point", ErrSessionConfirmation)
+	// ErrConfirmationTimeout is returned by ConfirmWithTimeout when the peer
+	// does not complete key confirmation before the deadline.
+	ErrConfirmationTimeout = errors.New("timed out awaiting key confirmation")
+)
+
+// rejectedMessageError reports a received message that failed validation.
+// Its text is the same for every cause so that nothing about the failure is
+// revealed by the message alone, while errors.Is still matches the cause.
+type rejectedMessageError struct {
+	cause error
+}
+
+func rejected(cause error) error {
+	return &rejectedMessageError{cause: cause}
+}
+
+func (e *rejectedMessageError) Error() string {
+	return "could not verify the validity of the received message"
+}
+
+func (e *rejectedMessageError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,52 @@
+package jpake_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/joshbuddy/jpake"
+)
+
+// ExampleThreePassJpake runs a complete exchange, including both key
+// confirmations, between two goroutines connected by net.Pipe, as it would
+// run over a TCP connection.
+func ExampleThreePassJpake() {
+	initiatorConn, responderConn := net.Pipe()
+	defer initiatorConn.Close()
+	defer responderConn.Close()
+
+	type result struct {
+		key []byte
+		err error
+	}
+	responder := make(chan result)
+	go func() {
+		jp, err := jpake.InitThreePassJpake(jpake.Responder, []byte("server"), []byte("correct horse"))
+		if err != nil {
+			responder <- result{nil, err}
+			return
+		}
+		key, err := jpake.RunResponder(responderConn, jp)
+		responder <- result{key, err}
+	}()
+
+	jp, err := jpake.InitThreePassJpake(jpake.Initiator, []byte("client"), []byte("correct horse"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	initiatorKey, err := jpake.RunInitiator(initiatorConn, jp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := <-responder
+	if r.err != nil {
+		log.Fatal(r.err)
+	}
+	fmt.Println("confirmed:", jp.Done())
+	fmt.Println("keys match:", bytes.Equal(initiatorKey, r.key))
+	// Output:
+	// confirmed: true
+	// keys match: true
+}
@@ -0,0 +1,57 @@
+package jpake_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joshbuddy/jpake"
+)
+
+// TestExternalKeyAccess runs an exchange using only the exported API.
+func TestExternalKeyAccess(t *testing.T) {
+	jpake1, err := jpake.InitThreePassJpake(jpake.Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := jpake.InitThreePassJpake(jpake.Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := jpake1.Key(); err == nil {
+		t.Fatalf("expected error getting key before the exchange, instead got nil")
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	key1, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+	key2, err := jpake2.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if len(key1) == 0 || !bytes.Equal(key1, key2) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, key2)
+	}
+}
@@ -0,0 +1,153 @@
+package jpake
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Frame type tags for the messages exchanged in the three pass variant.
+const (
+	FramePass1 byte = iota + 1
+	FramePass2
+	FramePass3
+	FrameConfirmation1
+	FrameConfirmation2
+	// FrameAbort may be sent at any stage to report that the exchange failed,
+	// see AbortMessage.
+	FrameAbort
+)
+
+var ErrUnexpectedMessage = errors.New("unexpected message for current stage")
+
+// Frame is a typed, encoded protocol message as carried by a transport.
+type Frame struct {
+	Type byte
+	Body []byte
+}
+
+type frameRoute struct {
+	stage   Stage
+	typeTag byte
+}
+
+// processFrameAt dispatches every (stage, message type) pairing that may
+// legally be received to the method which processes it. ok is false for any
+// other pairing.
+func (jp *ThreePassJpake[P, S]) processFrameAt(typeTag byte, body []byte) (reply *Frame, ok bool, err error) {
+	switch (frameRoute{jp.Stage, typeTag}) {
+	case frameRoute{StageAwaitingPass1, FramePass1}:
+		reply, err = jp.processPass1Frame(body)
+	case frameRoute{StageAwaitingPass2, FramePass2}:
+		reply, err = jp.processPass2Frame(body)
+	case frameRoute{StageAwaitingPass3, FramePass3}:
+		reply, err = jp.processPass3Frame(body)
+	case frameRoute{StageAwaitingConfirmation1, FrameConfirmation1}:
+		reply, err = jp.processConfirmation1Frame(body)
+	case frameRoute{StageAwaitingConfirmation2, FrameConfirmation2}:
+		err = jp.ProcessSessionConfirmation2(body)
+	default:
+		return nil, false, nil
+	}
+	return reply, true, err
+}
+
+func (jp *ThreePassJpake[P, S]) processPass1Frame(body []byte) (*Frame, error) {
+	msg, err := jp.wireCodec().DecodePass1(body)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := jp.GetPass2Message(*msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FramePass2, Body: jp.wireCodec().EncodePass2(reply)}, nil
+}
+
+func (jp *ThreePassJpake[P, S]) processPass2Frame(body []byte) (*Frame, error) {
+	msg, err := jp.wireCodec().DecodePass2(body)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := jp.GetPass3Message(*msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FramePass3, Body: jp.wireCodec().EncodePass3(reply)}, nil
+}
+
+func (jp *ThreePassJpake[P, S]) processPass3Frame(body []byte) (*Frame, error) {
+	msg, err := jp.wireCodec().DecodePass3(body)
+	if err != nil {
+		return nil, err
+	}
+	confirm1, err := jp.ProcessPass3Message(*msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FrameConfirmation1, Body: confirm1}, nil
+}
+
+func (jp *ThreePassJpake[P, S]) processConfirmation1Frame(body []byte) (*Frame, error) {
+	confirm2, err := jp.ProcessSessionConfirmation1(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FrameConfirmation2, Body: confirm2}, nil
+}
+
+// Pass1Frame returns the encoded first message for the initiator.
+func (jp *ThreePassJpake[P, S]) Pass1Frame() (*Frame, error) {
+	msg, err := jp.Pass1Message()
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FramePass1, Body: jp.wireCodec().EncodePass1(msg)}, nil
+}
+
+// wireCodec is the codec frames are encoded with.
+func (jp *ThreePassJpake[P, S]) wireCodec() Codec[P, S] {
+	return Codec[P, S]{Points: jp.config.pointEncoding}
+}
+
+// ProcessFrame decodes and processes a received frame, returning the frame to
+// send in reply. The response is nil once no further messages are expected.
+func (jp *ThreePassJpake[P, S]) ProcessFrame(typeTag byte, body []byte) (*Frame, error) {
+	if typeTag == FrameAbort {
+		msg, err := DecodeAbortMessage(body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &PeerAbortedError{Reason: msg.Reason}
+	}
+	digest, err := jp.checkFrameReplay(typeTag, body)
+	if err != nil {
+		return nil, err
+	}
+	reply, ok, err := jp.processFrameAt(typeTag, body)
+	if !ok {
+		return nil, fmt.Errorf("%w: type %d at stage %s", ErrUnexpectedMessage, typeTag, jp.Stage)
+	}
+	if err == nil {
+		jp.inboundFrames = append(jp.inboundFrames, digest)
+	}
+	return reply, err
+}
+
+// checkFrameReplay returns the digest of a received frame, or
+// ErrReplayedMessage if ProcessFrame has already processed the same frame.
+// It runs before the frame is routed by stage, so that a re-delivery is
+// reported as such rather than as an unexpected message.
+func (jp *ThreePassJpake[P, S]) checkFrameReplay(typeTag byte, body []byte) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	h.Write([]byte{typeTag})
+	h.Write(body)
+	var digest [sha256.Size]byte
+	h.Sum(digest[:0])
+	for _, d := range jp.inboundFrames {
+		if d == digest {
+			return digest, fmt.Errorf("frame type %d at stage %s was already processed: %w", typeTag, jp.Stage, ErrReplayedMessage)
+		}
+	}
+	return digest, nil
+}
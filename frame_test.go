@@ -0,0 +1,254 @@
+package jpake
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestJpake3PassFrames(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []*ThreePassJpake[*Curve25519Point, *Curve25519Scalar]{jpake2, jpake1}
+	for i := 0; frame != nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+	}
+	if jpake1.Stage != 7 || jpake2.Stage != 8 {
+		t.Fatalf("expected stages 7 and 8, got %d and %d", jpake1.Stage, jpake2.Stage)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassFrameUnexpectedMessage(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	// the initiator never receives a pass1 message
+	if _, err := jpake1.ProcessFrame(frame.Type, frame.Body); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Fatalf("expected ErrUnexpectedMessage, instead got: %v", err)
+	}
+	if _, err := jpake2.ProcessFrame(FrameConfirmation2, []byte("confirm")); !errors.Is(err, ErrUnexpectedMessage) {
+		t.Fatalf("expected ErrUnexpectedMessage, instead got: %v", err)
+	}
+	if jpake2.Stage != 2 {
+		t.Fatalf("expected stage to remain 2, was %d", jpake2.Stage)
+	}
+}
+
+func TestJpake3PassFrameReplay(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame1, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	frame2, err := jpake2.ProcessFrame(frame1.Type, frame1.Body)
+	if err != nil {
+		t.Fatalf("error processing pass1 frame: %v", err)
+	}
+	frame3, err := jpake1.ProcessFrame(frame2.Type, frame2.Body)
+	if err != nil {
+		t.Fatalf("error processing pass2 frame: %v", err)
+	}
+	confirm1, err := jpake2.ProcessFrame(frame3.Type, frame3.Body)
+	if err != nil {
+		t.Fatalf("error processing pass3 frame: %v", err)
+	}
+	// the responder has moved past the stage a pass 1 frame is routed at
+	if _, err := jpake2.ProcessFrame(frame1.Type, frame1.Body); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 1 frame, instead got: %v", err)
+	}
+	if _, err := jpake2.ProcessFrame(frame3.Type, frame3.Body); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 3 frame, instead got: %v", err)
+	}
+	if jpake2.Stage != StageAwaitingConfirmation2 {
+		t.Fatalf("expected stage %s, was %s", StageAwaitingConfirmation2, jpake2.Stage)
+	}
+	confirm2, err := jpake1.ProcessFrame(confirm1.Type, confirm1.Body)
+	if err != nil {
+		t.Fatalf("error processing confirmation1 frame: %v", err)
+	}
+	if _, err := jpake1.ProcessFrame(frame2.Type, frame2.Body); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 2 frame, instead got: %v", err)
+	}
+	if _, err := jpake1.ProcessFrame(confirm1.Type, confirm1.Body); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed confirmation1 frame, instead got: %v", err)
+	}
+	if _, err := jpake2.ProcessFrame(confirm2.Type, confirm2.Body); err != nil {
+		t.Fatalf("error processing confirmation2 frame: %v", err)
+	}
+
+	// a different pass 1 frame at the wrong stage is still unexpected
+	other, err := InitThreePassJpake(Initiator, []byte("three"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init other: %v", err)
+	}
+	otherFrame1, err := other.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	if _, err := jpake2.ProcessFrame(otherFrame1.Type, otherFrame1.Body); !errors.Is(err, ErrUnexpectedMessage) || errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrUnexpectedMessage, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassFrameTruncated(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	if _, err := jpake2.ProcessFrame(frame.Type, frame.Body[:len(frame.Body)-1]); err == nil {
+		t.Fatalf("expected error processing truncated frame, instead got nil")
+	}
+}
+
+func TestJpake3PassFrameVariantMismatch(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if jpake2.Variant() != VariantThreePass {
+		t.Fatalf("expected three pass variant, was %d", jpake2.Variant())
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	frame.Body[0] = byte(VariantTwoPass)
+	if _, err := jpake2.ProcessFrame(frame.Type, frame.Body); !errors.Is(err, ErrVariantMismatch) {
+		t.Fatalf("expected ErrVariantMismatch, instead got: %v", err)
+	}
+	if jpake2.Stage != 2 {
+		t.Fatalf("expected stage to remain 2, was %d", jpake2.Stage)
+	}
+}
+
+func TestJpake3PassFrameAbort(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	if _, err := jpake1.Pass1Frame(); err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	abort := NewAbortMessage(rejected(ErrZKPVerification)).Frame()
+	_, err = jpake1.ProcessFrame(abort.Type, abort.Body)
+	var aborted *PeerAbortedError
+	if !errors.As(err, &aborted) || aborted.Reason != AbortRejectedMessage {
+		t.Fatalf("expected PeerAbortedError for a rejected message, instead got: %v", err)
+	}
+	if err.Error() != "peer aborted the exchange: rejected message" {
+		t.Fatalf("unexpected error text %q", err.Error())
+	}
+	if _, err := jpake1.ProcessFrame(FrameAbort, nil); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage for an empty abort, instead got: %v", err)
+	}
+}
+
+func TestFramesPointEncoding(t *testing.T) {
+	handshake := func(enc1, enc2 *PointEncoding) (*ThreePassJpake[*P256Point, *P256Scalar], *ThreePassJpake[*P256Point, *P256Scalar], *Frame, error) {
+		jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Initiator, []byte("one"), []byte("password"), P256Curve{}, NewConfig().SetPointEncoding(enc1))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Responder, []byte("two"), []byte("password"), P256Curve{}, NewConfig().SetPointEncoding(enc2))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		pass1, err := jpake1.Pass1Frame()
+		if err != nil {
+			t.Fatalf("error getting pass1 frame: %v", err)
+		}
+		sides := []*ThreePassJpake[*P256Point, *P256Scalar]{jpake2, jpake1}
+		frame := pass1
+		for i := 0; frame != nil; i++ {
+			if frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		return jpake1, jpake2, pass1, nil
+	}
+	var lengths []int
+	for _, enc := range []*PointEncoding{nil, P256Uncompressed} {
+		jpake1, jpake2, pass1, err := handshake(enc, enc)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+		if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+			t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+		}
+		transcript1, err := jpake1.Transcript()
+		if err != nil {
+			t.Fatalf("error getting transcript1: %v", err)
+		}
+		transcript2, err := jpake2.Transcript()
+		if err != nil {
+			t.Fatalf("error getting transcript2: %v", err)
+		}
+		if !bytes.Equal(transcript1, transcript2) {
+			t.Fatalf("expected equal transcripts, got %x and %x", transcript1, transcript2)
+		}
+		// the transcript commits to the encoding of Bytes, whatever the wire
+		msg1, err := Codec[*P256Point, *P256Scalar]{Points: enc}.DecodePass1(pass1.Body)
+		if err != nil {
+			t.Fatalf("error decoding pass1: %v", err)
+		}
+		if !bytes.Equal(Codec[*P256Point, *P256Scalar]{}.EncodePass1(msg1), jpake2.transcript[0]) {
+			t.Fatalf("expected the transcript to hold the canonical encoding of pass 1")
+		}
+		lengths = append(lengths, len(pass1.Body))
+	}
+	// two points and two ZKP commitments, each 32 bytes longer uncompressed
+	if lengths[1] != lengths[0]+4*P256ScalarSize {
+		t.Fatalf("expected the uncompressed pass 1 to be %d bytes longer, got %d and %d", 4*P256ScalarSize, lengths[0], lengths[1])
+	}
+
+	if _, _, _, err := handshake(P256Uncompressed, nil); !errors.Is(err, ErrInvalidPoint) {
+		t.Fatalf("expected ErrInvalidPoint for mismatched encodings, instead got: %v", err)
+	}
+	if _, _, _, err := handshake(nil, P256Uncompressed); !errors.Is(err, ErrInvalidPoint) {
+		t.Fatalf("expected ErrInvalidPoint for mismatched encodings, instead got: %v", err)
+	}
+}
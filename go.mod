@@ -2,4 +2,13 @@ module github.com/joshbuddy/jpake
 
 go 1.20
 
-require filippo.io/edwards25519 v1.0.0
+require (
+	filippo.io/edwards25519 v1.0.0
+	filippo.io/nistec v0.0.3
+	github.com/cloudflare/circl v1.3.3
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
+	github.com/gtank/ristretto255 v0.1.2
+	golang.org/x/crypto v0.14.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,136 @@
+package jpake
+
+// Points and scalars are gob encoded as their Bytes and decoded as by
+// PointFromBytes and ScalarFromBytes, so a decoded point is always a valid
+// element of the curve. The messages are gob encoded as their MarshalBinary
+// encoding.
+
+func gobDecodePoint[P CurvePoint[P, S], S CurveScalar[S]](p P, b []byte) error {
+	_, err := setPoint(p, b)
+	return err
+}
+
+func gobDecodeScalar[S CurveScalar[S]](s S, b []byte) error {
+	_, err := setCanonicalScalar(s, b)
+	return err
+}
+
+func (p *Curve25519Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *Curve25519Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*Curve25519Point, *Curve25519Scalar](p, b)
+}
+
+func (s *Curve25519Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *Curve25519Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (p *P256Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *P256Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*P256Point, *P256Scalar](p, b)
+}
+
+func (s *P256Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *P256Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (p *P384Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *P384Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*P384Point, *P384Scalar](p, b)
+}
+
+func (s *P384Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *P384Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (p *Secp256k1Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *Secp256k1Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*Secp256k1Point, *Secp256k1Scalar](p, b)
+}
+
+func (s *Secp256k1Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *Secp256k1Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (p *Ristretto255Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *Ristretto255Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*Ristretto255Point, *Ristretto255Scalar](p, b)
+}
+
+func (s *Ristretto255Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *Ristretto255Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (p *Ed448Point) GobEncode() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+func (p *Ed448Point) GobDecode(b []byte) error {
+	return gobDecodePoint[*Ed448Point, *Ed448Scalar](p, b)
+}
+
+func (s *Ed448Scalar) GobEncode() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *Ed448Scalar) GobDecode(b []byte) error {
+	return gobDecodeScalar(s, b)
+}
+
+func (msg ThreePassVariant1[P, S]) GobEncode() ([]byte, error) {
+	return msg.MarshalBinary()
+}
+
+func (msg *ThreePassVariant1[P, S]) GobDecode(b []byte) error {
+	return msg.UnmarshalBinary(b)
+}
+
+func (msg ThreePassVariant2[P, S]) GobEncode() ([]byte, error) {
+	return msg.MarshalBinary()
+}
+
+func (msg *ThreePassVariant2[P, S]) GobDecode(b []byte) error {
+	return msg.UnmarshalBinary(b)
+}
+
+func (msg ThreePassVariant3[P, S]) GobEncode() ([]byte, error) {
+	return msg.MarshalBinary()
+}
+
+func (msg *ThreePassVariant3[P, S]) GobDecode(b []byte) error {
+	return msg.UnmarshalBinary(b)
+}
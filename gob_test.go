@@ -0,0 +1,87 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestJpake3PassGob(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg2); err != nil {
+		t.Fatalf("error encoding pass2: %v", err)
+	}
+	var decoded ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("error decoding pass2: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(decoded); err != nil {
+		t.Fatalf("error getting pass3 from decoded pass2: %v", err)
+	}
+}
+
+func TestGobPointsAndScalars(t *testing.T) {
+	type session struct {
+		X1  *Curve25519Scalar
+		X1G *Curve25519Point
+		B   *P256Point
+		S   *Secp256k1Scalar
+		A   *Ristretto255Point
+	}
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	s, err := Secp256k1Curve{}.NewScalarFromSecret(1, []byte("secret"))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	in := session{
+		X1:  jpake1.X1,
+		X1G: msg1.X1G,
+		B:   P256Curve{}.NewGeneratorPoint(),
+		S:   s,
+		A:   Ristretto255Curve{}.NewGeneratorPoint(),
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	var out session
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if out.X1.Equal(in.X1) != 1 || out.X1G.Equal(in.X1G) != 1 || out.B.Equal(in.B) != 1 ||
+		out.S.Equal(in.S) != 1 || out.A.Equal(in.A) != 1 {
+		t.Fatalf("expected values to round trip")
+	}
+
+	notOnCurve := make([]byte, 32)
+	notOnCurve[0] = 2
+	if err := new(Curve25519Point).GobDecode(notOnCurve); err == nil {
+		t.Fatalf("expected error decoding a point not on the curve, instead got nil")
+	}
+	if err := new(P256Point).GobDecode(notOnCurve); err == nil {
+		t.Fatalf("expected error decoding a point of the wrong size, instead got nil")
+	}
+}
@@ -0,0 +1,221 @@
+package jpake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Protocol messages are represented in JSON with every byte field, including
+// user IDs, as an unpadded base64url string. Points and scalars are validated
+// as by PointFromBytes and ScalarFromBytes when decoded.
+
+type zkpJSON struct {
+	T string
+	R string
+}
+
+type pass1JSON struct {
+	Version uint8
+	UserID  string
+	X1G     string
+	X2G     string
+	X1ZKP   zkpJSON
+	X2ZKP   zkpJSON
+}
+
+type pass2JSON struct {
+	UserID string
+	X3G    string
+	X4G    string
+	B      string
+	XsZKP  zkpJSON
+	X3ZKP  zkpJSON
+	X4ZKP  zkpJSON
+}
+
+type pass3JSON struct {
+	A     string
+	XsZKP zkpJSON
+}
+
+func jsonCodec[P CurvePoint[P, S], S CurveScalar[S]]() Codec[P, S] {
+	return Codec[P, S]{Encoding: EncodingBase64URL}
+}
+
+func (c Codec[P, S]) encodeZKPJSON(zkp ZKPMsg[P, S]) zkpJSON {
+	return zkpJSON{T: string(c.encodePoint(zkp.T)), R: string(c.encodeScalar(zkp.R))}
+}
+
+func (c Codec[P, S]) decodeZKPJSON(name string, j zkpJSON) (ZKPMsg[P, S], error) {
+	t, err := c.decodePoint(name+".T", []byte(j.T))
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	r, err := c.decodeScalar(name+".R", []byte(j.R))
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	return ZKPMsg[P, S]{T: t, R: r}, nil
+}
+
+func (zkp ZKPMsg[P, S]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCodec[P, S]().encodeZKPJSON(zkp))
+}
+
+func (zkp *ZKPMsg[P, S]) UnmarshalJSON(b []byte) error {
+	var j zkpJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	decoded, err := jsonCodec[P, S]().decodeZKPJSON("ZKP", j)
+	if err != nil {
+		return err
+	}
+	*zkp = decoded
+	return nil
+}
+
+func (msg ThreePassVariant1[P, S]) MarshalJSON() ([]byte, error) {
+	c := jsonCodec[P, S]()
+	return json.Marshal(pass1JSON{
+		Version: msg.Version,
+		UserID:  base64.RawURLEncoding.EncodeToString(msg.UserID),
+		X1G:     string(c.encodePoint(msg.X1G)),
+		X2G:     string(c.encodePoint(msg.X2G)),
+		X1ZKP:   c.encodeZKPJSON(msg.X1ZKP),
+		X2ZKP:   c.encodeZKPJSON(msg.X2ZKP),
+	})
+}
+
+func (msg *ThreePassVariant1[P, S]) UnmarshalJSON(b []byte) error {
+	var j pass1JSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	c := jsonCodec[P, S]()
+	decoded := ThreePassVariant1[P, S]{Version: j.Version}
+	var err error
+	if decoded.UserID, err = base64.RawURLEncoding.DecodeString(j.UserID); err != nil {
+		return err
+	}
+	if decoded.X1G, err = c.decodePoint("X1G", []byte(j.X1G)); err != nil {
+		return err
+	}
+	if decoded.X2G, err = c.decodePoint("X2G", []byte(j.X2G)); err != nil {
+		return err
+	}
+	if decoded.X1ZKP, err = c.decodeZKPJSON("X1ZKP", j.X1ZKP); err != nil {
+		return err
+	}
+	if decoded.X2ZKP, err = c.decodeZKPJSON("X2ZKP", j.X2ZKP); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
+
+func (msg ThreePassVariant2[P, S]) MarshalJSON() ([]byte, error) {
+	c := jsonCodec[P, S]()
+	return json.Marshal(pass2JSON{
+		UserID: base64.RawURLEncoding.EncodeToString(msg.UserID),
+		X3G:    string(c.encodePoint(msg.X3G)),
+		X4G:    string(c.encodePoint(msg.X4G)),
+		B:      string(c.encodePoint(msg.B)),
+		XsZKP:  c.encodeZKPJSON(msg.XsZKP),
+		X3ZKP:  c.encodeZKPJSON(msg.X3ZKP),
+		X4ZKP:  c.encodeZKPJSON(msg.X4ZKP),
+	})
+}
+
+func (msg *ThreePassVariant2[P, S]) UnmarshalJSON(b []byte) error {
+	var j pass2JSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	c := jsonCodec[P, S]()
+	var decoded ThreePassVariant2[P, S]
+	var err error
+	if decoded.UserID, err = base64.RawURLEncoding.DecodeString(j.UserID); err != nil {
+		return err
+	}
+	if decoded.X3G, err = c.decodePoint("X3G", []byte(j.X3G)); err != nil {
+		return err
+	}
+	if decoded.X4G, err = c.decodePoint("X4G", []byte(j.X4G)); err != nil {
+		return err
+	}
+	if decoded.B, err = c.decodePoint("B", []byte(j.B)); err != nil {
+		return err
+	}
+	if decoded.XsZKP, err = c.decodeZKPJSON("XsZKP", j.XsZKP); err != nil {
+		return err
+	}
+	if decoded.X3ZKP, err = c.decodeZKPJSON("X3ZKP", j.X3ZKP); err != nil {
+		return err
+	}
+	if decoded.X4ZKP, err = c.decodeZKPJSON("X4ZKP", j.X4ZKP); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
+
+func (msg ThreePassVariant3[P, S]) MarshalJSON() ([]byte, error) {
+	c := jsonCodec[P, S]()
+	return json.Marshal(pass3JSON{
+		A:     string(c.encodePoint(msg.A)),
+		XsZKP: c.encodeZKPJSON(msg.XsZKP),
+	})
+}
+
+func (msg *ThreePassVariant3[P, S]) UnmarshalJSON(b []byte) error {
+	var j pass3JSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	c := jsonCodec[P, S]()
+	var decoded ThreePassVariant3[P, S]
+	var err error
+	if decoded.A, err = c.decodePoint("A", []byte(j.A)); err != nil {
+		return err
+	}
+	if decoded.XsZKP, err = c.decodeZKPJSON("XsZKP", j.XsZKP); err != nil {
+		return err
+	}
+	*msg = decoded
+	return nil
+}
+
+func (p *Curve25519Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(p.Bytes()))
+}
+
+func (p *Curve25519Point) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	decoded, err := jsonCodec[*Curve25519Point, *Curve25519Scalar]().decodePoint("point", []byte(s))
+	if err != nil {
+		return err
+	}
+	*p = *decoded
+	return nil
+}
+
+func (s *Curve25519Scalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(s.Bytes()))
+}
+
+func (s *Curve25519Scalar) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	decoded, err := jsonCodec[*Curve25519Point, *Curve25519Scalar]().decodeScalar("scalar", []byte(str))
+	if err != nil {
+		return err
+	}
+	*s = *decoded
+	return nil
+}
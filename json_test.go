@@ -0,0 +1,100 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJpake3PassJSON(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	b, err := json.Marshal(msg1)
+	if err != nil {
+		t.Fatalf("error marshalling pass1: %v", err)
+	}
+	var decoded1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if err := json.Unmarshal(b, &decoded1); err != nil {
+		t.Fatalf("error unmarshalling pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(decoded1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	b, err = json.Marshal(msg2)
+	if err != nil {
+		t.Fatalf("error marshalling pass2: %v", err)
+	}
+	var decoded2 ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	if err := json.Unmarshal(b, &decoded2); err != nil {
+		t.Fatalf("error unmarshalling pass2: %v", err)
+	}
+	if !bytes.Equal(Codec[*Curve25519Point, *Curve25519Scalar]{}.EncodePass2(&decoded2), Codec[*Curve25519Point, *Curve25519Scalar]{}.EncodePass2(msg2)) {
+		t.Fatalf("expected pass2 to round trip")
+	}
+	msg3, err := jpake1.GetPass3Message(decoded2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	b, err = json.Marshal(msg3)
+	if err != nil {
+		t.Fatalf("error marshalling pass3: %v", err)
+	}
+	var decoded3 ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	if err := json.Unmarshal(b, &decoded3); err != nil {
+		t.Fatalf("error unmarshalling pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(decoded3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassJSONInvalidPoint(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	b, err := json.Marshal(msg1)
+	if err != nil {
+		t.Fatalf("error marshalling pass1: %v", err)
+	}
+	// y = 2 does not correspond to a point on the curve
+	notOnCurve := make([]byte, 32)
+	notOnCurve[0] = 2
+	invalid := strings.Replace(string(b), base64.RawURLEncoding.EncodeToString(msg1.X1G.Bytes()), base64.RawURLEncoding.EncodeToString(notOnCurve), 1)
+	var decoded1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if err := json.Unmarshal([]byte(invalid), &decoded1); err == nil || !strings.Contains(err.Error(), "invalid X1G") {
+		t.Fatalf("expected invalid X1G error, instead got: %v", err)
+	}
+	var p Curve25519Point
+	if err := json.Unmarshal([]byte(`"`+base64.RawURLEncoding.EncodeToString(notOnCurve)+`"`), &p); err == nil {
+		t.Fatalf("expected error unmarshalling point not on curve, instead got nil")
+	}
+}
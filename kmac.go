@@ -0,0 +1,46 @@
+package jpake
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// kmac256 implements KMAC256 as specified in NIST SP 800-185 section 4,
+// returning l bytes of output.
+func kmac256(key, msg []byte, l int, customization []byte) []byte {
+	const rate = 136
+	h := sha3.NewCShake256([]byte("KMAC"), customization)
+	encodedKey := append(leftEncode(uint64(len(key))*8), key...)
+	padded := append(leftEncode(rate), encodedKey...)
+	if rem := len(padded) % rate; rem != 0 {
+		padded = append(padded, make([]byte, rate-rem)...)
+	}
+	h.Write(padded)
+	h.Write(msg)
+	h.Write(rightEncode(uint64(l) * 8))
+	out := make([]byte, l)
+	h.Read(out)
+	return out
+}
+
+func leftEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append([]byte{byte(len(b))}, b...)
+}
+
+func rightEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append(b, byte(len(b)))
+}
+
+// encodeUint returns the minimal big-endian encoding of x, using at least one
+// byte.
+func encodeUint(x uint64) []byte {
+	b := binary.BigEndian.AppendUint64(nil, x)
+	i := 0
+	for i < len(b)-1 && b[i] == 0 {
+		i++
+	}
+	return b[i:]
+}
@@ -0,0 +1,17 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKMAC256(t *testing.T) {
+	// NIST SP 800-185 KMAC sample #4
+	key, _ := hex.DecodeString("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+	expected, _ := hex.DecodeString("20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd")
+	out := kmac256(key, []byte{0x00, 0x01, 0x02, 0x03}, 64, []byte("My Tagged Application"))
+	if !bytes.Equal(out, expected) {
+		t.Fatalf("expected kmac %x, got %x", expected, out)
+	}
+}
@@ -0,0 +1,60 @@
+package jpake
+
+import (
+	"crypto/sha1"
+	"errors"
+)
+
+// OpenSSLCompatConfig returns a config following OpenSSL's J-PAKE
+// implementation (crypto/jpake, removed in OpenSSL 1.1.0). ZKP challenges are
+// c = SHA-1(g || g^v || g^x || name), each item prefixed with its length as
+// two big endian bytes and group elements hashed as BN_bn2bin would, without
+// leading zero bytes. Keys are confirmed as by JPAKE_STEP3A and JPAKE_STEP3B:
+// the first confirmation is SHA-1(SHA-1(K)) and the reply SHA-1(K), K hashed
+// in the same way. SHA-1 is also used wherever the config hashes.
+//
+// OpenSSL ran J-PAKE over a prime order subgroup of Z_p^*, so a peer linking
+// it only interoperates given a Curve for the same group. The session key is
+// still derived by this package; OpenSSL returned K itself.
+func OpenSSLCompatConfig() *Config {
+	return NewConfig().SetHashFn(sha1HashFn).SetZKPChallengeFn(openSSLZKPChallenge).SetConfirmationFn(openSSLConfirmation)
+}
+
+var errOpenSSLChallengeLength = errors.New("challenge item longer than 65535 bytes")
+
+// openSSLZKPChallenge serializes challenge inputs as OpenSSL's zkp_hash did.
+func openSSLZKPChallenge(generator, t, y, userID []byte) ([]byte, error) {
+	var b []byte
+	for i, item := range [][]byte{generator, t, y, userID} {
+		if i < 3 {
+			// hashbn hashes the minimal big endian encoding
+			for len(item) > 0 && item[0] == 0 {
+				item = item[1:]
+			}
+		}
+		if len(item) > 0xffff {
+			return nil, errOpenSSLChallengeLength
+		}
+		b = append(b, byte(len(item)>>8), byte(len(item)))
+		b = append(b, item...)
+	}
+	return b, nil
+}
+
+// openSSLConfirmation computes OpenSSL's hhk (STEP3A) when first and its hk
+// (STEP3B) otherwise, both from quickhashbn(K).
+func openSSLConfirmation(k []byte, first bool) []byte {
+	for len(k) > 0 && k[0] == 0 {
+		k = k[1:]
+	}
+	hk := sha1HashFn(append([]byte{byte(len(k) >> 8), byte(len(k))}, k...))
+	if first {
+		return sha1HashFn(hk)
+	}
+	return hk
+}
+
+func sha1HashFn(in []byte) []byte {
+	hash := sha1.Sum(in)
+	return hash[:]
+}
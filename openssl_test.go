@@ -0,0 +1,150 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestOpenSSLZKPChallenge(t *testing.T) {
+	config := OpenSSLCompatConfig()
+	chal, err := config.zkpChallenge([]byte{0, 1, 2}, []byte{3}, []byte{0, 4}, []byte("alice"))
+	if err != nil {
+		t.Fatalf("error computing challenge: %v", err)
+	}
+	// SHA-1(00 02 0102 | 00 01 03 | 00 01 04 | 00 05 "alice")
+	expected, _ := hex.DecodeString("d5ce50130a777498a89225c334f4a78d5ba8ba66")
+	if !bytes.Equal(chal, expected) {
+		t.Fatalf("expected challenge %x, got %x", expected, chal)
+	}
+	if _, err := config.zkpChallenge(nil, nil, nil, make([]byte, 0x10000)); err == nil {
+		t.Fatalf("expected an error for an over long user ID")
+	}
+}
+
+func TestOpenSSLConfirmation(t *testing.T) {
+	// leading zero bytes of K are dropped, as by BN_bn2bin
+	k, _ := hex.DecodeString("00000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20")
+	// hk = SHA-1(00 20 | 0102..20), hhk = SHA-1(hk)
+	hk, _ := hex.DecodeString("fcaf1527c5bc65f80a42fb276d4756f03f740f67")
+	hhk, _ := hex.DecodeString("971f1c5763efb40977982f2eef39c80083bb28e9")
+	if got := openSSLConfirmation(k, true); !bytes.Equal(got, hhk) {
+		t.Fatalf("expected first confirmation %x, got %x", hhk, got)
+	}
+	if got := openSSLConfirmation(k, false); !bytes.Equal(got, hk) {
+		t.Fatalf("expected reply confirmation %x, got %x", hk, got)
+	}
+}
+
+func TestJpake3PassOpenSSLCompat(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), OpenSSLCompatConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), OpenSSLCompatConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	k := jpake1.sharedPoint.Bytes()
+	if expected := openSSLConfirmation(k, true); !bytes.Equal(conf1, expected) {
+		t.Fatalf("expected the responder to send SHA-1(SHA-1(K)) %x, got %x", expected, conf1)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if expected := openSSLConfirmation(k, false); !bytes.Equal(conf2, expected) {
+		t.Fatalf("expected the initiator to reply SHA-1(K) %x, got %x", expected, conf2)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+
+	// a peer using the default challenge rejects the OpenSSL layout
+	jpake1, err = InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), OpenSSLCompatConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err = InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err = jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); err == nil {
+		t.Fatalf("expected the default config to reject OpenSSL layout proofs")
+	}
+}
+
+func TestJpake3PassOpenSSLCompatRestored(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), OpenSSLCompatConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), OpenSSLCompatConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+
+	// neither side holds the shared point once restored
+	state, err := jpake2.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake2: %v", err)
+	}
+	restored2, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, OpenSSLCompatConfig(), Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling jpake2: %v", err)
+	}
+	if conf, err := restored2.ConfirmationMessage(); !errors.Is(err, ErrConfirmationUnavailable) || conf != nil {
+		t.Fatalf("expected ErrConfirmationUnavailable and no confirmation, instead got %x and %v", conf, err)
+	}
+	state, err = jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake1: %v", err)
+	}
+	restored1, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, OpenSSLCompatConfig(), Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling jpake1: %v", err)
+	}
+	if conf, err := restored1.ProcessSessionConfirmation1(conf1); !errors.Is(err, ErrConfirmationUnavailable) || conf != nil {
+		t.Fatalf("expected ErrConfirmationUnavailable and no confirmation, instead got %x and %v", conf, err)
+	}
+	if restored1.Stage != StageAwaitingConfirmation1 {
+		t.Fatalf("expected stage %s, was %s", StageAwaitingConfirmation1, restored1.Stage)
+	}
+}
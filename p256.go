@@ -0,0 +1,240 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+)
+
+// Encoded sizes of P-256 points and scalars. Points use the SEC 1 compressed
+// encoding and scalars are big-endian.
+const (
+	P256PointSize  = 33
+	P256ScalarSize = 32
+)
+
+var P256Params = &CurveParams{
+	N: bigFromHex("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551"),
+}
+
+// P256Uncompressed encodes P-256 points on the wire in the SEC 1
+// uncompressed form 0x04 || X || Y rather than the compressed form of Bytes,
+// see Config.SetPointEncoding.
+var P256Uncompressed = &PointEncoding{
+	Marshal: func(canonical []byte) []byte {
+		p, err := nistec.NewP256Point().SetBytes(canonical)
+		if err != nil {
+			// canonical came from Bytes, so is always a valid point
+			return canonical
+		}
+		return p.Bytes()
+	},
+	Unmarshal: func(wire []byte) ([]byte, error) {
+		if len(wire) != 2*P256ScalarSize+1 || wire[0] != 4 {
+			return nil, errors.New("expected an uncompressed point")
+		}
+		p, err := nistec.NewP256Point().SetBytes(wire)
+		if err != nil {
+			return nil, err
+		}
+		return p.BytesCompressed(), nil
+	},
+}
+
+// P256Point is a point on the NIST P-256 curve. The zero value is the point
+// at infinity.
+type P256Point struct {
+	p *nistec.P256Point
+}
+
+// P256Scalar is an integer modulo the order of the P-256 group.
+type P256Scalar struct {
+	n big.Int
+}
+
+type P256Curve struct {
+	Curve[*P256Point, *P256Scalar]
+}
+
+func (c P256Curve) Name() string {
+	return "p256"
+}
+
+func (c P256Curve) PointByteLen() int {
+	return P256PointSize
+}
+
+func (c P256Curve) ScalarByteLen() int {
+	return P256ScalarSize
+}
+
+func (c P256Curve) Params() *CurveParams {
+	return P256Params
+}
+
+func (c P256Curve) NewGeneratorPoint() *P256Point {
+	return &P256Point{p: nistec.NewP256Point().SetGenerator()}
+}
+
+func (c P256Curve) NewPoint() *P256Point {
+	return &P256Point{p: nistec.NewP256Point()}
+}
+
+func (c P256Curve) NewScalar() *P256Scalar {
+	return new(P256Scalar)
+}
+
+func (c P256Curve) NewRandomScalar(rand io.Reader, l int) (*P256Scalar, error) {
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Set(c.Params().N)
+	upper.Sub(upper, lower)
+	n, err := crypto_rand.Int(rand, upper)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, lower)
+	return c.NewScalar().SetBigInt(n)
+}
+
+// NewScalarFromSecret maps b into [l, N-1], see Curve25519Curve.
+func (c P256Curve) NewScalarFromSecret(l int, b []byte) (*P256Scalar, error) {
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewScalar().SetBigInt(n)
+}
+
+func (c P256Curve) Infinity(p *P256Point) bool {
+	return p.Equal(c.NewPoint()) == 1
+}
+
+// point returns the underlying nistec point, initialising it to the point at
+// infinity if p was allocated as a zero value.
+func (p *P256Point) point() *nistec.P256Point {
+	if p.p == nil {
+		p.p = nistec.NewP256Point()
+	}
+	return p.p
+}
+
+func (p *P256Point) Add(r1, r2 *P256Point) *P256Point {
+	p.point().Add(r1.point(), r2.point())
+	return p
+}
+
+func (p *P256Point) Subtract(r1, r2 *P256Point) *P256Point {
+	neg := nistec.NewP256Point().Negate(r2.point())
+	p.point().Add(r1.point(), neg)
+	return p
+}
+
+func (p *P256Point) ScalarBaseMult(s *P256Scalar) (*P256Point, error) {
+	if _, err := p.point().ScalarBaseMult(s.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *P256Point) ScalarMult(q *P256Point, s *P256Scalar) (*P256Point, error) {
+	if _, err := p.point().ScalarMult(q.point(), s.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetBytes accepts the compressed or uncompressed SEC 1 encoding of a point
+// on the curve.
+func (p *P256Point) SetBytes(b []byte) (*P256Point, error) {
+	if _, err := p.point().SetBytes(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *P256Point) Size() int {
+	return P256PointSize
+}
+
+func (p *P256Point) Bytes() []byte {
+	return p.point().BytesCompressed()
+}
+
+func (p *P256Point) Equal(q *P256Point) int {
+	return subtle.ConstantTimeCompare(p.point().Bytes(), q.point().Bytes())
+}
+
+func (s *P256Scalar) BigInt() *big.Int {
+	return new(big.Int).Set(&s.n)
+}
+
+func (s *P256Scalar) SetBigInt(i *big.Int) (*P256Scalar, error) {
+	if i.Sign() < 0 || i.Cmp(P256Params.N) >= 0 {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	s.n.Set(i)
+	return s, nil
+}
+
+func (s *P256Scalar) Multiply(t *P256Scalar, u *P256Scalar) (*P256Scalar, error) {
+	s.n.Mul(&t.n, &u.n)
+	s.n.Mod(&s.n, P256Params.N)
+	return s, nil
+}
+
+func (s *P256Scalar) Add(t *P256Scalar, u *P256Scalar) (*P256Scalar, error) {
+	s.n.Add(&t.n, &u.n)
+	s.n.Mod(&s.n, P256Params.N)
+	return s, nil
+}
+
+func (s *P256Scalar) Subtract(t *P256Scalar, u *P256Scalar) (*P256Scalar, error) {
+	s.n.Sub(&t.n, &u.n)
+	s.n.Mod(&s.n, P256Params.N)
+	return s, nil
+}
+
+func (s *P256Scalar) Negate(t *P256Scalar) (*P256Scalar, error) {
+	s.n.Neg(&t.n)
+	s.n.Mod(&s.n, P256Params.N)
+	return s, nil
+}
+
+func (s *P256Scalar) SetBytes(b []byte) (*P256Scalar, error) {
+	if len(b) != P256ScalarSize {
+		return nil, errors.New("invalid scalar length")
+	}
+	return s.SetBigInt(new(big.Int).SetBytes(b))
+}
+
+func (s *P256Scalar) Size() int {
+	return P256ScalarSize
+}
+
+func (s *P256Scalar) Bytes() []byte {
+	b := make([]byte, P256ScalarSize)
+	return s.n.FillBytes(b)
+}
+
+func (s *P256Scalar) Zero() bool {
+	return s.n.BitLen() == 0
+}
+
+func (s *P256Scalar) Equal(t *P256Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+// Zeroize sets s to zero. Setting the big.Int to zero would only truncate
+// it, so the words it held are cleared first.
+func (s *P256Scalar) Zeroize() {
+	w := s.n.Bits()
+	w = w[:cap(w)]
+	for i := range w {
+		w[i] = 0
+	}
+	s.n.SetInt64(0)
+}
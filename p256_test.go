@@ -0,0 +1,105 @@
+package jpake
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"testing"
+)
+
+func TestP256Params(t *testing.T) {
+	if P256Params.N.Cmp(elliptic.P256().Params().N) != 0 {
+		t.Fatalf("expected N to be %x, was %x", elliptic.P256().Params().N, P256Params.N)
+	}
+}
+
+func TestJpake3PassP256(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Initiator, []byte("one"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Responder, []byte("two"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassP256DifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Initiator, []byte("one"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Responder, []byte("two"), []byte("wrong"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error with mismatched passwords, instead got nil")
+	}
+}
+
+func TestJpake3PassP256Frames(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Initiator, []byte("one"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Responder, []byte("two"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []*ThreePassJpake[*P256Point, *P256Scalar]{jpake2, jpake1}
+	for i := 0; frame != nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
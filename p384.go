@@ -0,0 +1,240 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+)
+
+// Encoded sizes of P-384 points and scalars. Points use the SEC 1 compressed
+// encoding and scalars are big-endian.
+const (
+	P384PointSize  = 49
+	P384ScalarSize = 48
+)
+
+var P384Params = &CurveParams{
+	N: bigFromHex("ffffffffffffffffffffffffffffffffffffffffffffffffc7634d81f4372ddf581a0db248b0a77aecec196accc52973"),
+}
+
+// P384Uncompressed encodes P-384 points on the wire in the SEC 1
+// uncompressed form 0x04 || X || Y rather than the compressed form of Bytes,
+// see Config.SetPointEncoding.
+var P384Uncompressed = &PointEncoding{
+	Marshal: func(canonical []byte) []byte {
+		p, err := nistec.NewP384Point().SetBytes(canonical)
+		if err != nil {
+			// canonical came from Bytes, so is always a valid point
+			return canonical
+		}
+		return p.Bytes()
+	},
+	Unmarshal: func(wire []byte) ([]byte, error) {
+		if len(wire) != 2*P384ScalarSize+1 || wire[0] != 4 {
+			return nil, errors.New("expected an uncompressed point")
+		}
+		p, err := nistec.NewP384Point().SetBytes(wire)
+		if err != nil {
+			return nil, err
+		}
+		return p.BytesCompressed(), nil
+	},
+}
+
+// P384Point is a point on the NIST P-384 curve. The zero value is the point
+// at infinity.
+type P384Point struct {
+	p *nistec.P384Point
+}
+
+// P384Scalar is an integer modulo the order of the P-384 group.
+type P384Scalar struct {
+	n big.Int
+}
+
+type P384Curve struct {
+	Curve[*P384Point, *P384Scalar]
+}
+
+func (c P384Curve) Name() string {
+	return "p384"
+}
+
+func (c P384Curve) PointByteLen() int {
+	return P384PointSize
+}
+
+func (c P384Curve) ScalarByteLen() int {
+	return P384ScalarSize
+}
+
+func (c P384Curve) Params() *CurveParams {
+	return P384Params
+}
+
+func (c P384Curve) NewGeneratorPoint() *P384Point {
+	return &P384Point{p: nistec.NewP384Point().SetGenerator()}
+}
+
+func (c P384Curve) NewPoint() *P384Point {
+	return &P384Point{p: nistec.NewP384Point()}
+}
+
+func (c P384Curve) NewScalar() *P384Scalar {
+	return new(P384Scalar)
+}
+
+func (c P384Curve) NewRandomScalar(rand io.Reader, l int) (*P384Scalar, error) {
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Set(c.Params().N)
+	upper.Sub(upper, lower)
+	n, err := crypto_rand.Int(rand, upper)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, lower)
+	return c.NewScalar().SetBigInt(n)
+}
+
+// NewScalarFromSecret maps b into [l, N-1], see Curve25519Curve.
+func (c P384Curve) NewScalarFromSecret(l int, b []byte) (*P384Scalar, error) {
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewScalar().SetBigInt(n)
+}
+
+func (c P384Curve) Infinity(p *P384Point) bool {
+	return p.Equal(c.NewPoint()) == 1
+}
+
+// point returns the underlying nistec point, initialising it to the point at
+// infinity if p was allocated as a zero value.
+func (p *P384Point) point() *nistec.P384Point {
+	if p.p == nil {
+		p.p = nistec.NewP384Point()
+	}
+	return p.p
+}
+
+func (p *P384Point) Add(r1, r2 *P384Point) *P384Point {
+	p.point().Add(r1.point(), r2.point())
+	return p
+}
+
+func (p *P384Point) Subtract(r1, r2 *P384Point) *P384Point {
+	neg := nistec.NewP384Point().Negate(r2.point())
+	p.point().Add(r1.point(), neg)
+	return p
+}
+
+func (p *P384Point) ScalarBaseMult(s *P384Scalar) (*P384Point, error) {
+	if _, err := p.point().ScalarBaseMult(s.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *P384Point) ScalarMult(q *P384Point, s *P384Scalar) (*P384Point, error) {
+	if _, err := p.point().ScalarMult(q.point(), s.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetBytes accepts the compressed or uncompressed SEC 1 encoding of a point
+// on the curve.
+func (p *P384Point) SetBytes(b []byte) (*P384Point, error) {
+	if _, err := p.point().SetBytes(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *P384Point) Size() int {
+	return P384PointSize
+}
+
+func (p *P384Point) Bytes() []byte {
+	return p.point().BytesCompressed()
+}
+
+func (p *P384Point) Equal(q *P384Point) int {
+	return subtle.ConstantTimeCompare(p.point().Bytes(), q.point().Bytes())
+}
+
+func (s *P384Scalar) BigInt() *big.Int {
+	return new(big.Int).Set(&s.n)
+}
+
+func (s *P384Scalar) SetBigInt(i *big.Int) (*P384Scalar, error) {
+	if i.Sign() < 0 || i.Cmp(P384Params.N) >= 0 {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	s.n.Set(i)
+	return s, nil
+}
+
+func (s *P384Scalar) Multiply(t *P384Scalar, u *P384Scalar) (*P384Scalar, error) {
+	s.n.Mul(&t.n, &u.n)
+	s.n.Mod(&s.n, P384Params.N)
+	return s, nil
+}
+
+func (s *P384Scalar) Add(t *P384Scalar, u *P384Scalar) (*P384Scalar, error) {
+	s.n.Add(&t.n, &u.n)
+	s.n.Mod(&s.n, P384Params.N)
+	return s, nil
+}
+
+func (s *P384Scalar) Subtract(t *P384Scalar, u *P384Scalar) (*P384Scalar, error) {
+	s.n.Sub(&t.n, &u.n)
+	s.n.Mod(&s.n, P384Params.N)
+	return s, nil
+}
+
+func (s *P384Scalar) Negate(t *P384Scalar) (*P384Scalar, error) {
+	s.n.Neg(&t.n)
+	s.n.Mod(&s.n, P384Params.N)
+	return s, nil
+}
+
+func (s *P384Scalar) SetBytes(b []byte) (*P384Scalar, error) {
+	if len(b) != P384ScalarSize {
+		return nil, errors.New("invalid scalar length")
+	}
+	return s.SetBigInt(new(big.Int).SetBytes(b))
+}
+
+func (s *P384Scalar) Size() int {
+	return P384ScalarSize
+}
+
+func (s *P384Scalar) Bytes() []byte {
+	b := make([]byte, P384ScalarSize)
+	return s.n.FillBytes(b)
+}
+
+func (s *P384Scalar) Zero() bool {
+	return s.n.BitLen() == 0
+}
+
+func (s *P384Scalar) Equal(t *P384Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+// Zeroize sets s to zero. Setting the big.Int to zero would only truncate
+// it, so the words it held are cleared first.
+func (s *P384Scalar) Zeroize() {
+	w := s.n.Bits()
+	w = w[:cap(w)]
+	for i := range w {
+		w[i] = 0
+	}
+	s.n.SetInt64(0)
+}
@@ -0,0 +1,120 @@
+package jpake
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"testing"
+)
+
+func TestP384Params(t *testing.T) {
+	if P384Params.N.Cmp(elliptic.P384().Params().N) != 0 {
+		t.Fatalf("expected N to be %x, was %x", elliptic.P384().Params().N, P384Params.N)
+	}
+}
+
+func TestJpake3PassP384(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Initiator, []byte("one"), []byte("password"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Responder, []byte("two"), []byte("password"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassP384DifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Initiator, []byte("one"), []byte("password"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Responder, []byte("two"), []byte("wrong"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error with mismatched passwords, instead got nil")
+	}
+}
+
+func TestJpake3PassP384Frames(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Initiator, []byte("one"), []byte("password"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*P384Point, *P384Scalar](Responder, []byte("two"), []byte("password"), P384Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []*ThreePassJpake[*P384Point, *P384Scalar]{jpake2, jpake1}
+	for i := 0; frame != nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestP384Uncompressed(t *testing.T) {
+	g := P384Curve{}.NewGeneratorPoint().Bytes()
+	wire := P384Uncompressed.Marshal(g)
+	if len(wire) != 2*P384ScalarSize+1 || wire[0] != 4 {
+		t.Fatalf("expected an uncompressed point, got %x", wire)
+	}
+	canonical, err := P384Uncompressed.Unmarshal(wire)
+	if err != nil || !bytes.Equal(canonical, g) {
+		t.Fatalf("expected %x to decode to %x, got %x (%v)", wire, g, canonical, err)
+	}
+	if _, err := P384Uncompressed.Unmarshal(g); err == nil {
+		t.Fatalf("expected an error for a compressed point")
+	}
+}
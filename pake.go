@@ -0,0 +1,119 @@
+package jpake
+
+import "fmt"
+
+// PAKE is the frame driven lifecycle shared by both variants, so that a
+// transport can run an exchange without knowing which variant or curve it is.
+// *ThreePassJpake and *TwoPassJpake satisfy it for every curve.
+type PAKE interface {
+	Variant() Variant
+	// Start returns the first frame this side sends, or nil if it waits for
+	// the peer to speak first.
+	Start() (*Frame, error)
+	// ProcessFrame processes a received frame, returning the frame to send in
+	// reply or nil if there is none.
+	ProcessFrame(typeTag byte, body []byte) (*Frame, error)
+	// Done reports whether this side expects no further frames.
+	Done() bool
+	Key() ([]byte, error)
+}
+
+var (
+	_ PAKE = (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar])(nil)
+	_ PAKE = (*TwoPassJpake[*Curve25519Point, *Curve25519Scalar])(nil)
+)
+
+// Options configures New. The zero value of each field selects a default:
+// the three pass variant, the initiator role, curve25519 and NewConfig().
+// Role is ignored by the two pass variant, in which both sides are alike.
+type Options struct {
+	Variant  Variant
+	Role     Role
+	UserID   []byte
+	Password []byte
+	// Curve is the name of a registered curve, see CurveByName.
+	Curve  string
+	Config *Config
+}
+
+// New creates an exchange of either variant on a curve chosen by name,
+// returning it behind the PAKE interface.
+func New(opts Options) (PAKE, error) {
+	if opts.Variant == 0 {
+		opts.Variant = VariantThreePass
+	}
+	if opts.Curve == "" {
+		opts.Curve = Curve25519Curve{}.Name()
+	}
+	if opts.Config == nil {
+		opts.Config = NewConfig()
+	}
+	c, err := CurveByName(opts.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return c.(interface {
+		newPAKE(Variant, Role, []byte, []byte, *Config) (PAKE, error)
+	}).newPAKE(opts.Variant, opts.Role, opts.UserID, opts.Password, opts.Config)
+}
+
+// Start returns the pass 1 frame for the initiator and nil for the responder.
+func (jp *ThreePassJpake[P, S]) Start() (*Frame, error) {
+	if jp.role == Responder {
+		return nil, nil
+	}
+	return jp.Pass1Frame()
+}
+
+// Done reports whether this side has completed key confirmation: for the
+// initiator once ProcessSessionConfirmation1 succeeds and for the responder
+// once ProcessSessionConfirmation2 does. It is false again after Destroy.
+func (jp *ThreePassJpake[P, S]) Done() bool {
+	return jp.confirmed()
+}
+
+// Start returns the pass 1 frame. Both sides send it without waiting for the
+// peer.
+func (jp *TwoPassJpake[P, S]) Start() (*Frame, error) {
+	msg, err := jp.Pass1Message()
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: FramePass1, Body: Codec[P, S]{}.EncodeTwoPass1(msg)}, nil
+}
+
+// ProcessFrame decodes and processes the peer's pass 1 or pass 2 frame. The
+// peer's pass 1 is answered with this side's pass 2; the response is nil once
+// the session key is derived.
+func (jp *TwoPassJpake[P, S]) ProcessFrame(typeTag byte, body []byte) (*Frame, error) {
+	switch {
+	case typeTag == FrameAbort:
+		msg, err := DecodeAbortMessage(body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &PeerAbortedError{Reason: msg.Reason}
+	case jp.Stage == TwoPassStageAwaitingPass1 && typeTag == FramePass1:
+		msg, err := Codec[P, S]{}.DecodeTwoPass1(body)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := jp.GetPass2Message(*msg)
+		if err != nil {
+			return nil, err
+		}
+		return &Frame{Type: FramePass2, Body: Codec[P, S]{}.EncodeTwoPass2(reply)}, nil
+	case jp.Stage == TwoPassStageAwaitingPass2 && typeTag == FramePass2:
+		msg, err := Codec[P, S]{}.DecodeTwoPass2(body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, jp.ProcessPass2Message(*msg)
+	}
+	return nil, fmt.Errorf("%w: type %d at stage %s", ErrUnexpectedMessage, typeTag, jp.Stage)
+}
+
+// Done reports whether the session key has been derived.
+func (jp *TwoPassJpake[P, S]) Done() bool {
+	return jp.Stage == TwoPassStageDone
+}
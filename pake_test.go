@@ -0,0 +1,146 @@
+package jpake
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// runPAKE delivers frames between two sides, in whatever order they become
+// available, until both are done.
+func runPAKE(t *testing.T, a, b PAKE) {
+	t.Helper()
+	sides := [2]PAKE{a, b}
+	var inbox [2][]*Frame
+	for i, side := range sides {
+		frame, err := side.Start()
+		if err != nil {
+			t.Fatalf("error starting side %d: %v", i, err)
+		}
+		if frame != nil {
+			inbox[1-i] = append(inbox[1-i], frame)
+		}
+	}
+	for !a.Done() || !b.Done() {
+		delivered := false
+		for i, side := range sides {
+			if len(inbox[i]) == 0 {
+				continue
+			}
+			frame := inbox[i][0]
+			inbox[i] = inbox[i][1:]
+			reply, err := side.ProcessFrame(frame.Type, frame.Body)
+			if err != nil {
+				t.Fatalf("error processing frame on side %d: %v", i, err)
+			}
+			if reply != nil {
+				inbox[1-i] = append(inbox[1-i], reply)
+			}
+			delivered = true
+		}
+		if !delivered {
+			t.Fatalf("exchange stalled before both sides were done")
+		}
+	}
+	key1, err := a.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+	key2, err := b.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, key2)
+	}
+}
+
+func TestPAKE(t *testing.T) {
+	three1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	three2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, three1, three2)
+
+	two1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	two2, err := InitTwoPassJpake([]byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, two1, two2)
+}
+
+func TestPAKEVariantMismatch(t *testing.T) {
+	three, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	two, err := InitTwoPassJpake([]byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := two.Start(); err != nil {
+		t.Fatalf("error starting jpake2: %v", err)
+	}
+	frame, err := three.Start()
+	if err != nil {
+		t.Fatalf("error starting jpake1: %v", err)
+	}
+	if _, err := two.ProcessFrame(frame.Type, frame.Body); !errors.Is(err, ErrVariantMismatch) {
+		t.Fatalf("expected ErrVariantMismatch, instead got: %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	a, err := New(Options{Role: Initiator, UserID: []byte("one"), Password: []byte("password")})
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	b, err := New(Options{Role: Responder, UserID: []byte("two"), Password: []byte("password")})
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, ok := a.(*ThreePassJpake[*Curve25519Point, *Curve25519Scalar]); !ok {
+		t.Fatalf("expected the defaults to give a curve25519 three pass exchange, got %T", a)
+	}
+	runPAKE(t, a, b)
+
+	for _, variant := range []Variant{VariantThreePass, VariantTwoPass} {
+		a, err := New(Options{Variant: variant, Role: Initiator, UserID: []byte("one"), Password: []byte("password"), Curve: "p256", Config: NewConfig()})
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		b, err := New(Options{Variant: variant, Role: Responder, UserID: []byte("two"), Password: []byte("password"), Curve: "p256", Config: NewConfig()})
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if a.Variant() != variant {
+			t.Fatalf("expected variant %d, got %d", variant, a.Variant())
+		}
+		runPAKE(t, a, b)
+	}
+
+	if _, err := New(Options{UserID: []byte("one"), Password: []byte("password"), Curve: "p521"}); !errors.Is(err, ErrUnknownCurve) {
+		t.Fatalf("expected ErrUnknownCurve, instead got: %v", err)
+	}
+	if _, err := New(Options{Variant: 5, UserID: []byte("one"), Password: []byte("password")}); err == nil {
+		t.Fatalf("expected an error for an unknown variant")
+	}
+}
+
+func TestNewError(t *testing.T) {
+	p, err := New(Options{Role: 7, UserID: []byte("one"), Password: []byte("password")})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid role")
+	}
+	if p != nil {
+		t.Fatalf("expected a nil PAKE on error, got %#v", p)
+	}
+}
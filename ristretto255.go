@@ -0,0 +1,196 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"io"
+	"math/big"
+
+	"github.com/gtank/ristretto255"
+)
+
+// Encoded sizes of ristretto255 elements and scalars.
+const (
+	Ristretto255PointSize  = 32
+	Ristretto255ScalarSize = 32
+)
+
+// Ristretto255Params shares the prime order of the edwards25519 subgroup, as
+// ristretto255 is a prime order group built from that curve.
+var Ristretto255Params = &CurveParams{
+	N: Curve25519Params.N,
+}
+
+// Ristretto255Point is an element of the ristretto255 group. Unlike
+// Curve25519Point every decoded element is in the prime order group, so there
+// are no small subgroup points for a peer to send.
+type Ristretto255Point ristretto255.Element
+type Ristretto255Scalar ristretto255.Scalar
+
+type Ristretto255Curve struct {
+	Curve[*Ristretto255Point, *Ristretto255Scalar]
+}
+
+func (c Ristretto255Curve) Name() string {
+	return "ristretto255"
+}
+
+func (c Ristretto255Curve) PointByteLen() int {
+	return Ristretto255PointSize
+}
+
+func (c Ristretto255Curve) ScalarByteLen() int {
+	return Ristretto255ScalarSize
+}
+
+func (c Ristretto255Curve) Params() *CurveParams {
+	return Ristretto255Params
+}
+
+func (c Ristretto255Curve) NewGeneratorPoint() *Ristretto255Point {
+	return (*Ristretto255Point)(ristretto255.NewElement().Base())
+}
+
+func (c Ristretto255Curve) NewPoint() *Ristretto255Point {
+	return (*Ristretto255Point)(ristretto255.NewElement())
+}
+
+func (c Ristretto255Curve) NewScalar() *Ristretto255Scalar {
+	return (*Ristretto255Scalar)(ristretto255.NewScalar())
+}
+
+func (c Ristretto255Curve) NewRandomScalar(rand io.Reader, l int) (*Ristretto255Scalar, error) {
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Set(c.Params().N)
+	upper.Sub(upper, lower)
+	n, err := crypto_rand.Int(rand, upper)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, lower)
+	return c.NewScalar().SetBigInt(n)
+}
+
+// NewScalarFromSecret maps b into [l, N-1], see Curve25519Curve.
+func (c Ristretto255Curve) NewScalarFromSecret(l int, b []byte) (*Ristretto255Scalar, error) {
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewScalar().SetBigInt(n)
+}
+
+func (c Ristretto255Curve) Infinity(p *Ristretto255Point) bool {
+	return p.Equal(c.NewPoint()) == 1
+}
+
+// VarTimeMultiScalarMult returns the sum of scalars[i] * points[i]. It runs in
+// variable time and is only used on public values.
+func (c Ristretto255Curve) VarTimeMultiScalarMult(scalars []*Ristretto255Scalar, points []*Ristretto255Point) *Ristretto255Point {
+	s := make([]*ristretto255.Scalar, len(scalars))
+	for i := range scalars {
+		s[i] = (*ristretto255.Scalar)(scalars[i])
+	}
+	p := make([]*ristretto255.Element, len(points))
+	for i := range points {
+		p[i] = (*ristretto255.Element)(points[i])
+	}
+	return (*Ristretto255Point)(ristretto255.NewElement().VarTimeMultiScalarMult(s, p))
+}
+
+func (p *Ristretto255Point) Add(r1, r2 *Ristretto255Point) *Ristretto255Point {
+	return (*Ristretto255Point)((*ristretto255.Element)(p).Add((*ristretto255.Element)(r1), (*ristretto255.Element)(r2)))
+}
+
+func (p *Ristretto255Point) Subtract(r1, r2 *Ristretto255Point) *Ristretto255Point {
+	return (*Ristretto255Point)((*ristretto255.Element)(p).Subtract((*ristretto255.Element)(r1), (*ristretto255.Element)(r2)))
+}
+
+func (p *Ristretto255Point) ScalarBaseMult(s *Ristretto255Scalar) (*Ristretto255Point, error) {
+	return (*Ristretto255Point)((*ristretto255.Element)(p).ScalarBaseMult((*ristretto255.Scalar)(s))), nil
+}
+
+func (p *Ristretto255Point) ScalarMult(q *Ristretto255Point, s *Ristretto255Scalar) (*Ristretto255Point, error) {
+	return (*Ristretto255Point)((*ristretto255.Element)(p).ScalarMult((*ristretto255.Scalar)(s), (*ristretto255.Element)(q))), nil
+}
+
+// SetBytes decodes a canonical ristretto255 encoding, rejecting anything
+// else.
+func (p *Ristretto255Point) SetBytes(b []byte) (*Ristretto255Point, error) {
+	if err := (*ristretto255.Element)(p).Decode(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Ristretto255Point) Size() int {
+	return Ristretto255PointSize
+}
+
+func (p *Ristretto255Point) Bytes() []byte {
+	return (*ristretto255.Element)(p).Encode(nil)
+}
+
+func (p *Ristretto255Point) Equal(q *Ristretto255Point) int {
+	return (*ristretto255.Element)(p).Equal((*ristretto255.Element)(q))
+}
+
+func (s *Ristretto255Scalar) BigInt() *big.Int {
+	b := s.Bytes()
+	for i := 0; i < 16; i++ {
+		b[i], b[32-i-1] = b[32-i-1], b[i]
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (s *Ristretto255Scalar) SetBigInt(i *big.Int) (*Ristretto255Scalar, error) {
+	b := make([]byte, 32)
+	i.FillBytes(b)
+	for j := 0; j < 16; j++ {
+		b[j], b[32-j-1] = b[32-j-1], b[j]
+	}
+	return s.SetBytes(b)
+}
+
+func (s *Ristretto255Scalar) Multiply(t *Ristretto255Scalar, u *Ristretto255Scalar) (*Ristretto255Scalar, error) {
+	return (*Ristretto255Scalar)((*ristretto255.Scalar)(s).Multiply((*ristretto255.Scalar)(t), (*ristretto255.Scalar)(u))), nil
+}
+
+func (s *Ristretto255Scalar) Add(t *Ristretto255Scalar, u *Ristretto255Scalar) (*Ristretto255Scalar, error) {
+	return (*Ristretto255Scalar)((*ristretto255.Scalar)(s).Add((*ristretto255.Scalar)(t), (*ristretto255.Scalar)(u))), nil
+}
+
+func (s *Ristretto255Scalar) Subtract(t *Ristretto255Scalar, u *Ristretto255Scalar) (*Ristretto255Scalar, error) {
+	return (*Ristretto255Scalar)((*ristretto255.Scalar)(s).Subtract((*ristretto255.Scalar)(t), (*ristretto255.Scalar)(u))), nil
+}
+
+func (s *Ristretto255Scalar) Negate(t *Ristretto255Scalar) (*Ristretto255Scalar, error) {
+	return (*Ristretto255Scalar)((*ristretto255.Scalar)(s).Negate((*ristretto255.Scalar)(t))), nil
+}
+
+func (s *Ristretto255Scalar) SetBytes(b []byte) (*Ristretto255Scalar, error) {
+	if err := (*ristretto255.Scalar)(s).Decode(b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Ristretto255Scalar) Size() int {
+	return Ristretto255ScalarSize
+}
+
+func (s *Ristretto255Scalar) Bytes() []byte {
+	return (*ristretto255.Scalar)(s).Encode(nil)
+}
+
+func (s *Ristretto255Scalar) Zero() bool {
+	return s.BigInt().BitLen() == 0
+}
+
+func (s *Ristretto255Scalar) Equal(t *Ristretto255Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+func (s *Ristretto255Scalar) Zeroize() {
+	(*ristretto255.Scalar)(s).Zero()
+}
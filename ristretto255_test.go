@@ -0,0 +1,140 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestJpake3PassRistretto255(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Initiator, []byte("one"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Responder, []byte("two"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassRistretto255DifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Initiator, []byte("one"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Responder, []byte("two"), []byte("wrong"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error with mismatched passwords, instead got nil")
+	}
+}
+
+func TestJpake3PassRistretto255Frames(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Initiator, []byte("one"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Responder, []byte("two"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []*ThreePassJpake[*Ristretto255Point, *Ristretto255Scalar]{jpake2, jpake1}
+	for i := 0; frame != nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassRistretto255RejectsSmallOrderPoint(t *testing.T) {
+	// an edwards25519 point of order 8
+	smallOrder, _ := hex.DecodeString("26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05")
+
+	edJpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	edMsg1, err := edJpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	edCodec := Codec[*Curve25519Point, *Curve25519Scalar]{}
+	edBody := append([]byte{byte(VariantThreePass), ProtocolVersion}, concat(edMsg1.UserID, smallOrder, edMsg1.X2G.Bytes(), edCodec.encodeZKP(edMsg1.X1ZKP), edCodec.encodeZKP(edMsg1.X2ZKP))...)
+	if _, err := edCodec.DecodePass1(edBody); err != nil {
+		t.Fatalf("expected edwards25519 to decode the small order point, instead got: %v", err)
+	}
+
+	rJpake1, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Initiator, []byte("one"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	rJpake2, err := InitThreePassJpakeWithConfigAndCurve[*Ristretto255Point, *Ristretto255Scalar](Responder, []byte("two"), []byte("password"), Ristretto255Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	rMsg1, err := rJpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	rCodec := Codec[*Ristretto255Point, *Ristretto255Scalar]{}
+	rBody := append([]byte{byte(VariantThreePass), ProtocolVersion}, concat(rMsg1.UserID, smallOrder, rMsg1.X2G.Bytes(), rCodec.encodeZKP(rMsg1.X1ZKP), rCodec.encodeZKP(rMsg1.X2ZKP))...)
+	if _, err := rJpake2.ProcessFrame(FramePass1, rBody); err == nil || !strings.Contains(err.Error(), "invalid X1G") {
+		t.Fatalf("expected ristretto255 to reject the small order point, instead got: %v", err)
+	}
+	if rJpake2.Stage != 2 {
+		t.Fatalf("expected stage to remain 2, was %d", rJpake2.Stage)
+	}
+}
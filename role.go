@@ -0,0 +1,22 @@
+package jpake
+
+import "fmt"
+
+// Role is the side of a three pass exchange. The initiator sends the first
+// message.
+type Role int
+
+const (
+	Initiator Role = iota
+	Responder
+)
+
+func (r Role) String() string {
+	switch r {
+	case Initiator:
+		return "initiator"
+	case Responder:
+		return "responder"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
@@ -0,0 +1,229 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Encoded sizes of secp256k1 points and scalars. Points use the SEC 1
+// compressed encoding and scalars are big-endian.
+const (
+	Secp256k1PointSize  = 33
+	Secp256k1ScalarSize = 32
+)
+
+var Secp256k1Params = &CurveParams{
+	N: bigFromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"),
+}
+
+// Secp256k1Point is a point on the secp256k1 curve. The zero value is the
+// point at infinity.
+//
+// The underlying library only provides variable time point arithmetic, so
+// this backend should not be used where timing side channels are a concern.
+type Secp256k1Point struct {
+	p secp256k1.JacobianPoint
+}
+
+// Secp256k1Scalar is an integer modulo the order of the secp256k1 group.
+type Secp256k1Scalar struct {
+	s secp256k1.ModNScalar
+}
+
+type Secp256k1Curve struct {
+	Curve[*Secp256k1Point, *Secp256k1Scalar]
+}
+
+func (c Secp256k1Curve) Name() string {
+	return "secp256k1"
+}
+
+func (c Secp256k1Curve) PointByteLen() int {
+	return Secp256k1PointSize
+}
+
+func (c Secp256k1Curve) ScalarByteLen() int {
+	return Secp256k1ScalarSize
+}
+
+func (c Secp256k1Curve) Params() *CurveParams {
+	return Secp256k1Params
+}
+
+func (c Secp256k1Curve) NewGeneratorPoint() *Secp256k1Point {
+	p := new(Secp256k1Point)
+	var one secp256k1.ModNScalar
+	one.SetInt(1)
+	secp256k1.ScalarBaseMultNonConst(&one, &p.p)
+	return p
+}
+
+func (c Secp256k1Curve) NewPoint() *Secp256k1Point {
+	return new(Secp256k1Point)
+}
+
+func (c Secp256k1Curve) NewScalar() *Secp256k1Scalar {
+	return new(Secp256k1Scalar)
+}
+
+func (c Secp256k1Curve) NewRandomScalar(rand io.Reader, l int) (*Secp256k1Scalar, error) {
+	lower := new(big.Int).SetInt64(int64(l))
+	upper := new(big.Int).Set(c.Params().N)
+	upper.Sub(upper, lower)
+	n, err := crypto_rand.Int(rand, upper)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, lower)
+	return c.NewScalar().SetBigInt(n)
+}
+
+// NewScalarFromSecret maps b into [l, N-1], see Curve25519Curve.
+func (c Secp256k1Curve) NewScalarFromSecret(l int, b []byte) (*Secp256k1Scalar, error) {
+	n, err := c.Params().secretInRange(l, b)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewScalar().SetBigInt(n)
+}
+
+func (c Secp256k1Curve) Infinity(p *Secp256k1Point) bool {
+	return p.infinity()
+}
+
+func (p *Secp256k1Point) infinity() bool {
+	return (p.p.X.IsZero() && p.p.Y.IsZero()) || p.p.Z.IsZero()
+}
+
+func (p *Secp256k1Point) Add(r1, r2 *Secp256k1Point) *Secp256k1Point {
+	var sum secp256k1.JacobianPoint
+	secp256k1.AddNonConst(&r1.p, &r2.p, &sum)
+	p.p.Set(&sum)
+	return p
+}
+
+func (p *Secp256k1Point) Subtract(r1, r2 *Secp256k1Point) *Secp256k1Point {
+	var neg secp256k1.JacobianPoint
+	neg.Set(&r2.p)
+	neg.Y.Negate(1).Normalize()
+	var sum secp256k1.JacobianPoint
+	secp256k1.AddNonConst(&r1.p, &neg, &sum)
+	p.p.Set(&sum)
+	return p
+}
+
+func (p *Secp256k1Point) ScalarBaseMult(s *Secp256k1Scalar) (*Secp256k1Point, error) {
+	secp256k1.ScalarBaseMultNonConst(&s.s, &p.p)
+	return p, nil
+}
+
+func (p *Secp256k1Point) ScalarMult(q *Secp256k1Point, s *Secp256k1Scalar) (*Secp256k1Point, error) {
+	var r secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(&s.s, &q.p, &r)
+	p.p.Set(&r)
+	return p, nil
+}
+
+// SetBytes accepts the compressed or uncompressed SEC 1 encoding of a point
+// on the curve.
+func (p *Secp256k1Point) SetBytes(b []byte) (*Secp256k1Point, error) {
+	pub, err := secp256k1.ParsePubKey(b)
+	if err != nil {
+		return nil, err
+	}
+	pub.AsJacobian(&p.p)
+	return p, nil
+}
+
+func (p *Secp256k1Point) Size() int {
+	return Secp256k1PointSize
+}
+
+// Bytes returns the compressed encoding of p, or a single zero byte for the
+// point at infinity.
+func (p *Secp256k1Point) Bytes() []byte {
+	if p.infinity() {
+		return []byte{0}
+	}
+	var affine secp256k1.JacobianPoint
+	affine.Set(&p.p)
+	affine.ToAffine()
+	return secp256k1.NewPublicKey(&affine.X, &affine.Y).SerializeCompressed()
+}
+
+func (p *Secp256k1Point) Equal(q *Secp256k1Point) int {
+	return subtle.ConstantTimeCompare(p.Bytes(), q.Bytes())
+}
+
+func (s *Secp256k1Scalar) BigInt() *big.Int {
+	b := s.s.Bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+func (s *Secp256k1Scalar) SetBigInt(i *big.Int) (*Secp256k1Scalar, error) {
+	if i.Sign() < 0 || i.Cmp(Secp256k1Params.N) >= 0 {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	var b [32]byte
+	i.FillBytes(b[:])
+	s.s.SetBytes(&b)
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) Multiply(t *Secp256k1Scalar, u *Secp256k1Scalar) (*Secp256k1Scalar, error) {
+	s.s.Mul2(&t.s, &u.s)
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) Add(t *Secp256k1Scalar, u *Secp256k1Scalar) (*Secp256k1Scalar, error) {
+	s.s.Add2(&t.s, &u.s)
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) Subtract(t *Secp256k1Scalar, u *Secp256k1Scalar) (*Secp256k1Scalar, error) {
+	var neg secp256k1.ModNScalar
+	neg.NegateVal(&u.s)
+	s.s.Add2(&t.s, &neg)
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) Negate(t *Secp256k1Scalar) (*Secp256k1Scalar, error) {
+	s.s.NegateVal(&t.s)
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) SetBytes(b []byte) (*Secp256k1Scalar, error) {
+	if len(b) != Secp256k1ScalarSize {
+		return nil, errors.New("invalid scalar length")
+	}
+	if s.s.SetByteSlice(b) {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	return s, nil
+}
+
+func (s *Secp256k1Scalar) Size() int {
+	return Secp256k1ScalarSize
+}
+
+func (s *Secp256k1Scalar) Bytes() []byte {
+	b := s.s.Bytes()
+	return b[:]
+}
+
+func (s *Secp256k1Scalar) Zero() bool {
+	return s.s.IsZero()
+}
+
+func (s *Secp256k1Scalar) Equal(t *Secp256k1Scalar) int {
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes())
+}
+
+func (s *Secp256k1Scalar) Zeroize() {
+	s.s.Zero()
+}
@@ -0,0 +1,124 @@
+package jpake
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestJpake3PassSecp256k1(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Initiator, []byte("one"), []byte("password"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Responder, []byte("two"), []byte("password"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassSecp256k1DifferentPasswords(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Initiator, []byte("one"), []byte("password"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Responder, []byte("two"), []byte("wrong"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error with mismatched passwords, instead got nil")
+	}
+}
+
+func TestJpake3PassSecp256k1Frames(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Initiator, []byte("one"), []byte("password"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Secp256k1Point, *Secp256k1Scalar](Responder, []byte("two"), []byte("password"), Secp256k1Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	frame, err := jpake1.Pass1Frame()
+	if err != nil {
+		t.Fatalf("error getting pass1 frame: %v", err)
+	}
+	sides := []*ThreePassJpake[*Secp256k1Point, *Secp256k1Scalar]{jpake2, jpake1}
+	for i := 0; frame != nil; i++ {
+		frame, err = sides[i%2].ProcessFrame(frame.Type, frame.Body)
+		if err != nil {
+			t.Fatalf("error processing frame: %v", err)
+		}
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestSecp256k1Infinity(t *testing.T) {
+	curve := Secp256k1Curve{}
+	g := curve.NewGeneratorPoint()
+	if curve.Infinity(g) {
+		t.Fatalf("expected generator not to be infinity")
+	}
+	if !curve.Infinity(curve.NewPoint().Subtract(g, g)) {
+		t.Fatalf("expected G - G to be infinity")
+	}
+	nMinusOne, err := curve.NewScalar().SetBigInt(new(big.Int).Sub(curve.Params().N, big.NewInt(1)))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	p, err := curve.NewPoint().ScalarBaseMult(nMinusOne)
+	if err != nil {
+		t.Fatalf("error multiplying: %v", err)
+	}
+	if !curve.Infinity(p.Add(p, g)) {
+		t.Fatalf("expected (N-1)G + G to be infinity")
+	}
+	if _, err := curve.NewScalar().SetBigInt(curve.Params().N); err == nil {
+		t.Fatalf("expected error setting scalar to N, instead got nil")
+	}
+}
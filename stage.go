@@ -0,0 +1,114 @@
+package jpake
+
+import "fmt"
+
+// Stage is the position of a ThreePassJpake in the message flow. Initiator
+// stages are odd and responder stages are even.
+type Stage int
+
+const (
+	// StageDestroyed is the stage after Destroy has been called.
+	StageDestroyed Stage = iota
+	StageInit
+	StageAwaitingPass1
+	StageAwaitingPass2
+	StageAwaitingPass3
+	StageAwaitingConfirmation1
+	StageAwaitingConfirmation2
+	StageInitiatorDone
+	StageResponderDone
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageDestroyed:
+		return "destroyed"
+	case StageInit:
+		return "init"
+	case StageAwaitingPass1:
+		return "awaiting pass 1"
+	case StageAwaitingPass2:
+		return "awaiting pass 2"
+	case StageAwaitingPass3:
+		return "awaiting pass 3"
+	case StageAwaitingConfirmation1:
+		return "awaiting confirmation 1"
+	case StageAwaitingConfirmation2:
+		return "awaiting confirmation 2"
+	case StageInitiatorDone:
+		return "initiator done"
+	case StageResponderDone:
+		return "responder done"
+	}
+	return fmt.Sprintf("Stage(%d)", int(s))
+}
+
+// nextCall names the method which advances an exchange at stage s, or
+// returns "" once there is none.
+func (s Stage) nextCall() string {
+	switch s {
+	case StageInit:
+		return "Pass1Message"
+	case StageAwaitingPass1:
+		return "GetPass2Message"
+	case StageAwaitingPass2:
+		return "GetPass3Message"
+	case StageAwaitingPass3:
+		return "ProcessPass3Message"
+	case StageAwaitingConfirmation1:
+		return "ProcessSessionConfirmation1"
+	case StageAwaitingConfirmation2:
+		return "ProcessSessionConfirmation2"
+	}
+	return ""
+}
+
+// role returns the side of the exchange s belongs to.
+func (s Stage) role() Role {
+	if s%2 == 1 {
+		return Initiator
+	}
+	return Responder
+}
+
+// TwoPassStage is the position of a TwoPassJpake in the message flow.
+type TwoPassStage int
+
+const (
+	// TwoPassStageDestroyed is the stage after Destroy has been called.
+	TwoPassStageDestroyed TwoPassStage = iota
+	TwoPassStageInit
+	TwoPassStageAwaitingPass1
+	TwoPassStageAwaitingPass2
+	TwoPassStageDone
+)
+
+func (s TwoPassStage) String() string {
+	switch s {
+	case TwoPassStageDestroyed:
+		return "destroyed"
+	case TwoPassStageInit:
+		return "init"
+	case TwoPassStageAwaitingPass1:
+		return "awaiting pass 1"
+	case TwoPassStageAwaitingPass2:
+		return "awaiting pass 2"
+	case TwoPassStageDone:
+		return "done"
+	}
+	return fmt.Sprintf("TwoPassStage(%d)", int(s))
+}
+
+// nextCall names the method which advances an exchange at stage s, or
+// returns "" once there is none.
+func (s TwoPassStage) nextCall() string {
+	switch s {
+	case TwoPassStageInit:
+		return "Pass1Message"
+	case TwoPassStageAwaitingPass1:
+		return "GetPass2Message"
+	case TwoPassStageAwaitingPass2:
+		return "ProcessPass2Message"
+	}
+	return ""
+}
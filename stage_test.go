@@ -0,0 +1,133 @@
+package jpake
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJpake3PassCurrentStage(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if jpake1.CurrentStage() != StageInit || jpake2.CurrentStage() != StageAwaitingPass1 {
+		t.Fatalf("expected stages %s and %s, got %s and %s", StageInit, StageAwaitingPass1, jpake1.CurrentStage(), jpake2.CurrentStage())
+	}
+	_, err = jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if jpake1.CurrentStage() != StageAwaitingPass2 {
+		t.Fatalf("expected stage %s, was %s", StageAwaitingPass2, jpake1.CurrentStage())
+	}
+	_, err = jpake1.Pass1Message()
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "Pass1Message called at stage awaiting pass 2 (GetPass3Message expected)") {
+		t.Fatalf("expected stage mismatch error naming the expected call, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassStageErrorNamesExpectedCall(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	_, err = jpake2.ProcessPass3Message(ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]{})
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "ProcessPass3Message called at stage awaiting pass 1 (GetPass2Message expected)") {
+		t.Fatalf("expected stage mismatch error naming the expected call, instead got: %v", err)
+	}
+	_, err = jpake1.ProcessSessionConfirmation1(nil)
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "ProcessSessionConfirmation1 called at stage init (Pass1Message expected)") {
+		t.Fatalf("expected stage mismatch error naming the expected call, instead got: %v", err)
+	}
+	jpake1.Destroy()
+	_, err = jpake1.Pass1Message()
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "Pass1Message called at stage destroyed (no further calls expected)") {
+		t.Fatalf("expected stage mismatch error for a destroyed exchange, instead got: %v", err)
+	}
+}
+
+func TestStageString(t *testing.T) {
+	if s := StageAwaitingConfirmation1.String(); s != "awaiting confirmation 1" {
+		t.Fatalf("expected 'awaiting confirmation 1', was %q", s)
+	}
+	if s := Stage(42).String(); s != "Stage(42)" {
+		t.Fatalf("expected 'Stage(42)', was %q", s)
+	}
+}
+
+func TestJpake2PassCurrentStage(t *testing.T) {
+	jpake1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	if jpake1.CurrentStage() != TwoPassStageInit {
+		t.Fatalf("expected stage %s, was %s", TwoPassStageInit, jpake1.CurrentStage())
+	}
+	if _, err := jpake1.Key(); !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "Key called at stage init (Pass1Message expected)") {
+		t.Fatalf("expected stage mismatch error naming the expected call, instead got: %v", err)
+	}
+	if _, err := jpake1.Pass1Message(); err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if jpake1.CurrentStage() != TwoPassStageAwaitingPass1 {
+		t.Fatalf("expected stage %s, was %s", TwoPassStageAwaitingPass1, jpake1.CurrentStage())
+	}
+	err = jpake1.ProcessPass2Message(TwoPassVariant2[*Curve25519Point, *Curve25519Scalar]{})
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "ProcessPass2Message called at stage awaiting pass 1 (GetPass2Message expected)") {
+		t.Fatalf("expected stage mismatch error naming the expected call, instead got: %v", err)
+	}
+	jpake1.Destroy()
+	_, err = jpake1.Pass1Message()
+	if !errors.Is(err, ErrStage) || !strings.Contains(err.Error(), "Pass1Message called at stage destroyed (no further calls expected)") {
+		t.Fatalf("expected stage mismatch error for a destroyed exchange, instead got: %v", err)
+	}
+	if s := TwoPassStage(42).String(); s != "TwoPassStage(42)" {
+		t.Fatalf("expected 'TwoPassStage(42)', was %q", s)
+	}
+}
+
+func TestJpake3PassWrongRole(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if jpake1.Role() != Initiator || jpake2.Role() != Responder {
+		t.Fatalf("expected roles %s and %s, got %s and %s", Initiator, Responder, jpake1.Role(), jpake2.Role())
+	}
+	_, err = jpake2.Pass1Message()
+	if !errors.Is(err, ErrRole) || !strings.Contains(err.Error(), "Pass1Message is only called by the initiator, this side is the responder") {
+		t.Fatalf("expected role error naming the roles, instead got: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake1.GetPass2Message(*msg1); !errors.Is(err, ErrRole) {
+		t.Fatalf("expected ErrRole, instead got: %v", err)
+	}
+	if _, err := InitThreePassJpake(Role(2), []byte("one"), []byte("password")); err == nil {
+		t.Fatalf("expected error for invalid role, instead got nil")
+	}
+}
+
+func TestRoleString(t *testing.T) {
+	if s := Responder.String(); s != "responder" {
+		t.Fatalf("expected 'responder', was %q", s)
+	}
+	if s := Role(5).String(); s != "Role(5)" {
+		t.Fatalf("expected 'Role(5)', was %q", s)
+	}
+}
@@ -0,0 +1,108 @@
+package jpake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// threePassStateVersion is the leading byte of the format produced by
+// (*ThreePassJpake).MarshalBinary. Version 2 added the ratchet counter;
+// version 1 state is still read, with a counter of zero.
+const threePassStateVersion byte = 2
+
+// MarshalBinary captures the full protocol state, including the private
+// scalars, so that it may later be resumed with UnmarshalThreePassJpake. The
+// config is not included and must be supplied again when restoring. The
+// output is as sensitive as the password itself.
+func (jp *ThreePassJpake[P, S]) MarshalBinary() ([]byte, error) {
+	if jp.X1.Zero() || jp.X2.Zero() || jp.S.Zero() {
+		return nil, errors.New("cannot marshal state after scalars have been zeroized")
+	}
+	stage := binary.BigEndian.AppendUint64(nil, uint64(jp.Stage))
+	ratchets := binary.BigEndian.AppendUint64(nil, jp.ratchets)
+	var otherX1G, otherX2G []byte
+	if !isNil(jp.OtherX1G) {
+		otherX1G = jp.OtherX1G.Bytes()
+	}
+	if !isNil(jp.OtherX2G) {
+		otherX2G = jp.OtherX2G.Bytes()
+	}
+	return append([]byte{threePassStateVersion}, concat(
+		stage,
+		[]byte(jp.curve.Name()),
+		jp.userID,
+		jp.OtherUserID,
+		jp.sessionKey,
+		jp.X1.Bytes(),
+		jp.X2.Bytes(),
+		jp.S.Bytes(),
+		otherX1G,
+		otherX2G,
+		ratchets,
+	)...), nil
+}
+
+// UnmarshalThreePassJpake restores state produced by MarshalBinary. curve must
+// be the curve the state was created with.
+func UnmarshalThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]](b []byte, config *Config, curve Curve[P, S]) (*ThreePassJpake[P, S], error) {
+	if len(b) == 0 {
+		return nil, errors.New("truncated state")
+	}
+	var n int
+	switch b[0] {
+	case 1:
+		n = 10
+	case threePassStateVersion:
+		n = 11
+	default:
+		return nil, fmt.Errorf("unsupported state version %d", b[0])
+	}
+	parts, err := splitConcat(b[1:], n)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts[0]) != 8 {
+		return nil, errors.New("invalid stage")
+	}
+	var ratchets uint64
+	if n == 11 {
+		if len(parts[10]) != 8 {
+			return nil, errors.New("invalid ratchet counter")
+		}
+		ratchets = binary.BigEndian.Uint64(parts[10])
+	}
+	stage := Stage(binary.BigEndian.Uint64(parts[0]))
+	if string(parts[1]) != curve.Name() {
+		return nil, fmt.Errorf("state was created on curve %q, not %q", parts[1], curve.Name())
+	}
+	c := Codec[P, S]{}
+	x1, err := c.decodeScalar("X1", parts[5])
+	if err != nil {
+		return nil, err
+	}
+	x2, err := c.decodeScalar("X2", parts[6])
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.decodeScalar("S", parts[7])
+	if err != nil {
+		return nil, err
+	}
+	var otherX1G, otherX2G P
+	if len(parts[8]) != 0 {
+		if otherX1G, err = c.decodePoint("OtherX1G", parts[8]); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts[9]) != 0 {
+		if otherX2G, err = c.decodePoint("OtherX2G", parts[9]); err != nil {
+			return nil, err
+		}
+	}
+	var sessionKey []byte
+	if len(parts[4]) != 0 {
+		sessionKey = parts[4]
+	}
+	return RestoreThreePassJpakeWithCurveAndConfig[P, S](stage, parts[2], parts[3], sessionKey, ratchets, x1, x2, s, otherX1G, otherX2G, curve, config)
+}
@@ -0,0 +1,148 @@
+package jpake
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJpake3PassMarshalState(t *testing.T) {
+	config := NewConfig()
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if jpake1.Stage != 3 {
+		t.Fatalf("expected stage 3, was %d", jpake1.Stage)
+	}
+	state, err := jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake1: %v", err)
+	}
+	jpake1, err = UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, config, Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling jpake1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	state, err = jpake2.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake2: %v", err)
+	}
+	jpake2, err = UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, config, Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling jpake2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+
+	state, err = jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling completed jpake1: %v", err)
+	}
+	restored, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, config, Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling completed jpake1: %v", err)
+	}
+	if restored.Stage != 7 || !bytes.Equal(restored.SessionKey, jpake1.SessionKey) || !bytes.Equal(restored.OtherUserID, []byte("two")) {
+		t.Fatalf("expected completed state to be restored")
+	}
+}
+
+func TestJpake3PassUnmarshalStateInvalid(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	state, err := jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake1: %v", err)
+	}
+	if _, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state[:len(state)-1], NewConfig(), Curve25519Curve{}); err == nil {
+		t.Fatalf("expected error unmarshalling truncated state, instead got nil")
+	}
+	if _, err := UnmarshalThreePassJpake[*Ristretto255Point, *Ristretto255Scalar](state, NewConfig(), Ristretto255Curve{}); err == nil {
+		t.Fatalf("expected error unmarshalling state for another curve, instead got nil")
+	}
+	state[0] = 3
+	if _, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, NewConfig(), Curve25519Curve{}); err == nil {
+		t.Fatalf("expected error unmarshalling unknown version, instead got nil")
+	}
+}
+
+func TestJpake3PassMarshalStateRatchet(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+	for i := 0; i < 3; i++ {
+		if _, err := jpake1.Ratchet(); err != nil {
+			t.Fatalf("error ratcheting jpake1: %v", err)
+		}
+		if _, err := jpake2.Ratchet(); err != nil {
+			t.Fatalf("error ratcheting jpake2: %v", err)
+		}
+	}
+	state, err := jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling jpake1: %v", err)
+	}
+	restored, err := UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](state, NewConfig(), Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling jpake1: %v", err)
+	}
+	if restored.RatchetCount() != 3 {
+		t.Fatalf("expected ratchet count 3, was %d", restored.RatchetCount())
+	}
+	key1, err := restored.Ratchet()
+	if err != nil {
+		t.Fatalf("error ratcheting restored jpake1: %v", err)
+	}
+	key2, err := jpake2.Ratchet()
+	if err != nil {
+		t.Fatalf("error ratcheting jpake2: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("expected equal keys after restoring, got %x and %x", key1, key2)
+	}
+
+	// version 1 state has no counter and restores with a count of zero
+	v1 := append([]byte{1}, state[1:len(state)-16]...)
+	restored, err = UnmarshalThreePassJpake[*Curve25519Point, *Curve25519Scalar](v1, NewConfig(), Curve25519Curve{})
+	if err != nil {
+		t.Fatalf("error unmarshalling version 1 state: %v", err)
+	}
+	if restored.RatchetCount() != 0 {
+		t.Fatalf("expected ratchet count 0, was %d", restored.RatchetCount())
+	}
+}
@@ -0,0 +1,279 @@
+package jpake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The WriteTo and ReadFrom methods stream a message in the raw binary
+// encoding used by MarshalBinary, one length prefixed field at a time, so
+// that neither side holds the whole encoded message. Every length prefix is
+// checked against the exact size of the point or scalar it precedes before
+// anything is read, and a user ID may be at most streamMaxUserIDLength
+// bytes, so a peer cannot make the reader allocate more than one field.
+
+// streamMaxUserIDLength bounds a streamed user ID, matching the default of
+// Config.SetMaxUserIDLength.
+const streamMaxUserIDLength = 256
+
+type streamWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (sw *streamWriter) write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	n, err := sw.w.Write(b)
+	sw.n += int64(n)
+	sw.err = err
+}
+
+func (sw *streamWriter) length(l int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(l))
+	sw.write(b[:])
+}
+
+func (sw *streamWriter) field(b []byte) {
+	sw.length(len(b))
+	sw.write(b)
+}
+
+func streamWriteZKP[P CurvePoint[P, S], S CurveScalar[S]](sw *streamWriter, zkp ZKPMsg[P, S]) {
+	sw.length(16 + zkp.T.Size() + zkp.R.Size())
+	sw.field(zkp.T.Bytes())
+	sw.field(zkp.R.Bytes())
+}
+
+type streamReader struct {
+	r io.Reader
+	n int64
+}
+
+func (sr *streamReader) read(b []byte) error {
+	n, err := io.ReadFull(sr.r, b)
+	sr.n += int64(n)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.New("truncated message")
+	}
+	return err
+}
+
+// length reads a length prefix, which must lie within [min, max].
+func (sr *streamReader) length(name string, min, max int) (int, error) {
+	var b [8]byte
+	if err := sr.read(b[:]); err != nil {
+		return 0, err
+	}
+	l := binary.BigEndian.Uint64(b[:])
+	if l < uint64(min) || l > uint64(max) {
+		if min == max {
+			return 0, fmt.Errorf("invalid %s: expected %d bytes, got %d", name, min, l)
+		}
+		return 0, fmt.Errorf("invalid %s: expected %d to %d bytes, got %d", name, min, max, l)
+	}
+	return int(l), nil
+}
+
+func (sr *streamReader) field(name string, min, max int) ([]byte, error) {
+	l, err := sr.length(name, min, max)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, l)
+	if err := sr.read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func streamReadPoint[P CurvePoint[P, S], S CurveScalar[S]](sr *streamReader, name string) (P, error) {
+	p := newElement[P]()
+	b, err := sr.field(name, p.Size(), p.Size())
+	if err != nil {
+		return *new(P), err
+	}
+	if p, err = setPoint(p, b); err != nil {
+		return *new(P), fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return p, nil
+}
+
+func streamReadScalar[P CurvePoint[P, S], S CurveScalar[S]](sr *streamReader, name string) (S, error) {
+	s := newElement[S]()
+	b, err := sr.field(name, s.Size(), s.Size())
+	if err != nil {
+		return *new(S), err
+	}
+	if s, err = setCanonicalScalar(s, b); err != nil {
+		return *new(S), fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return s, nil
+}
+
+func streamReadZKP[P CurvePoint[P, S], S CurveScalar[S]](sr *streamReader, name string) (ZKPMsg[P, S], error) {
+	l := 16 + newElement[P]().Size() + newElement[S]().Size()
+	if _, err := sr.length(name, l, l); err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	t, err := streamReadPoint[P, S](sr, name+".T")
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	r, err := streamReadScalar[P, S](sr, name+".R")
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	return ZKPMsg[P, S]{T: t, R: r}, nil
+}
+
+func (msg ThreePassVariant1[P, S]) writeTo(w io.Writer, variant Variant) (int64, error) {
+	sw := &streamWriter{w: w}
+	sw.write([]byte{byte(variant), msg.Version})
+	sw.field(msg.UserID)
+	sw.field(msg.X1G.Bytes())
+	sw.field(msg.X2G.Bytes())
+	streamWriteZKP(sw, msg.X1ZKP)
+	streamWriteZKP(sw, msg.X2ZKP)
+	return sw.n, sw.err
+}
+
+func (msg *ThreePassVariant1[P, S]) readFrom(r io.Reader, variant Variant) (int64, error) {
+	sr := &streamReader{r: r}
+	var v [2]byte
+	if err := sr.read(v[:1]); err != nil {
+		return sr.n, err
+	}
+	if Variant(v[0]) != variant {
+		return sr.n, fmt.Errorf("%w: received variant %d", ErrVariantMismatch, v[0])
+	}
+	if err := sr.read(v[1:]); err != nil {
+		return sr.n, err
+	}
+	if err := checkVersion(v[1]); err != nil {
+		return sr.n, err
+	}
+	decoded := ThreePassVariant1[P, S]{Version: v[1]}
+	var err error
+	if decoded.UserID, err = sr.field("UserID", 0, streamMaxUserIDLength); err != nil {
+		return sr.n, err
+	}
+	if decoded.X1G, err = streamReadPoint[P, S](sr, "X1G"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X2G, err = streamReadPoint[P, S](sr, "X2G"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X1ZKP, err = streamReadZKP[P, S](sr, "X1ZKP"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X2ZKP, err = streamReadZKP[P, S](sr, "X2ZKP"); err != nil {
+		return sr.n, err
+	}
+	*msg = decoded
+	return sr.n, nil
+}
+
+// WriteTo streams the message to w in the encoding of MarshalBinary.
+func (msg ThreePassVariant1[P, S]) WriteTo(w io.Writer) (int64, error) {
+	return msg.writeTo(w, VariantThreePass)
+}
+
+// ReadFrom reads one message written by WriteTo from r, consuming no more
+// of r than the message itself.
+func (msg *ThreePassVariant1[P, S]) ReadFrom(r io.Reader) (int64, error) {
+	return msg.readFrom(r, VariantThreePass)
+}
+
+// WriteTo streams the message to w in the encoding of MarshalBinary.
+func (msg ThreePassVariant2[P, S]) WriteTo(w io.Writer) (int64, error) {
+	sw := &streamWriter{w: w}
+	sw.field(msg.UserID)
+	sw.field(msg.X3G.Bytes())
+	sw.field(msg.X4G.Bytes())
+	sw.field(msg.B.Bytes())
+	streamWriteZKP(sw, msg.XsZKP)
+	streamWriteZKP(sw, msg.X3ZKP)
+	streamWriteZKP(sw, msg.X4ZKP)
+	return sw.n, sw.err
+}
+
+// ReadFrom reads one message written by WriteTo from r, consuming no more
+// of r than the message itself.
+func (msg *ThreePassVariant2[P, S]) ReadFrom(r io.Reader) (int64, error) {
+	sr := &streamReader{r: r}
+	var decoded ThreePassVariant2[P, S]
+	var err error
+	if decoded.UserID, err = sr.field("UserID", 0, streamMaxUserIDLength); err != nil {
+		return sr.n, err
+	}
+	if decoded.X3G, err = streamReadPoint[P, S](sr, "X3G"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X4G, err = streamReadPoint[P, S](sr, "X4G"); err != nil {
+		return sr.n, err
+	}
+	if decoded.B, err = streamReadPoint[P, S](sr, "B"); err != nil {
+		return sr.n, err
+	}
+	if decoded.XsZKP, err = streamReadZKP[P, S](sr, "XsZKP"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X3ZKP, err = streamReadZKP[P, S](sr, "X3ZKP"); err != nil {
+		return sr.n, err
+	}
+	if decoded.X4ZKP, err = streamReadZKP[P, S](sr, "X4ZKP"); err != nil {
+		return sr.n, err
+	}
+	*msg = decoded
+	return sr.n, nil
+}
+
+// WriteTo streams the message to w in the encoding of MarshalBinary.
+func (msg ThreePassVariant3[P, S]) WriteTo(w io.Writer) (int64, error) {
+	sw := &streamWriter{w: w}
+	sw.field(msg.A.Bytes())
+	streamWriteZKP(sw, msg.XsZKP)
+	return sw.n, sw.err
+}
+
+// ReadFrom reads one message written by WriteTo from r, consuming no more
+// of r than the message itself.
+func (msg *ThreePassVariant3[P, S]) ReadFrom(r io.Reader) (int64, error) {
+	sr := &streamReader{r: r}
+	var decoded ThreePassVariant3[P, S]
+	var err error
+	if decoded.A, err = streamReadPoint[P, S](sr, "A"); err != nil {
+		return sr.n, err
+	}
+	if decoded.XsZKP, err = streamReadZKP[P, S](sr, "XsZKP"); err != nil {
+		return sr.n, err
+	}
+	*msg = decoded
+	return sr.n, nil
+}
+
+// WriteTo streams the message to w in the encoding of Codec.EncodeTwoPass1.
+func (msg TwoPassVariant1[P, S]) WriteTo(w io.Writer) (int64, error) {
+	return ThreePassVariant1[P, S](msg).writeTo(w, VariantTwoPass)
+}
+
+// ReadFrom reads one message written by WriteTo from r.
+func (msg *TwoPassVariant1[P, S]) ReadFrom(r io.Reader) (int64, error) {
+	return (*ThreePassVariant1[P, S])(msg).readFrom(r, VariantTwoPass)
+}
+
+// WriteTo streams the message to w in the encoding of Codec.EncodeTwoPass2.
+func (msg TwoPassVariant2[P, S]) WriteTo(w io.Writer) (int64, error) {
+	return ThreePassVariant3[P, S](msg).WriteTo(w)
+}
+
+// ReadFrom reads one message written by WriteTo from r.
+func (msg *TwoPassVariant2[P, S]) ReadFrom(r io.Reader) (int64, error) {
+	return (*ThreePassVariant3[P, S])(msg).ReadFrom(r)
+}
@@ -0,0 +1,119 @@
+package jpake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// pipe streams msg through an io.Pipe into out, as over a net.Conn.
+func pipe(t *testing.T, msg io.WriterTo, out io.ReaderFrom) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := msg.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	if _, err := out.ReadFrom(pr); err != nil {
+		t.Fatalf("error reading streamed message: %v", err)
+	}
+}
+
+func TestJpake3PassStream(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	var decoded1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	pipe(t, msg1, &decoded1)
+	msg2, err := jpake2.GetPass2Message(decoded1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	var decoded2 ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	pipe(t, msg2, &decoded2)
+	msg3, err := jpake1.GetPass3Message(decoded2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	var decoded3 ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	pipe(t, msg3, &decoded3)
+	conf1, err := jpake2.ProcessPass3Message(decoded3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+
+	// the stream carries exactly the MarshalBinary bytes
+	for _, msg := range []interface {
+		io.WriterTo
+		MarshalBinary() ([]byte, error)
+	}{msg1, msg2, msg3} {
+		var buf bytes.Buffer
+		n, err := msg.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("error writing message: %v", err)
+		}
+		b, _ := msg.MarshalBinary()
+		if n != int64(len(b)) || !bytes.Equal(buf.Bytes(), b) {
+			t.Fatalf("expected streamed message %x to be equal to %x", buf.Bytes(), b)
+		}
+	}
+}
+
+func TestJpake3PassStreamReadFromConsumesOneMessage(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	var buf bytes.Buffer
+	msg1.WriteTo(&buf)
+	buf.WriteString("next")
+	var decoded ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if _, err := decoded.ReadFrom(&buf); err != nil {
+		t.Fatalf("error reading pass1: %v", err)
+	}
+	if buf.String() != "next" {
+		t.Fatalf("expected the following bytes to remain unread, got %q", buf.String())
+	}
+	if decoded.X1G.Equal(msg1.X1G) != 1 {
+		t.Fatalf("expected X1G to round trip")
+	}
+}
+
+func TestJpake3PassStreamLengthLimits(t *testing.T) {
+	huge := binary.BigEndian.AppendUint64(nil, 1<<40)
+	for name, b := range map[string][]byte{
+		"user id":   append([]byte{byte(VariantThreePass), ProtocolVersion}, huge...),
+		"point":     append(append([]byte{byte(VariantThreePass), ProtocolVersion}, concat([]byte("one"))...), huge...),
+		"truncated": append([]byte{byte(VariantThreePass), ProtocolVersion}, binary.BigEndian.AppendUint64(nil, 3)...),
+		"variant":   {byte(VariantTwoPass)},
+	} {
+		var msg ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+		if _, err := msg.ReadFrom(bytes.NewReader(b)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
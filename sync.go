@@ -0,0 +1,202 @@
+package jpake
+
+import "sync"
+
+// SyncThreePassJpake wraps a ThreePassJpake so that it may be used from
+// several goroutines, for example a reader and a timeout handler. Every
+// method holds a mutex for its duration. The wrapped exchange must not be
+// used directly once wrapped.
+type SyncThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	mu sync.Mutex
+	jp *ThreePassJpake[P, S]
+}
+
+func NewSyncThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]](jp *ThreePassJpake[P, S]) *SyncThreePassJpake[P, S] {
+	return &SyncThreePassJpake[P, S]{jp: jp}
+}
+
+func (s *SyncThreePassJpake[P, S]) CurrentStage() Stage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.CurrentStage()
+}
+
+func (s *SyncThreePassJpake[P, S]) Done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Done()
+}
+
+func (s *SyncThreePassJpake[P, S]) Variant() Variant {
+	return VariantThreePass
+}
+
+func (s *SyncThreePassJpake[P, S]) Role() Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Role()
+}
+
+func (s *SyncThreePassJpake[P, S]) Start() (*Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Start()
+}
+
+func (s *SyncThreePassJpake[P, S]) Pass1Message() (*ThreePassVariant1[P, S], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Pass1Message()
+}
+
+func (s *SyncThreePassJpake[P, S]) RegeneratePass1Message() (*ThreePassVariant1[P, S], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.RegeneratePass1Message()
+}
+
+func (s *SyncThreePassJpake[P, S]) GetPass2Message(msg ThreePassVariant1[P, S]) (*ThreePassVariant2[P, S], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.GetPass2Message(msg)
+}
+
+func (s *SyncThreePassJpake[P, S]) GetPass3Message(msg ThreePassVariant2[P, S]) (*ThreePassVariant3[P, S], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.GetPass3Message(msg)
+}
+
+func (s *SyncThreePassJpake[P, S]) ProcessPass3Message(msg ThreePassVariant3[P, S]) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.ProcessPass3Message(msg)
+}
+
+func (s *SyncThreePassJpake[P, S]) ProcessSessionConfirmation1(confirm1 []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.ProcessSessionConfirmation1(confirm1)
+}
+
+func (s *SyncThreePassJpake[P, S]) ProcessSessionConfirmation2(confirm2 []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.ProcessSessionConfirmation2(confirm2)
+}
+
+func (s *SyncThreePassJpake[P, S]) ConfirmationMessage() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.ConfirmationMessage()
+}
+
+func (s *SyncThreePassJpake[P, S]) VerifyConfirmation(peer []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.VerifyConfirmation(peer)
+}
+
+func (s *SyncThreePassJpake[P, S]) Pass1Frame() (*Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Pass1Frame()
+}
+
+func (s *SyncThreePassJpake[P, S]) ProcessFrame(typeTag byte, body []byte) (*Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.ProcessFrame(typeTag, body)
+}
+
+func (s *SyncThreePassJpake[P, S]) RetryWithFreshEphemerals() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.RetryWithFreshEphemerals()
+}
+
+func (s *SyncThreePassJpake[P, S]) Reset(role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Reset(role)
+}
+
+// Clone returns a copy of the exchange made under the lock, itself wrapped
+// with its own mutex.
+func (s *SyncThreePassJpake[P, S]) Clone() *SyncThreePassJpake[P, S] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return NewSyncThreePassJpake(s.jp.Clone())
+}
+
+func (s *SyncThreePassJpake[P, S]) MarshalBinary() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.MarshalBinary()
+}
+
+func (s *SyncThreePassJpake[P, S]) Key() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Key()
+}
+
+func (s *SyncThreePassJpake[P, S]) DirectionalKeys() (sendKey, recvKey []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.DirectionalKeys()
+}
+
+func (s *SyncThreePassJpake[P, S]) SetAdditionalSecret(secret []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.SetAdditionalSecret(secret)
+}
+
+func (s *SyncThreePassJpake[P, S]) Ratchet() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Ratchet()
+}
+
+func (s *SyncThreePassJpake[P, S]) RatchetCount() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.RatchetCount()
+}
+
+func (s *SyncThreePassJpake[P, S]) Transcript() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.Transcript()
+}
+
+func (s *SyncThreePassJpake[P, S]) EqualityProof() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.EqualityProof()
+}
+
+func (s *SyncThreePassJpake[P, S]) VerifyEqualityProof(peer []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.VerifyEqualityProof(peer)
+}
+
+func (s *SyncThreePassJpake[P, S]) KeyFingerprint() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.KeyFingerprint()
+}
+
+func (s *SyncThreePassJpake[P, S]) SharedPointX25519() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jp.SharedPointX25519()
+}
+
+func (s *SyncThreePassJpake[P, S]) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jp.Destroy()
+}
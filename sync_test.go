@@ -0,0 +1,104 @@
+package jpake
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSyncThreePassJpakeConcurrentStage(t *testing.T) {
+	jp1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jp2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	jpake1 := NewSyncThreePassJpake(jp1)
+	jpake2 := NewSyncThreePassJpake(jp2)
+
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				jpake1.CurrentStage()
+				jpake2.CurrentStage()
+			}
+		}
+	}()
+
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	close(done)
+	<-watched
+
+	if stage := jpake1.CurrentStage(); stage != StageInitiatorDone {
+		t.Fatalf("expected stage %s, was %s", StageInitiatorDone, stage)
+	}
+	key1, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+	key2, err := jpake2.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, key2)
+	}
+}
+
+func TestSyncThreePassJpakeCoversMethods(t *testing.T) {
+	wrapper := reflect.TypeOf(&SyncThreePassJpake[*Curve25519Point, *Curve25519Scalar]{})
+	inner := reflect.TypeOf(&ThreePassJpake[*Curve25519Point, *Curve25519Scalar]{})
+	for i := 0; i < inner.NumMethod(); i++ {
+		name := inner.Method(i).Name
+		if _, ok := wrapper.MethodByName(name); !ok {
+			t.Fatalf("expected SyncThreePassJpake to forward %s", name)
+		}
+	}
+
+	jp, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake1 := NewSyncThreePassJpake(jp)
+	frame, err := jpake1.Start()
+	if err != nil || frame == nil {
+		t.Fatalf("expected the initiator to start with a frame, instead got %v, %v", frame, err)
+	}
+	clone := jpake1.Clone()
+	if clone.Role() != Initiator || clone.CurrentStage() != StageAwaitingPass2 {
+		t.Fatalf("expected a clone at stage %s, was %s", StageAwaitingPass2, clone.CurrentStage())
+	}
+	if clone.jp == jp {
+		t.Fatalf("expected the clone to wrap a copy of the exchange")
+	}
+}
@@ -1,17 +1,24 @@
 package jpake
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"reflect"
 )
 
 func concat(parts ...[]byte) []byte {
-	msg := []byte{}
+	total := 0
+	for _, m := range parts {
+		total += 8 + len(m)
+	}
+	msg := make([]byte, 0, total)
 	for _, m := range parts {
 		msg = binary.BigEndian.AppendUint64(msg, uint64(len(m)))
 		msg = append(msg, m...)
@@ -20,11 +27,13 @@ func concat(parts ...[]byte) []byte {
 }
 
 type ThreePassVariant1[P CurvePoint[P, S], S CurveScalar[S]] struct {
-	UserID []byte
-	X1G    P
-	X2G    P
-	X1ZKP  ZKPMsg[P, S]
-	X2ZKP  ZKPMsg[P, S]
+	// Version is the sender's ProtocolVersion.
+	Version uint8
+	UserID  []byte
+	X1G     P
+	X2G     P
+	X1ZKP   ZKPMsg[P, S]
+	X2ZKP   ZKPMsg[P, S]
 }
 
 type ThreePassVariant2[P CurvePoint[P, S], S CurveScalar[S]] struct {
@@ -42,6 +51,95 @@ type ThreePassVariant3[P CurvePoint[P, S], S CurveScalar[S]] struct {
 	XsZKP ZKPMsg[P, S]
 }
 
+func copyPoint[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], p P) P {
+	if isNil(p) {
+		return p
+	}
+	return curve.NewPoint().Add(p, curve.NewPoint())
+}
+
+func copyScalar[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], s S) S {
+	if isNil(s) {
+		return s
+	}
+	c, err := curve.NewScalar().SetBytes(s.Bytes())
+	if err != nil {
+		// every encoding produced by Bytes is canonical
+		panic("jpake: scalar encoding did not round trip: " + err.Error())
+	}
+	return c
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// isNil reports whether v is nil, including nil pointers held by a type
+// parameter.
+func isNil[T any](v T) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func validateZKP[P CurvePoint[P, S], S CurveScalar[S]](name string, zkp ZKPMsg[P, S]) error {
+	if isNil(zkp.T) {
+		return fmt.Errorf("%w: missing %s.T", ErrMalformedMessage, name)
+	}
+	if isNil(zkp.R) {
+		return fmt.Errorf("%w: missing %s.R", ErrMalformedMessage, name)
+	}
+	return nil
+}
+
+func (msg *ThreePassVariant1[P, S]) validate() error {
+	if isNil(msg.X1G) {
+		return fmt.Errorf("%w: missing X1G", ErrMalformedMessage)
+	}
+	if isNil(msg.X2G) {
+		return fmt.Errorf("%w: missing X2G", ErrMalformedMessage)
+	}
+	if err := validateZKP("X1ZKP", msg.X1ZKP); err != nil {
+		return err
+	}
+	return validateZKP("X2ZKP", msg.X2ZKP)
+}
+
+func (msg *ThreePassVariant2[P, S]) validate() error {
+	if isNil(msg.X3G) {
+		return fmt.Errorf("%w: missing X3G", ErrMalformedMessage)
+	}
+	if isNil(msg.X4G) {
+		return fmt.Errorf("%w: missing X4G", ErrMalformedMessage)
+	}
+	if isNil(msg.B) {
+		return fmt.Errorf("%w: missing B", ErrMalformedMessage)
+	}
+	if err := validateZKP("XsZKP", msg.XsZKP); err != nil {
+		return err
+	}
+	if err := validateZKP("X3ZKP", msg.X3ZKP); err != nil {
+		return err
+	}
+	return validateZKP("X4ZKP", msg.X4ZKP)
+}
+
+func (msg *ThreePassVariant3[P, S]) validate() error {
+	if isNil(msg.A) {
+		return fmt.Errorf("%w: missing A", ErrMalformedMessage)
+	}
+	return validateZKP("XsZKP", msg.XsZKP)
+}
+
 // Three pass variant jpake https://tools.ietf.org/html/rfc8236#section-4
 // If serializing/deserializing, get/set all exported members
 type ThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]] struct {
@@ -56,7 +154,24 @@ type ThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]] struct {
 	OtherUserID []byte
 
 	// Calculated values
-	x2s        S
+	x2s         S
+	sharedPoint P
+	sessionKey  []byte
+	// ratchets counts the calls to Ratchet since the key was derived
+	ratchets uint64
+	// additionalSecret is mixed into the session key, see
+	// SetAdditionalSecret
+	additionalSecret []byte
+	// transcript holds the encoded messages of the handshake so far
+	transcript [][]byte
+	// inboundFrames holds a digest of each frame ProcessFrame has processed,
+	// see checkFrameReplay
+	inboundFrames [][sha256.Size]byte
+	// pass1 is the message returned by Pass1Message, kept for
+	// RegeneratePass1Message
+	pass1 *ThreePassVariant1[P, S]
+	// SessionKey is the derived key. When the config requires key
+	// confirmation it is only populated once confirmation has completed.
 	SessionKey []byte
 
 	// Private Variables
@@ -65,43 +180,90 @@ type ThreePassJpake[P CurvePoint[P, S], S CurveScalar[S]] struct {
 	S  S
 
 	// configuration
-	Stage  int
+	Stage  Stage
+	role   Role
 	config *Config
 	curve  Curve[P, S]
 }
 
 // curve25519Curve{curve[curvePoint[curve25519point]]}
 
-func InitThreePassJpake(initiator bool, userID, pw []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
-	return InitThreePassJpakeWithConfig(initiator, userID, pw, NewConfig())
+func InitThreePassJpake(role Role, userID, pw []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return InitThreePassJpakeWithConfig(role, userID, pw, NewConfig())
 }
 
-func InitThreePassJpakeWithConfig(initiator bool, userID, pw []byte, config *Config) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
-	return InitThreePassJpakeWithConfigAndCurve[*Curve25519Point, *Curve25519Scalar](initiator, userID, pw, Curve25519Curve{}, config)
+func InitThreePassJpakeWithConfig(role Role, userID, pw []byte, config *Config) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return InitThreePassJpakeWithConfigAndCurve[*Curve25519Point, *Curve25519Scalar](role, userID, pw, Curve25519Curve{}, config)
 }
 
-func InitThreePassJpakeWithConfigAndCurve[P CurvePoint[P, S], S CurveScalar[S]](initiator bool, userID, pw []byte, curve Curve[P, S], config *Config) (*ThreePassJpake[P, S], error) {
+// maxReaderPasswordLength bounds the password read by
+// InitThreePassJpakeFromReader.
+const maxReaderPasswordLength = 4096
+
+// InitThreePassJpakeFromReader is InitThreePassJpakeWithConfig with the
+// password read from pw until EOF, for callers which would rather not hold
+// the password in a slice of their own. The password is read into a buffer
+// which is zeroized once s has been derived, so no copy of it is retained.
+func InitThreePassJpakeFromReader(role Role, userID []byte, pw io.Reader, config *Config) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return InitThreePassJpakeFromReaderWithCurve[*Curve25519Point, *Curve25519Scalar](role, userID, pw, Curve25519Curve{}, config)
+}
+
+// InitThreePassJpakeFromReaderWithCurve is InitThreePassJpakeFromReader on
+// the given curve.
+func InitThreePassJpakeFromReaderWithCurve[P CurvePoint[P, S], S CurveScalar[S]](role Role, userID []byte, pw io.Reader, curve Curve[P, S], config *Config) (*ThreePassJpake[P, S], error) {
+	// a fixed buffer rather than io.ReadAll, which leaves the password in
+	// every buffer it outgrows
+	buf := make([]byte, maxReaderPasswordLength+1)
+	defer zeroizeBytes(buf)
+	n, err := io.ReadFull(pw, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("reading password: %w", err)
+	}
+	if n > maxReaderPasswordLength {
+		return nil, fmt.Errorf("password longer than %d bytes", maxReaderPasswordLength)
+	}
+	return InitThreePassJpakeWithConfigAndCurve[P, S](role, userID, buf[:n], curve, config)
+}
+
+// InitThreePassJpakeNamed initialises a three pass exchange on the curve
+// registered under curveName, see CurveByName.
+func InitThreePassJpakeNamed(curveName string, role Role, userID, pw []byte) (Handshake, error) {
+	curve, err := CurveByName(curveName)
+	if err != nil {
+		return nil, err
+	}
+	return curve.NewThreePassJpake(role, userID, pw, NewConfig())
+}
+
+func InitThreePassJpakeWithConfigAndCurve[P CurvePoint[P, S], S CurveScalar[S]](role Role, userID, pw []byte, curve Curve[P, S], config *Config) (*ThreePassJpake[P, S], error) {
+	if role != Initiator && role != Responder {
+		return nil, fmt.Errorf("invalid role %s", role)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.checkPassword(pw); err != nil {
+		return nil, err
+	}
 	jp := new(ThreePassJpake[P, S])
 	jp.SessionKey = []byte{} // make sure to invalidate the session key
 	jp.userID = userID
+	if config.blindUserIDs {
+		jp.userID = config.blindedUserID(role)
+	}
 	jp.config = config
+	jp.role = role
 	// Generate private random variables
-	rand1, err := curve.NewRandomScalar(1)
-	if err != nil {
-		return nil, err
-	}
-	rand2, err := curve.NewRandomScalar(1)
-	if err != nil {
+	if err := jp.generateEphemerals(curve); err != nil {
 		return nil, err
 	}
-	jp.X1 = rand1
-	jp.X2 = rand2
-	if initiator {
-		jp.Stage = 1
+	if role == Initiator {
+		jp.Stage = StageInit
 	} else {
-		jp.Stage = 2
+		jp.Stage = StageAwaitingPass1
 	}
 	// Compute a simple hash of our secret
+	var err error
 	jp.S, err = curve.NewScalarFromSecret(1, config.generateSecret(pw)) // The value of s falls within [1, n-1].
 	if err != nil {
 		return jp, err
@@ -112,15 +274,18 @@ func InitThreePassJpakeWithConfigAndCurve[P CurvePoint[P, S], S CurveScalar[S]](
 	return jp, err
 }
 
-func RestoreThreePassJpake(stage int, userID, otherUserID, sessionKey []byte, x1, x2, s *Curve25519Scalar, otherX1G, otherX2G *Curve25519Point) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
-	return RestoreThreePassJpakeWithConfig(stage, userID, otherUserID, sessionKey, x1, x2, s, otherX1G, otherX2G, NewConfig())
+func RestoreThreePassJpake(stage Stage, userID, otherUserID, sessionKey []byte, ratchets uint64, x1, x2, s *Curve25519Scalar, otherX1G, otherX2G *Curve25519Point) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return RestoreThreePassJpakeWithConfig(stage, userID, otherUserID, sessionKey, ratchets, x1, x2, s, otherX1G, otherX2G, NewConfig())
 }
 
-func RestoreThreePassJpakeWithConfig(stage int, userID, otherUserID, sessionKey []byte, x1, x2, s *Curve25519Scalar, otherX1G, otherX2G *Curve25519Point, config *Config) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
-	return RestoreThreePassJpakeWithCurveAndConfig[*Curve25519Point, *Curve25519Scalar](stage, userID, otherUserID, sessionKey, x1, x2, s, otherX1G, otherX2G, Curve25519Curve{}, config)
+func RestoreThreePassJpakeWithConfig(stage Stage, userID, otherUserID, sessionKey []byte, ratchets uint64, x1, x2, s *Curve25519Scalar, otherX1G, otherX2G *Curve25519Point, config *Config) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return RestoreThreePassJpakeWithCurveAndConfig[*Curve25519Point, *Curve25519Scalar](stage, userID, otherUserID, sessionKey, ratchets, x1, x2, s, otherX1G, otherX2G, Curve25519Curve{}, config)
 }
 
-func RestoreThreePassJpakeWithCurveAndConfig[P CurvePoint[P, S], S CurveScalar[S]](stage int, userID, otherUserID, sessionKey []byte, x1, x2, s S, otherX1G, otherX2G P, curve Curve[P, S], config *Config) (*ThreePassJpake[P, S], error) {
+func RestoreThreePassJpakeWithCurveAndConfig[P CurvePoint[P, S], S CurveScalar[S]](stage Stage, userID, otherUserID, sessionKey []byte, ratchets uint64, x1, x2, s S, otherX1G, otherX2G P, curve Curve[P, S], config *Config) (*ThreePassJpake[P, S], error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	if x1.Zero() {
 		return nil, errors.New("x1 cannot be at zero")
 	}
@@ -131,138 +296,254 @@ func RestoreThreePassJpakeWithCurveAndConfig[P CurvePoint[P, S], S CurveScalar[S
 		return nil, errors.New("s cannot be at zero")
 	}
 
-	if stage >= 4 {
+	if stage < StageInit || stage > StageResponderDone {
+		return nil, fmt.Errorf("cannot restore at stage %s: %w", stage, ErrStage)
+	}
+	if stage >= StageAwaitingPass3 {
+		if isNil(otherX1G) {
+			return nil, fmt.Errorf("otherx1g is required at stage %s", stage)
+		}
+		if isNil(otherX2G) {
+			return nil, fmt.Errorf("otherx2g is required at stage %s", stage)
+		}
+		if len(otherUserID) == 0 {
+			return nil, fmt.Errorf("other user id is required at stage %s", stage)
+		}
 		if curve.Infinity(otherX1G) {
-			return nil, errors.New("otherx1g cannot be at infinity")
+			return nil, fmt.Errorf("otherx1g cannot be at infinity: %w", ErrPointAtInfinity)
 		}
 		if curve.Infinity(otherX2G) {
-			return nil, errors.New("otherx2g cannot be at infinity")
+			return nil, fmt.Errorf("otherx2g cannot be at infinity: %w", ErrPointAtInfinity)
 		}
 	}
+	if stage >= StageAwaitingConfirmation1 && len(sessionKey) == 0 {
+		return nil, fmt.Errorf("session key is required at stage %s", stage)
+	}
+	if ratchets != 0 && len(sessionKey) == 0 {
+		return nil, errors.New("ratchets require a session key")
+	}
 
 	jp := new(ThreePassJpake[P, S])
 	jp.Stage = stage
+	jp.role = stage.role()
+	jp.config = config
 	jp.userID = userID
 	jp.OtherUserID = otherUserID
-	jp.SessionKey = sessionKey
+	jp.sessionKey = sessionKey
+	jp.ratchets = ratchets
+	jp.releaseSessionKey()
 	jp.X1 = x1
 	jp.X2 = x2
 	jp.S = s
 	jp.OtherX1G = otherX1G
 	jp.OtherX2G = otherX2G
-	jp.config = config
 	if err := jp.initWithCurve(curve); err != nil {
 		return jp, err
 	}
 	return jp, nil
 }
 
-func (jp *ThreePassJpake[P, S]) initWithCurve(curve Curve[P, S]) error {
-	jp.curve = curve
-
-	p1, err := jp.curve.NewPoint().ScalarBaseMult(jp.X1)
-	if err != nil {
-		return err
-	}
-	jp.x1G = p1
-	p2, err := jp.curve.NewPoint().ScalarBaseMult(jp.X2)
-	if err != nil {
-		return err
+// setStage moves the exchange to stage, notifying the observer if any.
+func (jp *ThreePassJpake[P, S]) setStage(stage Stage) {
+	if jp.config.observer != nil {
+		jp.config.observer.OnStageTransition(jp.Stage, stage)
 	}
-	jp.x2G = p2
+	jp.Stage = stage
+}
 
-	jp.x2s, err = jp.curve.NewScalar().Multiply(jp.X2, jp.S)
-	if err != nil {
-		return err
+// Role returns the side of the exchange this party is on.
+func (jp *ThreePassJpake[P, S]) Role() Role {
+	return jp.role
+}
+
+// checkRole returns an error naming method if it belongs to the other side.
+func (jp *ThreePassJpake[P, S]) checkRole(method string, role Role) error {
+	if jp.role != role {
+		return fmt.Errorf("%s is only called by the %s, this side is the %s: %w", method, role, jp.role, ErrRole)
 	}
 	return nil
 }
 
-func (jp *ThreePassJpake[P, S]) computeZKP(x S, generator P, y P) (ZKPMsg[P, S], error) {
-	// Computes a ZKP for x on Generator. We use the Fiat-Shamir heuristic:
-	// https://en.wikipedia.org/wiki/Fiat%E2%80%93Shamir_heuristic
-	// i.e. prove that we know x such that y = x.Generator
-	// Note that we differentiate between the point G on the curve, and the
-	// Generator used to compute the ZKP
-
-	// 1. Pick a random v \in Z_q* and compute t = vG
-	v, err := jp.curve.NewRandomScalar(1)
-	if err != nil {
-		return ZKPMsg[P, S]{}, err
+// checkStage returns an ErrStage error naming the method which should be
+// called instead if the exchange is not at stage.
+func (jp *ThreePassJpake[P, S]) checkStage(method string, stage Stage) error {
+	if jp.Stage == stage {
+		return nil
+	}
+	if next := jp.Stage.nextCall(); next != "" {
+		return fmt.Errorf("%s called at stage %s (%s expected): %w", method, jp.Stage, next, ErrStage)
 	}
+	return fmt.Errorf("%s called at stage %s (no further calls expected): %w", method, jp.Stage, ErrStage)
+}
 
-	t, err := jp.curve.NewPoint().ScalarMult(generator, v)
-	if err != nil {
-		return ZKPMsg[P, S]{}, err
+// checkReplay returns ErrReplayedMessage if encoded is a message already
+// recorded in the transcript, so that a re-delivery is reported as such
+// rather than as a stage mismatch.
+func (jp *ThreePassJpake[P, S]) checkReplay(method string, encoded []byte) error {
+	for _, m := range jp.transcript {
+		if bytes.Equal(m, encoded) {
+			return fmt.Errorf("%s called at stage %s with a message already processed: %w", method, jp.Stage, ErrReplayedMessage)
+		}
 	}
+	return nil
+}
 
-	// 2. Compute c = H(g, y, t) where H() is a cryptographic hash fn
-	//    Within the hash function, there must be a clear boundary between any two concatenated items.  It is RECOMMENDED that one should always prepend each item with a 4-byte integer that represents the byte length of that item.  OtherInfo may contain multiple subitems.  In that case, the same rule shall apply to ensure a clear boundary between adjacent subitems.
+// CurrentStage returns the stage the exchange is at.
+func (jp *ThreePassJpake[P, S]) CurrentStage() Stage {
+	return jp.Stage
+}
 
-	chal := concat(generator.Bytes(), t.Bytes(), y.Bytes(), jp.userID)
-	c := (new(big.Int).SetBytes(jp.config.hashFn(chal)))
-	c.Mod(c, jp.curve.Params().N)
+func (jp *ThreePassJpake[P, S]) Variant() Variant {
+	return VariantThreePass
+}
 
-	// Need to store the result of Mul(c,x) in a new pointer as we need c later,
-	// but we don't need to do the same for v because we don't use it afterwards
-	vint := v.BigInt()
-	xint := x.BigInt()
-	rIntermediate := vint.Sub(vint, new(big.Int).Mul(c, xint))
-	r := rIntermediate.Mod(rIntermediate, jp.curve.Params().N)
-	rS, err := jp.curve.NewScalar().SetBigInt(r)
+func (jp *ThreePassJpake[P, S]) generateEphemerals(curve Curve[P, S]) error {
+	rand1, err := curve.NewRandomScalar(jp.config.rand, 1)
 	if err != nil {
-		return ZKPMsg[P, S]{}, err
+		return err
+	}
+	rand2, err := curve.NewRandomScalar(jp.config.rand, 1)
+	if err != nil {
+		return err
 	}
-	return ZKPMsg[P, S]{
-		T: t,
-		R: rS,
-	}, err
+	jp.X1 = rand1
+	jp.X2 = rand2
+	return nil
 }
 
-func (jp *ThreePassJpake[P, S]) checkZKP(msgObj ZKPMsg[P, S], generator, y P) bool {
-	if jp.curve.Infinity(generator) {
-		return false
+// RetryWithFreshEphemerals restarts the handshake from the first stage for
+// this side with new ephemeral scalars, discarding everything received from
+// the peer. The password derived secret s and the config are kept, avoiding
+// the cost of re-deriving s with a slow password KDF. It returns an error
+// once Destroy has been called or s has been zeroized on completion.
+func (jp *ThreePassJpake[P, S]) RetryWithFreshEphemerals() error {
+	if jp.Stage == StageDestroyed {
+		return fmt.Errorf("RetryWithFreshEphemerals called at stage %s: %w", jp.Stage, ErrStage)
 	}
-	if jp.curve.Infinity(y) {
-		return false
+	if isNil(jp.S) || jp.S.Zero() {
+		return errors.New("cannot retry after s has been zeroized")
 	}
-	// validate T is not infinity
-	if jp.curve.Infinity(msgObj.T) {
-		return false
+	if err := jp.generateEphemerals(jp.curve); err != nil {
+		return err
 	}
-	// validate R is not zero
-	if msgObj.R.Zero() {
-		return false
+	if err := jp.initWithCurve(jp.curve); err != nil {
+		return err
+	}
+	var zero P
+	jp.OtherX1G = zero
+	jp.OtherX2G = zero
+	jp.OtherUserID = nil
+	jp.sharedPoint = zero
+	jp.sessionKey = nil
+	jp.SessionKey = []byte{}
+	jp.ratchets = 0
+	jp.transcript = nil
+	jp.inboundFrames = nil
+	jp.pass1 = nil
+	if jp.role == Initiator {
+		jp.setStage(StageInit)
+	} else {
+		jp.setStage(StageAwaitingPass1)
 	}
+	return nil
+}
 
-	chal := concat(generator.Bytes(), msgObj.T.Bytes(), y.Bytes(), jp.OtherUserID)
-	c := (new(big.Int).SetBytes(jp.config.hashFn(chal)))
-	c = c.Mod(c, jp.curve.Params().N)
+// Reset prepares a completed exchange for a fresh handshake as role with the
+// same password, user ID and config. It may only be called once this side
+// has completed key confirmation. The previous ephemeral scalars and session
+// key are zeroized, so the config must not wipe s on completion, see
+// SetAutoZeroizeEphemerals.
+func (jp *ThreePassJpake[P, S]) Reset(role Role) error {
+	if role != Initiator && role != Responder {
+		return fmt.Errorf("invalid role %s", role)
+	}
+	if jp.Stage == StageDestroyed {
+		return fmt.Errorf("Reset called at stage %s: %w", jp.Stage, ErrStage)
+	}
+	if !jp.confirmed() {
+		return fmt.Errorf("cannot reset at stage %s, the exchange has not completed: %w", jp.Stage, ErrStage)
+	}
+	if jp.S.Zero() {
+		return errors.New("cannot reset after s has been zeroized")
+	}
+	zeroize(jp.X1, jp.X2, jp.x2s)
+	zeroizeBytes(jp.sessionKey)
+	jp.role = role
+	if jp.config.blindUserIDs {
+		jp.userID = jp.config.blindedUserID(role)
+	}
+	return jp.RetryWithFreshEphemerals()
+}
 
-	// if c is zero
-	if c.BitLen() == 0 {
-		return false
+// Clone returns an independent copy of the exchange at its current stage,
+// sharing only the config. This allows several continuations to be tried from
+// the same state without re-deriving s.
+func (jp *ThreePassJpake[P, S]) Clone() *ThreePassJpake[P, S] {
+	return &ThreePassJpake[P, S]{
+		x1G:              copyPoint(jp.curve, jp.x1G),
+		x2G:              copyPoint(jp.curve, jp.x2G),
+		userID:           copyBytes(jp.userID),
+		OtherX1G:         copyPoint(jp.curve, jp.OtherX1G),
+		OtherX2G:         copyPoint(jp.curve, jp.OtherX2G),
+		OtherUserID:      copyBytes(jp.OtherUserID),
+		x2s:              copyScalar(jp.curve, jp.x2s),
+		sharedPoint:      copyPoint(jp.curve, jp.sharedPoint),
+		sessionKey:       copyBytes(jp.sessionKey),
+		SessionKey:       copyBytes(jp.SessionKey),
+		ratchets:         jp.ratchets,
+		transcript:       append([][]byte(nil), jp.transcript...),
+		inboundFrames:    append([][sha256.Size]byte(nil), jp.inboundFrames...),
+		additionalSecret: copyBytes(jp.additionalSecret),
+		pass1:            jp.pass1,
+		X1:               copyScalar(jp.curve, jp.X1),
+		X2:               copyScalar(jp.curve, jp.X2),
+		S:                copyScalar(jp.curve, jp.S),
+		Stage:            jp.Stage,
+		role:             jp.role,
+		config:           jp.config,
+		curve:            jp.curve,
 	}
+}
 
-	vcheck, err := jp.curve.NewPoint().ScalarMult(generator, msgObj.R)
+func (jp *ThreePassJpake[P, S]) initWithCurve(curve Curve[P, S]) error {
+	jp.curve = curve
+
+	p1, err := jp.curve.NewPoint().ScalarBaseMult(jp.X1)
 	if err != nil {
-		return false
+		return err
 	}
-	cS, err := jp.curve.NewScalar().SetBigInt(c)
+	jp.x1G = p1
+	p2, err := jp.curve.NewPoint().ScalarBaseMult(jp.X2)
 	if err != nil {
-		return false
+		return err
 	}
-	tmp2, err := jp.curve.NewPoint().ScalarMult(y, cS)
+	jp.x2G = p2
+
+	jp.x2s, err = jp.curve.NewScalar().Multiply(jp.X2, jp.S)
 	if err != nil {
-		return false
+		return err
 	}
-	vcheck.Add(vcheck, tmp2)
-	return vcheck.Equal(msgObj.T) == 1
+	return nil
+}
+
+func (jp *ThreePassJpake[P, S]) computeZKP(x S, generator P, y P) (ZKPMsg[P, S], error) {
+	return computeZKP(jp.curve, jp.config, jp.userID, x, generator, y)
+}
+
+func (jp *ThreePassJpake[P, S]) checkZKP(name string, msgObj ZKPMsg[P, S], generator, y P) error {
+	return checkZKP(jp.curve, jp.config, jp.OtherUserID, name, msgObj, generator, y)
+}
+
+func (jp *ThreePassJpake[P, S]) checkZKPs(statements ...zkpStatement[P, S]) error {
+	return checkZKPs(jp.curve, jp.config, jp.OtherUserID, statements...)
 }
 
 func (jp *ThreePassJpake[P, S]) Pass1Message() (*ThreePassVariant1[P, S], error) {
-	if jp.Stage != 1 {
-		return nil, fmt.Errorf("expected stage 1, was %d", jp.Stage)
+	if err := jp.checkRole("Pass1Message", Initiator); err != nil {
+		return nil, err
+	}
+	if err := jp.checkStage("Pass1Message", StageInit); err != nil {
+		return nil, err
 	}
 	x1ZKP, err := jp.computeZKP(jp.X1, jp.curve.NewGeneratorPoint(), jp.x1G)
 	if err != nil {
@@ -273,52 +554,122 @@ func (jp *ThreePassJpake[P, S]) Pass1Message() (*ThreePassVariant1[P, S], error)
 		return nil, err
 	}
 
-	jp.Stage = 3
+	jp.setStage(StageAwaitingPass2)
 	pass1Message := ThreePassVariant1[P, S]{
-		UserID: jp.userID,
-		X1G:    jp.x1G,
-		X2G:    jp.x2G,
-		X1ZKP:  x1ZKP,
-		X2ZKP:  x2ZKP,
+		Version: ProtocolVersion,
+		UserID:  jp.userID,
+		X1G:     jp.x1G,
+		X2G:     jp.x2G,
+		X1ZKP:   x1ZKP,
+		X2ZKP:   x2ZKP,
 	}
+	jp.transcript = [][]byte{Codec[P, S]{}.EncodePass1(&pass1Message)}
+	jp.pass1 = &pass1Message
 	return &pass1Message, nil
 }
 
-func (jp *ThreePassJpake[P, S]) GetPass2Message(msg ThreePassVariant1[P, S]) (*ThreePassVariant2[P, S], error) {
-	if jp.Stage != 2 {
-		return nil, fmt.Errorf("expected stage 2, was %d", jp.Stage)
+// RegeneratePass1Message returns the message Pass1Message returned, so that
+// it may be sent again after a transient failure without restarting the
+// handshake. Its proofs use random nonces, so it is returned from a cache
+// rather than recomputed. It is only available until the reply has been
+// processed.
+func (jp *ThreePassJpake[P, S]) RegeneratePass1Message() (*ThreePassVariant1[P, S], error) {
+	if err := jp.checkRole("RegeneratePass1Message", Initiator); err != nil {
+		return nil, err
 	}
-	if subtle.ConstantTimeCompare(msg.UserID, jp.userID) == 1 {
-		return nil, errors.New("could not verify the validity of the received message")
+	if err := jp.checkStage("RegeneratePass1Message", StageAwaitingPass2); err != nil {
+		return nil, err
+	}
+	if jp.pass1 == nil {
+		return nil, errors.New("the first message was not recorded")
 	}
+	msg := *jp.pass1
+	return &msg, nil
+}
 
-	// validate ZKPs
-	jp.OtherUserID = msg.UserID
+// VerifyPass1Proofs checks the points and Schnorr proofs of a first message
+// as the responder would, without a password or any exchange state, so that
+// a relay can drop malformed messages early. config supplies the hash and
+// any session ID, which must match the endpoints'. Passing it shows only that
+// the sender knows the discrete logarithms of X1G and X2G, not its password.
+func VerifyPass1Proofs[P CurvePoint[P, S], S CurveScalar[S]](msg ThreePassVariant1[P, S], curve Curve[P, S], config *Config) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := config.checkPeerUserID(msg.UserID); err != nil {
+		return rejected(err)
+	}
+	return verifyPass1Proofs(curve, config, msg, curve.NewGeneratorPoint())
+}
 
-	x1Proof := jp.checkZKP(msg.X1ZKP, jp.curve.NewGeneratorPoint(), msg.X1G)
-	x2Proof := jp.checkZKP(msg.X2ZKP, jp.curve.NewGeneratorPoint(), msg.X2G)
-	if !(x1Proof && x2Proof) {
-		return nil, errors.New("could not verify the validity of the received message")
+// verifyPass1Proofs checks both proofs of msg against g, the curve's base
+// point, which is only read and so may be shared with the caller.
+func verifyPass1Proofs[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, msg ThreePassVariant1[P, S], g P) error {
+	if smallOrder(curve, msg.X1G, msg.X2G) {
+		return rejected(ErrSmallOrderPoint)
+	}
+	if err := checkZKPs(curve, config, msg.UserID,
+		zkpStatement[P, S]{"X1ZKP", msg.X1ZKP, g, msg.X1G},
+		zkpStatement[P, S]{"X2ZKP", msg.X2ZKP, g, msg.X2G},
+	); err != nil {
+		return rejected(err)
 	}
+	return nil
+}
 
+// ComputePass2ZKPGenerator returns the generator the responder uses for B and
+// its xs ZKP in pass 2, ownX1G + peerX1G + peerX2G (G3 + G1 + G2).
+func ComputePass2ZKPGenerator[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], ownX1G, peerX1G, peerX2G P) P {
+	generator := curve.NewPoint().Add(ownX1G, peerX1G)
+	return generator.Add(generator, peerX2G)
+}
+
+func (jp *ThreePassJpake[P, S]) GetPass2Message(msg ThreePassVariant1[P, S]) (*ThreePassVariant2[P, S], error) {
+	if err := jp.checkRole("GetPass2Message", Responder); err != nil {
+		return nil, err
+	}
+	if jp.Stage != StageAwaitingPass1 && msg.validate() == nil {
+		if err := jp.checkReplay("GetPass2Message", Codec[P, S]{}.EncodePass1(&msg)); err != nil {
+			return nil, err
+		}
+	}
+	if err := jp.checkStage("GetPass2Message", StageAwaitingPass1); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(msg.Version); err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if err := jp.config.checkPeerUserID(msg.UserID); err != nil {
+		return nil, rejected(err)
+	}
+	if subtle.ConstantTimeCompare(msg.UserID, jp.userID) == 1 {
+		return nil, rejected(ErrUserIDCollision)
+	}
+	g := jp.curve.NewGeneratorPoint()
+	if err := verifyPass1Proofs(jp.curve, jp.config, msg, g); err != nil {
+		return nil, err
+	}
+	jp.OtherUserID = msg.UserID
 	jp.OtherX1G = msg.X1G
 	jp.OtherX2G = msg.X2G
-	jp.Stage = 4
+	jp.setStage(StageAwaitingPass3)
 
-	x3ZKP, err := jp.computeZKP(jp.X1, jp.curve.NewGeneratorPoint(), jp.x1G)
+	x3ZKP, err := jp.computeZKP(jp.X1, g, jp.x1G)
 	if err != nil {
 		return nil, err
 	}
-	x4ZKP, err := jp.computeZKP(jp.X2, jp.curve.NewGeneratorPoint(), jp.x2G)
+	x4ZKP, err := jp.computeZKP(jp.X2, g, jp.x2G)
 	if err != nil {
 		return nil, err
 	}
 
 	// new zkp generator is (G1 + G3 + G4)
-	generator := jp.curve.NewPoint().Add(jp.x1G, msg.X1G)
-	generator = generator.Add(generator, msg.X2G)
-	if jp.curve.Infinity(generator) {
-		return nil, errors.New("could not verify the validity of the received message")
+	generator := ComputePass2ZKPGenerator(jp.curve, jp.x1G, msg.X1G, msg.X2G)
+	if err := checkGenerator(jp.curve, generator); err != nil {
+		return nil, err
 	}
 
 	// B = (G1 + G2 + G3) x [x4*s]
@@ -340,15 +691,39 @@ func (jp *ThreePassJpake[P, S]) GetPass2Message(msg ThreePassVariant1[P, S]) (*T
 		X4ZKP:  x4ZKP,
 		XsZKP:  xsZKP,
 	}
+	jp.transcript = [][]byte{Codec[P, S]{}.EncodePass1(&msg), Codec[P, S]{}.EncodePass2(&pass2Msg)}
 	return &pass2Msg, nil
 }
 
 func (jp *ThreePassJpake[P, S]) GetPass3Message(msg ThreePassVariant2[P, S]) (*ThreePassVariant3[P, S], error) {
-	if jp.Stage != 3 {
-		return nil, fmt.Errorf("expected stage 3, was %d", jp.Stage)
+	if err := jp.checkRole("GetPass3Message", Initiator); err != nil {
+		return nil, err
+	}
+	if jp.Stage != StageAwaitingPass2 && msg.validate() == nil {
+		if err := jp.checkReplay("GetPass3Message", Codec[P, S]{}.EncodePass2(&msg)); err != nil {
+			return nil, err
+		}
+	}
+	if err := jp.checkStage("GetPass3Message", StageAwaitingPass2); err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if err := jp.config.checkPeerUserID(msg.UserID); err != nil {
+		return nil, rejected(err)
 	}
 	if subtle.ConstantTimeCompare(msg.UserID, jp.userID) == 1 {
-		return nil, errors.New("could not verify the validity of the received message")
+		return nil, rejected(ErrUserIDCollision)
+	}
+	if smallOrder(jp.curve, msg.X3G, msg.X4G, msg.B) {
+		return nil, rejected(ErrSmallOrderPoint)
+	}
+	// the peer's points must be its own, not ours echoed back
+	for _, p := range []P{msg.X3G, msg.X4G} {
+		if p.Equal(jp.x1G) == 1 || p.Equal(jp.x2G) == 1 {
+			return nil, rejected(ErrReplayedMessage)
+		}
 	}
 
 	jp.OtherUserID = msg.UserID
@@ -356,19 +731,22 @@ func (jp *ThreePassJpake[P, S]) GetPass3Message(msg ThreePassVariant2[P, S]) (*T
 	// new zkp generator is (G1 + G2 + G3)
 	zkpGenerator := jp.curve.NewPoint().Add(jp.x1G, jp.x2G)
 	zkpGenerator = zkpGenerator.Add(zkpGenerator, msg.X3G)
-	x3Proof := jp.checkZKP(msg.X3ZKP, jp.curve.NewGeneratorPoint(), msg.X3G)
-	x4Proof := jp.checkZKP(msg.X4ZKP, jp.curve.NewGeneratorPoint(), msg.X4G)
-	xsProof := jp.checkZKP(msg.XsZKP, zkpGenerator, msg.B)
-
-	if !(x3Proof && x4Proof && xsProof) {
-		return nil, errors.New("could not verify the validity of the received message")
+	if err := checkGenerator(jp.curve, zkpGenerator); err != nil {
+		return nil, err
+	}
+	if err := jp.checkZKPs(
+		zkpStatement[P, S]{"X3ZKP", msg.X3ZKP, jp.curve.NewGeneratorPoint(), msg.X3G},
+		zkpStatement[P, S]{"X4ZKP", msg.X4ZKP, jp.curve.NewGeneratorPoint(), msg.X4G},
+		zkpStatement[P, S]{"XsZKP", msg.XsZKP, zkpGenerator, msg.B},
+	); err != nil {
+		return nil, rejected(err)
 	}
 
 	// A = (G1 + G3 + G4) x [x2*s]
 	generator := jp.curve.NewPoint().Add(jp.x1G, msg.X3G)
 	generator = generator.Add(generator, msg.X4G)
-	if jp.curve.Infinity(generator) {
-		return nil, errors.New("could not verify the validity of the received message")
+	if err := checkGenerator(jp.curve, generator); err != nil {
+		return nil, err
 	}
 
 	a, err := jp.curve.NewPoint().ScalarMult(generator, jp.x2s)
@@ -385,79 +763,477 @@ func (jp *ThreePassJpake[P, S]) GetPass3Message(msg ThreePassVariant2[P, S]) (*T
 	}
 	jp.OtherX1G = msg.X3G
 	jp.OtherX2G = msg.X4G
-	jp.Stage = 5
+	jp.setStage(StageAwaitingConfirmation1)
 	if err := jp.computeSharedKey(msg.B); err != nil {
 		return nil, err
 	}
+	jp.transcript = append(jp.transcript, Codec[P, S]{}.EncodePass2(&msg), Codec[P, S]{}.EncodePass3(&pass3Msg))
 	return &pass3Msg, nil
 }
 
 func (jp *ThreePassJpake[P, S]) ProcessPass3Message(msg ThreePassVariant3[P, S]) ([]byte, error) {
-	if jp.Stage != 4 {
-		return nil, fmt.Errorf("expected stage 4, was %d", jp.Stage)
+	if err := jp.checkRole("ProcessPass3Message", Responder); err != nil {
+		return nil, err
+	}
+	if jp.Stage != StageAwaitingPass3 && msg.validate() == nil {
+		if err := jp.checkReplay("ProcessPass3Message", Codec[P, S]{}.EncodePass3(&msg)); err != nil {
+			return nil, err
+		}
+	}
+	if err := jp.checkStage("ProcessPass3Message", StageAwaitingPass3); err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	// pass 3 carries no user ID, but a restored exchange may hold a peer ID
+	// that GetPass2Message never checked
+	if subtle.ConstantTimeCompare(jp.OtherUserID, jp.userID) == 1 {
+		return nil, rejected(ErrUserIDCollision)
+	}
+	if smallOrder(jp.curve, msg.A) {
+		return nil, rejected(ErrSmallOrderPoint)
 	}
 	// validate ZKPs
 	tmp1 := jp.curve.NewPoint().Add(jp.x1G, jp.x2G)
 	zkpGenerator := tmp1.Add(tmp1, jp.OtherX1G)
-	xsProof := jp.checkZKP(msg.XsZKP, zkpGenerator, msg.A)
-	if !xsProof {
-		return nil, errors.New("could not verify the validity of the received message")
+	if err := checkGenerator(jp.curve, zkpGenerator); err != nil {
+		return nil, err
+	}
+	if err := jp.checkZKP("XsZKP", msg.XsZKP, zkpGenerator, msg.A); err != nil {
+		return nil, rejected(err)
 	}
 	if err := jp.computeSharedKey(msg.A); err != nil {
 		return nil, err
 	}
-	jp.Stage = 6
-	// MAC(k', "KC_1_U" || Alice || Bob || G1 || G2 || G3 || G4)
-	confirmMsg := concat([]byte("KC_1_U"), jp.userID, jp.OtherUserID, jp.x1G.Bytes(), jp.x2G.Bytes(), jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes())
-	return jp.config.generateConfirmationMac(jp.SessionKey[:], confirmMsg), nil
+	// MAC(k', "KC_1_U" || Alice || Bob || G1 || G2 || G3 || G4 || curve id)
+	confirm1, err := jp.confirmationMac(true)
+	if err != nil {
+		return nil, err
+	}
+	jp.transcript = append(jp.transcript, Codec[P, S]{}.EncodePass3(&msg))
+	jp.setStage(StageAwaitingConfirmation2)
+	return confirm1, nil
 }
 
 func (jp *ThreePassJpake[P, S]) ProcessSessionConfirmation1(confirm1 []byte) ([]byte, error) {
-	if jp.Stage != 5 {
-		return nil, fmt.Errorf("expected stage 5, was %d", jp.Stage)
+	if err := jp.checkRole("ProcessSessionConfirmation1", Initiator); err != nil {
+		return nil, err
 	}
-	expectedMsg := concat([]byte("KC_1_U"), jp.OtherUserID, jp.userID, jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes(), jp.x1G.Bytes(), jp.x2G.Bytes())
-	if subtle.ConstantTimeCompare(confirm1, jp.config.generateConfirmationMac(jp.SessionKey[:], expectedMsg)) != 1 {
-		return nil, errors.New("cannot confirm session")
+	if jp.Stage == StageInitiatorDone {
+		return nil, ErrSessionAlreadyConfirmed
 	}
-	// MAC(k', "KC_1_U" || Bob || Alice || G3 || G4 || G1 || G2)
-	jp.Stage = 7
-	msg := concat([]byte("KC_1_U"), jp.userID, jp.OtherUserID, jp.x1G.Bytes(), jp.x2G.Bytes(), jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes())
-	return jp.config.generateConfirmationMac(jp.SessionKey[:], msg), nil
+	if err := jp.checkStage("ProcessSessionConfirmation1", StageAwaitingConfirmation1); err != nil {
+		return nil, err
+	}
+	if err := jp.checkConfirmation(confirm1); err != nil {
+		return nil, err
+	}
+	// MAC(k', "KC_1_U" || Bob || Alice || G3 || G4 || G1 || G2 || curve id)
+	confirm2, err := jp.confirmationMac(true)
+	if err != nil {
+		return nil, err
+	}
+	jp.setStage(StageInitiatorDone)
+	jp.releaseSessionKey()
+	jp.finish()
+	return confirm2, nil
 }
 
 func (jp *ThreePassJpake[P, S]) ProcessSessionConfirmation2(confirm2 []byte) error {
-	if jp.Stage != 6 {
-		return fmt.Errorf("expected stage 6, was %d", jp.Stage)
+	if err := jp.checkRole("ProcessSessionConfirmation2", Responder); err != nil {
+		return err
+	}
+	if jp.Stage == StageResponderDone {
+		return ErrSessionAlreadyConfirmed
+	}
+	if err := jp.checkStage("ProcessSessionConfirmation2", StageAwaitingConfirmation2); err != nil {
+		return err
 	}
-	expectedMsg := concat([]byte("KC_1_U"), jp.OtherUserID, jp.userID, jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes(), jp.x1G.Bytes(), jp.x2G.Bytes())
-	if subtle.ConstantTimeCompare(confirm2, jp.config.generateConfirmationMac(jp.SessionKey[:], expectedMsg)) != 1 {
-		return errors.New("cannot confirm session")
+	if err := jp.checkConfirmation(confirm2); err != nil {
+		return err
 	}
-	jp.Stage = 8
+	jp.setStage(StageResponderDone)
+	jp.releaseSessionKey()
+	jp.finish()
 	return nil
 }
 
+// ConfirmationMessage returns the confirmation this side owes its peer: for
+// the responder once it has processed pass 3, the same bytes
+// ProcessPass3Message returned, and for the initiator once it has verified
+// the responder's confirmation. At any other stage it returns ErrStage.
+func (jp *ThreePassJpake[P, S]) ConfirmationMessage() ([]byte, error) {
+	switch {
+	case jp.role == Responder && jp.Stage == StageAwaitingConfirmation2:
+	case jp.role == Initiator && jp.Stage == StageInitiatorDone:
+	default:
+		return nil, fmt.Errorf("no confirmation is owed by the %s at stage %s: %w", jp.role, jp.Stage, ErrStage)
+	}
+	return jp.confirmationMac(true)
+}
+
+// VerifyConfirmation verifies the peer's confirmation with
+// ProcessSessionConfirmation1 or ProcessSessionConfirmation2, as this side's
+// role requires. It returns the confirmation to send in reply, or nil once
+// nothing further is owed.
+func (jp *ThreePassJpake[P, S]) VerifyConfirmation(peer []byte) ([]byte, error) {
+	if jp.role == Initiator {
+		return jp.ProcessSessionConfirmation1(peer)
+	}
+	return nil, jp.ProcessSessionConfirmation2(peer)
+}
+
+// checkConfirmation compares the peer's confirmation MAC with the expected
+// one, returning ErrMalformedConfirmation if the lengths differ and
+// ErrPasswordMismatch if the contents do.
+func (jp *ThreePassJpake[P, S]) checkConfirmation(confirm []byte) error {
+	expected, err := jp.confirmationMac(false)
+	if err != nil {
+		return err
+	}
+	if len(confirm) != len(expected) {
+		return fmt.Errorf("%w: got %d bytes, expected %d", ErrMalformedConfirmation, len(confirm), len(expected))
+	}
+	// compared in constant time, bytes.Equal would return at the first
+	// differing byte and let the peer learn the expected MAC a byte at a time
+	if subtle.ConstantTimeCompare(confirm, expected) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// confirmationMac computes the confirmation MAC sent by this side when own is
+// true, or the one expected from the peer otherwise. The curve name and order
+// are included so a confirmation computed on one curve never verifies on
+// another, followed by the config's confirmation context, if any. It returns
+// ErrConfirmationUnavailable if the config's confirmation function needs the
+// shared point and this side does not hold it.
+func (jp *ThreePassJpake[P, S]) confirmationMac(own bool) ([]byte, error) {
+	if jp.config.confirmationFn != nil {
+		if isNil(jp.sharedPoint) {
+			return nil, ErrConfirmationUnavailable
+		}
+		// the responder sends the first confirmation
+		first := own == (jp.role == Responder)
+		return jp.config.confirmationFn(jp.sharedPoint.Bytes(), first), nil
+	}
+	curveID := concat([]byte(jp.curve.Name()), jp.curve.Params().N.Bytes())
+	var parts [][]byte
+	if own {
+		parts = [][]byte{[]byte("KC_1_U"), jp.userID, jp.OtherUserID, jp.x1G.Bytes(), jp.x2G.Bytes(), jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes(), curveID}
+	} else {
+		parts = [][]byte{[]byte("KC_1_U"), jp.OtherUserID, jp.userID, jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes(), jp.x1G.Bytes(), jp.x2G.Bytes(), curveID}
+	}
+	if len(jp.config.confirmationContext) != 0 {
+		parts = append(parts, jp.config.confirmationContext)
+	}
+	msg := concat(jp.config.withSessionID(parts...)...)
+	return jp.config.generateConfirmationMac(jp.confirmationKey(), msg), nil
+}
+
+// confirmationKey returns the key confirmation MACs are derived from. When the
+// config binds the confirmation key to the exchanged points it is derived from
+// the session key and a hash of G1 || G2 || G3 || G4, ordered initiator first
+// so that both sides agree.
+func (jp *ThreePassJpake[P, S]) confirmationKey() []byte {
+	if !jp.config.bindConfirmationKeyToPoints {
+		return jp.sessionKey
+	}
+	var points []byte
+	if jp.role == Initiator {
+		points = concat(jp.x1G.Bytes(), jp.x2G.Bytes(), jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes())
+	} else {
+		points = concat(jp.OtherX1G.Bytes(), jp.OtherX2G.Bytes(), jp.x1G.Bytes(), jp.x2G.Bytes())
+	}
+	return jp.config.macFn(jp.sessionKey, jp.config.hashFn(points))
+}
+
 func (jp *ThreePassJpake[P, S]) computeSharedKey(p P) error {
+	k, err := computeSharedPoint(jp.curve, p, jp.OtherX2G, jp.x2s, jp.X2)
+	if err != nil {
+		return err
+	}
+	input := k.Bytes()
+	if len(jp.additionalSecret) != 0 {
+		input = concat(input, jp.additionalSecret)
+	}
+	sessionKey := DeriveSessionKeyFromSharedPoint(input, jp.config)
+	if allZero(sessionKey) {
+		return ErrWeakSessionKey
+	}
+	jp.sharedPoint = k
+	jp.sessionKey = sessionKey
+	jp.releaseSessionKey()
+	if jp.config.observer != nil {
+		jp.config.observer.OnSessionKeyDerived(jp.config.sessionKeyID(sessionKey))
+	}
+	return nil
+}
+
+// computeSharedKey derives the session key from the peer's A or B value p,
+// the peer's second point and our own x2*s and x2.
+func computeSharedKey[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, p, otherX2G P, x2s, x2 S) ([]byte, error) {
+	_, sessionKey, err := computeSharedPointAndKey(curve, config, p, otherX2G, x2s, x2)
+	return sessionKey, err
+}
+
+// computeSharedPointAndKey is computeSharedKey, also returning the shared
+// point the key is derived from.
+func computeSharedPointAndKey[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, p, otherX2G P, x2s, x2 S) (P, []byte, error) {
+	k, err := computeSharedPoint(curve, p, otherX2G, x2s, x2)
+	if err != nil {
+		return *new(P), nil, err
+	}
+	sessionKey := DeriveSessionKeyFromSharedPoint(k.Bytes(), config)
+	if allZero(sessionKey) {
+		return *new(P), nil, ErrWeakSessionKey
+	}
+	return k, sessionKey, nil
+}
+
+// computeSharedPoint computes the shared point, see computeSharedKey.
+func computeSharedPoint[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], p, otherX2G P, x2s, x2 S) (P, error) {
 	// compute either
 	// (B - (G4 x [x2*s])) x [x2]
 	// (A - (G2 x [x4*s])) x [x4]
-	otherx2gX2s, err := jp.curve.NewPoint().ScalarMult(jp.OtherX2G, jp.x2s)
+	otherx2gX2s, err := curve.NewPoint().ScalarMult(otherX2G, x2s)
 	if err != nil {
-		return err
+		return *new(P), err
 	}
 
 	// A - (G2 x [x4*s])
-	k := jp.curve.NewPoint().Subtract(p, otherx2gX2s)
+	k := curve.NewPoint().Subtract(p, otherx2gX2s)
 	// Kb = (A - (G2 x [x4*s])) x [x4]
-	if _, err = k.ScalarMult(k, jp.X2); err != nil {
-		return err
+	if k, err = k.ScalarMult(k, x2); err != nil {
+		return *new(P), err
+	}
+	if curve.Infinity(k) || allZero(k.Bytes()) {
+		return *new(P), ErrWeakSessionKey
+	}
+	return k, nil
+}
+
+// confirmed reports whether this side has completed key confirmation.
+func (jp *ThreePassJpake[P, S]) confirmed() bool {
+	return jp.Stage == StageInitiatorDone || jp.Stage == StageResponderDone
+}
+
+// finish runs once this side has completed key confirmation.
+func (jp *ThreePassJpake[P, S]) finish() {
+	if jp.config.autoZeroizeEphemerals {
+		jp.zeroizeScalars()
+	}
+}
+
+// zeroizeScalars overwrites the private scalars in place. Only the session key
+// remains usable afterwards.
+func (jp *ThreePassJpake[P, S]) zeroizeScalars() {
+	zeroize(jp.X1, jp.X2, jp.S, jp.x2s)
+}
+
+// Destroy overwrites the private scalars and the session key with zeros. The
+// exchange cannot be used afterwards; every method returns an error.
+func (jp *ThreePassJpake[P, S]) Destroy() {
+	jp.zeroizeScalars()
+	zeroizeBytes(jp.additionalSecret)
+	jp.additionalSecret = nil
+	zeroizeBytes(jp.sessionKey)
+	zeroizeBytes(jp.SessionKey)
+	jp.sessionKey = nil
+	jp.SessionKey = nil
+	var zero P
+	jp.sharedPoint = zero
+	jp.setStage(StageDestroyed)
+}
+
+// zeroize sets each scalar to zero in place. Every backend provides a
+// Zeroize method which overwrites its whole representation; for other
+// curves the scalar is set to zero with SetBigInt.
+func zeroize[S CurveScalar[S]](scalars ...S) {
+	for _, s := range scalars {
+		if isNil(s) {
+			continue
+		}
+		if z, ok := any(s).(interface{ Zeroize() }); ok {
+			z.Zeroize()
+		} else {
+			_, _ = s.SetBigInt(new(big.Int))
+		}
+	}
+}
+
+func (jp *ThreePassJpake[P, S]) releaseSessionKey() {
+	if !jp.config.requireKeyConfirmation || jp.confirmed() {
+		// a copy, so that writes to the exported field cannot change the key
+		// confirmation is computed from
+		jp.SessionKey = copyBytes(jp.sessionKey)
+	}
+}
+
+// Key returns a copy of the derived session key. If the config requires key
+// confirmation, ErrAwaitingConfirmation is returned until this side has
+// completed confirmation.
+func (jp *ThreePassJpake[P, S]) Key() ([]byte, error) {
+	if jp.config.requireKeyConfirmation && !jp.confirmed() {
+		return nil, ErrAwaitingConfirmation
 	}
+	if len(jp.sessionKey) == 0 {
+		return nil, errors.New("session key has not been derived")
+	}
+	return append([]byte{}, jp.sessionKey...), nil
+}
 
-	jp.SessionKey = jp.config.generateSessionKey(k.Bytes())
+// DirectionalKeys derives separate keys for each direction of a channel from
+// the session key with the config's mac function, so that a message cannot be
+// reflected back to its sender. The initiator's send key is the responder's
+// receive key and vice versa. The keys are withheld like Key.
+func (jp *ThreePassJpake[P, S]) DirectionalKeys() (sendKey, recvKey []byte, err error) {
+	key, err := jp.Key()
+	if err != nil {
+		return nil, nil, err
+	}
+	i2r := jp.config.macFn(key, []byte("JPAKE_KEY_INITIATOR_TO_RESPONDER"))
+	r2i := jp.config.macFn(key, []byte("JPAKE_KEY_RESPONDER_TO_INITIATOR"))
+	if jp.role == Initiator {
+		return i2r, r2i, nil
+	}
+	return r2i, i2r, nil
+}
+
+// SetAdditionalSecret mixes secret, such as the shared secret of a separate
+// post-quantum KEM, into the session key alongside the shared point, so
+// that the key stays secret unless both are broken. Both sides must supply
+// the same secret, or key confirmation fails. It must be set before the
+// session key is derived and is kept by RetryWithFreshEphemerals and Reset,
+// but is not part of MarshalBinary's state.
+func (jp *ThreePassJpake[P, S]) SetAdditionalSecret(secret []byte) error {
+	if jp.Stage >= StageAwaitingConfirmation1 || jp.Stage == StageDestroyed {
+		return fmt.Errorf("cannot set an additional secret at stage %s: %w", jp.Stage, ErrStage)
+	}
+	jp.additionalSecret = copyBytes(secret)
 	return nil
 }
 
+// Ratchet replaces the session key with one derived from it by the config's
+// session key KDF and a counter of previous ratchets, zeroizing the old key,
+// and returns a copy of the new key. Both sides hold the same key as long as
+// they have called Ratchet the same number of times. This is a KDF chain
+// rather than a new key agreement: it gives cheap key rotation and protects
+// earlier keys from a later compromise, but not later keys. The key is
+// withheld like Key. The counter is returned by RatchetCount and kept by
+// MarshalBinary, and must be passed back when restoring.
+func (jp *ThreePassJpake[P, S]) Ratchet() ([]byte, error) {
+	if _, err := jp.Key(); err != nil {
+		return nil, err
+	}
+	key := jp.config.ratchetSessionKey(jp.sessionKey, jp.ratchets)
+	zeroizeBytes(jp.sessionKey)
+	jp.sessionKey = key
+	jp.ratchets++
+	jp.releaseSessionKey()
+	return copyBytes(key), nil
+}
+
+// RatchetCount returns the number of times Ratchet has been called since the
+// session key was derived.
+func (jp *ThreePassJpake[P, S]) RatchetCount() uint64 {
+	return jp.ratchets
+}
+
+// Transcript returns a hash committing to every public value of the
+// handshake: both user IDs, the four points and their proofs, A, B and the
+// proofs for them. It is hash("JPAKE_TRANSCRIPT" || pass 1 || pass 2 ||
+// pass 3) over the zero value Codec encodings, length prefixed with concat,
+// and is the same on both sides, allowing an outer protocol to bind to the
+// handshake. It is available once this side has processed all three
+// messages. The messages are not part of MarshalBinary's state, so a
+// restored exchange has no transcript.
+func (jp *ThreePassJpake[P, S]) Transcript() ([]byte, error) {
+	if jp.Stage < StageAwaitingConfirmation1 {
+		return nil, fmt.Errorf("no transcript at stage %s: %w", jp.Stage, ErrStage)
+	}
+	if len(jp.transcript) != 3 {
+		return nil, errors.New("transcript was not recorded")
+	}
+	return jp.config.hashFn(concat(append([][]byte{[]byte("JPAKE_TRANSCRIPT")}, jp.transcript...)...)), nil
+}
+
+// KeyFingerprint returns a short, non-secret identifier of the session key
+// for logging or display: the first 8 bytes of hash(session key ||
+// "FINGERPRINT"). Both sides derive the same fingerprint. It is only
+// available once this side has completed key confirmation.
+func (jp *ThreePassJpake[P, S]) KeyFingerprint() ([]byte, error) {
+	if !jp.confirmed() {
+		return nil, ErrAwaitingConfirmation
+	}
+	return jp.config.hashFn(append(append([]byte{}, jp.sessionKey...), "FINGERPRINT"...))[:8], nil
+}
+
+// EqualityProof returns a MAC under the session key over a fixed challenge,
+// bound to this side's role and both user IDs, which the peer checks with
+// VerifyEqualityProof to learn whether both sides hold the same key. Unlike
+// the confirmation messages it is available whenever a session key is held,
+// including after RestoreThreePassJpake skipped confirmation. It returns nil
+// if no session key has been derived.
+func (jp *ThreePassJpake[P, S]) EqualityProof() []byte {
+	return jp.equalityProof(jp.role, jp.userID, jp.OtherUserID)
+}
+
+// VerifyEqualityProof reports whether peer is the peer's EqualityProof for the
+// session key held by this side, comparing in constant time. Our own proof is
+// not accepted, so a reflected proof does not verify.
+func (jp *ThreePassJpake[P, S]) VerifyEqualityProof(peer []byte) bool {
+	peerRole := Responder
+	if jp.role == Responder {
+		peerRole = Initiator
+	}
+	expected := jp.equalityProof(peerRole, jp.OtherUserID, jp.userID)
+	return expected != nil && subtle.ConstantTimeCompare(expected, peer) == 1
+}
+
+func (jp *ThreePassJpake[P, S]) equalityProof(sender Role, senderID, receiverID []byte) []byte {
+	if len(jp.sessionKey) == 0 {
+		return nil
+	}
+	msg := concat([]byte("JPAKE_EQUALITY_PROOF"), []byte(sender.String()), senderID, receiverID)
+	return jp.config.macFn(jp.sessionKey, msg)
+}
+
+// SharedPointX25519 returns the shared point the session key is derived
+// from as an X25519 u-coordinate, using the birational map from edwards25519
+// to its Montgomery form. Both sides obtain the same value, allowing it to
+// key protocols which take an X25519 shared secret. It is only available on
+// curve25519, is withheld like Key until confirmation if the config requires
+// it, and is not preserved by MarshalBinary.
+func (jp *ThreePassJpake[P, S]) SharedPointX25519() ([]byte, error) {
+	if jp.config.requireKeyConfirmation && !jp.confirmed() {
+		return nil, ErrAwaitingConfirmation
+	}
+	if isNil(jp.sharedPoint) {
+		return nil, errors.New("shared point has not been computed")
+	}
+	m, ok := any(jp.sharedPoint).(interface{ BytesMontgomery() []byte })
+	if !ok {
+		return nil, fmt.Errorf("X25519 output is only available on curve25519, not %s", jp.curve.Name())
+	}
+	return m.BytesMontgomery(), nil
+}
+
+func zeroizeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func allZero(b []byte) bool {
+	var acc byte
+	for _, v := range b {
+		acc |= v
+	}
+	return acc == 0
+}
+
 func sha256HashFn(in []byte) []byte {
 	hash := sha256.Sum256(in)
 	return hash[:]
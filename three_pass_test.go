@@ -2,15 +2,26 @@ package jpake
 
 import (
 	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	math_rand "math/rand"
+	"reflect"
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/curve25519"
 )
 
 func TestJpake3Pass(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -43,11 +54,11 @@ func TestJpake3Pass(t *testing.T) {
 }
 
 func TestJpake3PassDifferentPasswords(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password2"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password2"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -72,11 +83,11 @@ func TestJpake3PassDifferentPasswords(t *testing.T) {
 }
 
 func TestJpake3PassDifferentConfirmation1(t *testing.T) {
-	jpake1, err := InitThreePassJpakeWithConfig(true, []byte("one"), []byte("password"), NewConfig().SetSessionConfirmationBytes([]byte("CONFIRM1")))
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetSessionConfirmationBytes([]byte("CONFIRM1")))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpakeWithConfig(false, []byte("two"), []byte("password"), NewConfig().SetSessionConfirmationBytes([]byte("CONFIRM2")))
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetSessionConfirmationBytes([]byte("CONFIRM2")))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -106,11 +117,11 @@ func TestJpake3PassDifferentConfirmation1(t *testing.T) {
 }
 
 func TestJpake3PassDifferentConfirmation2(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -139,11 +150,11 @@ func TestJpake3PassDifferentConfirmation2(t *testing.T) {
 }
 
 func TestJpake3PassSameUserIDsPass2(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("one"), []byte("password2"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("one"), []byte("password2"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -159,11 +170,11 @@ func TestJpake3PassSameUserIDsPass2(t *testing.T) {
 }
 
 func TestJpake3PassSameUserIDsPass3(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -183,11 +194,11 @@ func TestJpake3PassSameUserIDsPass3(t *testing.T) {
 }
 
 func TestJpake3PassWithInfinityX1gPoint(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -203,11 +214,11 @@ func TestJpake3PassWithInfinityX1gPoint(t *testing.T) {
 }
 
 func TestJpake3PassWithInfinityX2gPoint(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -223,11 +234,11 @@ func TestJpake3PassWithInfinityX2gPoint(t *testing.T) {
 }
 
 func TestJpake3PassWithInfinityX3gPoint(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -248,11 +259,11 @@ func TestJpake3PassWithInfinityX3gPoint(t *testing.T) {
 }
 
 func TestJpake3PassWithInfinityX4gPoint(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -272,11 +283,11 @@ func TestJpake3PassWithInfinityX4gPoint(t *testing.T) {
 }
 
 func TestJpake3PassWithInfinityTPoint(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -292,11 +303,11 @@ func TestJpake3PassWithInfinityTPoint(t *testing.T) {
 }
 
 func TestJpake3PassWithZeroR(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -312,11 +323,11 @@ func TestJpake3PassWithZeroR(t *testing.T) {
 }
 
 func TestJpake3Restore(t *testing.T) {
-	jpake1, err := InitThreePassJpake(true, []byte("one"), []byte("password"))
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake1: %v", err)
 	}
-	jpake2, err := InitThreePassJpake(false, []byte("two"), []byte("password"))
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
 	if err != nil {
 		t.Fatalf("error init jpake2: %v", err)
 	}
@@ -324,7 +335,7 @@ func TestJpake3Restore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting pass1: %v", err)
 	}
-	restoredJpake2, err := RestoreThreePassJpake(jpake2.Stage, []byte("two"), jpake2.OtherUserID, jpake2.SessionKey, jpake2.X1, jpake2.X2, jpake2.S, jpake2.OtherX1G, jpake2.OtherX2G)
+	restoredJpake2, err := RestoreThreePassJpake(jpake2.Stage, []byte("two"), jpake2.OtherUserID, jpake2.SessionKey, 0, jpake2.X1, jpake2.X2, jpake2.S, jpake2.OtherX1G, jpake2.OtherX2G)
 	if err != nil {
 		t.Fatalf("error restoring jpake2: %v", err)
 	}
@@ -332,7 +343,7 @@ func TestJpake3Restore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting pass2: %v", err)
 	}
-	restoredJpake1, err := RestoreThreePassJpake(jpake1.Stage, []byte("one"), jpake1.OtherUserID, jpake1.SessionKey, jpake1.X1, jpake1.X2, jpake1.S, jpake1.OtherX1G, jpake1.OtherX2G)
+	restoredJpake1, err := RestoreThreePassJpake(jpake1.Stage, []byte("one"), jpake1.OtherUserID, jpake1.SessionKey, 0, jpake1.X1, jpake1.X2, jpake1.S, jpake1.OtherX1G, jpake1.OtherX2G)
 	if err != nil {
 		t.Fatalf("error restoring jpake2: %v", err)
 	}
@@ -340,7 +351,7 @@ func TestJpake3Restore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting pass3: %v", err)
 	}
-	restoredJpake2, err = RestoreThreePassJpake(restoredJpake2.Stage, []byte("two"), restoredJpake2.OtherUserID, restoredJpake2.SessionKey, restoredJpake2.X1, restoredJpake2.X2, restoredJpake2.S, restoredJpake2.OtherX1G, restoredJpake2.OtherX2G)
+	restoredJpake2, err = RestoreThreePassJpake(restoredJpake2.Stage, []byte("two"), restoredJpake2.OtherUserID, restoredJpake2.SessionKey, 0, restoredJpake2.X1, restoredJpake2.X2, restoredJpake2.S, restoredJpake2.OtherX1G, restoredJpake2.OtherX2G)
 	if err != nil {
 		t.Fatalf("error restoring jpake2: %v", err)
 	}
@@ -348,7 +359,7 @@ func TestJpake3Restore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error processing pass3: %v", err)
 	}
-	restoredJpake1, err = RestoreThreePassJpake(restoredJpake1.Stage, []byte("one"), restoredJpake1.OtherUserID, restoredJpake1.SessionKey, restoredJpake1.X1, restoredJpake1.X2, restoredJpake1.S, restoredJpake1.OtherX1G, restoredJpake1.OtherX2G)
+	restoredJpake1, err = RestoreThreePassJpake(restoredJpake1.Stage, []byte("one"), restoredJpake1.OtherUserID, restoredJpake1.SessionKey, 0, restoredJpake1.X1, restoredJpake1.X2, restoredJpake1.S, restoredJpake1.OtherX1G, restoredJpake1.OtherX2G)
 	if err != nil {
 		t.Fatalf("error restoring jpake2: %v", err)
 	}
@@ -356,7 +367,7 @@ func TestJpake3Restore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting conf2: %v", err)
 	}
-	restoredJpake2, err = RestoreThreePassJpake(restoredJpake2.Stage, []byte("two"), restoredJpake2.OtherUserID, restoredJpake2.SessionKey, restoredJpake2.X1, restoredJpake2.X2, restoredJpake2.S, restoredJpake2.OtherX1G, restoredJpake2.OtherX2G)
+	restoredJpake2, err = RestoreThreePassJpake(restoredJpake2.Stage, []byte("two"), restoredJpake2.OtherUserID, restoredJpake2.SessionKey, 0, restoredJpake2.X1, restoredJpake2.X2, restoredJpake2.S, restoredJpake2.OtherX1G, restoredJpake2.OtherX2G)
 	if err != nil {
 		t.Fatalf("error restoring jpake2: %v", err)
 	}
@@ -368,3 +379,2885 @@ func TestJpake3Restore(t *testing.T) {
 		t.Fatalf("expected session key %x to be equal to %x", restoredJpake1.SessionKey, restoredJpake2.SessionKey)
 	}
 }
+
+func TestJpake3RestoreInvalidStage(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	g := Curve25519Curve{}.NewGeneratorPoint()
+	key := []byte("key")
+	for _, tc := range []struct {
+		name        string
+		stage       Stage
+		otherUserID []byte
+		sessionKey  []byte
+		otherX1G    *Curve25519Point
+		otherX2G    *Curve25519Point
+		expected    string
+	}{
+		{"destroyed", StageDestroyed, nil, nil, nil, nil, "cannot restore at stage destroyed"},
+		{"negative", Stage(-1), nil, nil, nil, nil, "cannot restore at stage Stage(-1)"},
+		{"past done", Stage(99), nil, nil, nil, nil, "cannot restore at stage Stage(99)"},
+		{"missing otherx1g", StageAwaitingPass3, []byte("one"), nil, nil, g, "otherx1g is required at stage awaiting pass 3"},
+		{"missing otherx2g", StageAwaitingConfirmation1, []byte("two"), key, g, nil, "otherx2g is required at stage awaiting confirmation 1"},
+		{"missing other user id", StageAwaitingConfirmation2, nil, key, g, g, "other user id is required at stage awaiting confirmation 2"},
+		{"missing session key", StageInitiatorDone, []byte("two"), nil, g, g, "session key is required at stage initiator done"},
+	} {
+		_, err := RestoreThreePassJpake(tc.stage, []byte("one"), tc.otherUserID, tc.sessionKey, 0, jpake1.X1, jpake1.X2, jpake1.S, tc.otherX1G, tc.otherX2G)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("%s: expected error %q, instead got: %v", tc.name, tc.expected, err)
+		}
+	}
+	if _, err := RestoreThreePassJpake(Stage(0), []byte("one"), nil, nil, 0, jpake1.X1, jpake1.X2, jpake1.S, nil, nil); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage, instead got: %v", err)
+	}
+	if _, err := RestoreThreePassJpake(StageAwaitingPass2, []byte("one"), nil, nil, 0, jpake1.X1, jpake1.X2, jpake1.S, nil, nil); err != nil {
+		t.Fatalf("error restoring before the peer's points are known: %v", err)
+	}
+}
+
+func TestJpake3PassZKPVerificationObserver(t *testing.T) {
+	var names []string
+	config := NewConfig().SetZKPVerificationObserver(func(fieldName string, tBytes, c []byte, ok bool) {
+		if !ok {
+			t.Errorf("expected %s to verify", fieldName)
+		}
+		if len(tBytes) == 0 || len(c) == 0 {
+			t.Errorf("expected t and c for %s, got %x and %x", fieldName, tBytes, c)
+		}
+		names = append(names, fieldName)
+	})
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(*msg2); err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	expected := []string{"X1ZKP", "X2ZKP", "X3ZKP", "X4ZKP", "XsZKP"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected observed fields %v, got %v", expected, names)
+	}
+}
+
+func TestJpake3PassSHA512HashFn(t *testing.T) {
+	config := NewConfig().SetHashFn(func(in []byte) []byte {
+		hash := sha512.Sum512(in)
+		return hash[:]
+	})
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassRequireKeyConfirmation(t *testing.T) {
+	config := NewConfig().SetRequireKeyConfirmation(true)
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	assertWithheld := func(jp *ThreePassJpake[*Curve25519Point, *Curve25519Scalar]) {
+		t.Helper()
+		if _, err := jp.Key(); !errors.Is(err, ErrAwaitingConfirmation) {
+			t.Fatalf("expected ErrAwaitingConfirmation, instead got: %v", err)
+		}
+		if len(jp.SessionKey) != 0 {
+			t.Fatalf("expected session key to be withheld, was %x", jp.SessionKey)
+		}
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	assertWithheld(jpake1)
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	assertWithheld(jpake2)
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	assertWithheld(jpake2)
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	key1, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+	key2, err := jpake2.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if !bytes.Equal(key1, key2) || !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, key2)
+	}
+}
+
+func TestJpake3PassReplayedConfirmation(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); !errors.Is(err, ErrSessionAlreadyConfirmed) {
+		t.Fatalf("expected ErrSessionAlreadyConfirmed replaying conf1, instead got: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); !errors.Is(err, ErrSessionAlreadyConfirmed) {
+		t.Fatalf("expected ErrSessionAlreadyConfirmed replaying conf2, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassKMACSessionKey(t *testing.T) {
+	config := NewConfig().SetKMACSessionKeyDerivation([]byte("jpake test"))
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+
+	// recompute the shared point to compare against the default derivation
+	k, err := jpake2.curve.NewPoint().ScalarMult(jpake2.OtherX2G, jpake2.x2s)
+	if err != nil {
+		t.Fatalf("error computing shared point: %v", err)
+	}
+	k.Subtract(msg3.A, k)
+	k.ScalarMult(k, jpake2.X2)
+	if !bytes.Equal(jpake2.SessionKey, config.generateSessionKey(k.Bytes())) {
+		t.Fatalf("expected recomputed kmac session key to match")
+	}
+	if bytes.Equal(jpake2.SessionKey, NewConfig().generateSessionKey(k.Bytes())) {
+		t.Fatalf("expected kmac session key to differ from the default derivation")
+	}
+}
+
+func TestJpake3PassMissingPoints(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	missingX1G := *msg1
+	missingX1G.X1G = nil
+	if _, err := jpake2.GetPass2Message(missingX1G); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage, instead got: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	missingB := *msg2
+	missingB.B = nil
+	if _, err := jpake1.GetPass3Message(missingB); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage, instead got: %v", err)
+	}
+	missingR := *msg2
+	missingR.X4ZKP.R = nil
+	if _, err := jpake1.GetPass3Message(missingR); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage, instead got: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	missingA := *msg3
+	missingA.A = nil
+	if _, err := jpake2.ProcessPass3Message(missingA); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassComputePass2ZKPGenerator(t *testing.T) {
+	curve := Curve25519Curve{}
+	scalar := func(i int64) *Curve25519Scalar {
+		s, err := curve.NewScalar().SetBigInt(big.NewInt(i))
+		if err != nil {
+			t.Fatalf("error creating scalar: %v", err)
+		}
+		return s
+	}
+	jpake1, err := RestoreThreePassJpake(1, []byte("one"), nil, nil, 0, scalar(11), scalar(12), scalar(13), nil, nil)
+	if err != nil {
+		t.Fatalf("error restoring jpake1: %v", err)
+	}
+	jpake2, err := RestoreThreePassJpake(2, []byte("two"), nil, nil, 0, scalar(21), scalar(22), scalar(13), nil, nil)
+	if err != nil {
+		t.Fatalf("error restoring jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+
+	generator := ComputePass2ZKPGenerator[*Curve25519Point, *Curve25519Scalar](curve, msg2.X3G, msg1.X1G, msg1.X2G)
+	// (11 + 12 + 21) x G
+	expected, _ := curve.NewPoint().ScalarBaseMult(scalar(44))
+	if generator.Equal(expected) != 1 {
+		t.Fatalf("expected generator %x, got %x", expected.Bytes(), generator.Bytes())
+	}
+	// B = generator x [x4*s]
+	b, _ := curve.NewPoint().ScalarMult(generator, scalar(22*13))
+	if b.Equal(msg2.B) != 1 {
+		t.Fatalf("expected B %x, got %x", b.Bytes(), msg2.B.Bytes())
+	}
+	jpake1.OtherUserID = msg2.UserID
+	if err := jpake1.checkZKP("XsZKP", msg2.XsZKP, generator, msg2.B); err != nil {
+		t.Fatalf("expected xs ZKP to verify against the computed generator, got: %v", err)
+	}
+}
+
+// valuePoint is a point type that is not a pointer, as a custom curve may
+// define, wrapping curve25519.
+type valuePoint struct{ p *Curve25519Point }
+
+func (v valuePoint) Size() int { return v.p.Size() }
+
+func (v valuePoint) Add(r1, r2 valuePoint) valuePoint {
+	return valuePoint{new(Curve25519Point).Add(r1.p, r2.p)}
+}
+
+func (v valuePoint) Subtract(r1, r2 valuePoint) valuePoint {
+	return valuePoint{new(Curve25519Point).Subtract(r1.p, r2.p)}
+}
+
+func (v valuePoint) ScalarBaseMult(s *Curve25519Scalar) (valuePoint, error) {
+	p, err := new(Curve25519Point).ScalarBaseMult(s)
+	return valuePoint{p}, err
+}
+
+func (v valuePoint) ScalarMult(q valuePoint, s *Curve25519Scalar) (valuePoint, error) {
+	p, err := new(Curve25519Point).ScalarMult(q.p, s)
+	return valuePoint{p}, err
+}
+
+func (v valuePoint) Bytes() []byte { return v.p.Bytes() }
+
+func (v valuePoint) SetBytes(b []byte) (valuePoint, error) {
+	p, err := new(Curve25519Point).SetBytes(b)
+	return valuePoint{p}, err
+}
+
+func (v valuePoint) Equal(q valuePoint) int { return v.p.Equal(q.p) }
+
+type valuePointCurve struct{ Curve25519Curve }
+
+func (valuePointCurve) NewGeneratorPoint() valuePoint {
+	return valuePoint{Curve25519Curve{}.NewGeneratorPoint()}
+}
+
+func (valuePointCurve) NewPoint() valuePoint {
+	return valuePoint{Curve25519Curve{}.NewPoint()}
+}
+
+func (valuePointCurve) Infinity(p valuePoint) bool {
+	return Curve25519Curve{}.Infinity(p.p)
+}
+
+func TestJpake3PassValuePointCurve(t *testing.T) {
+	config := NewConfig()
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[valuePoint, *Curve25519Scalar](Initiator, []byte("one"), []byte("password"), valuePointCurve{}, config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[valuePoint, *Curve25519Scalar](Responder, []byte("two"), []byte("password"), valuePointCurve{}, config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error processing conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+func TestJpake3PassRetryWithFreshEphemerals(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	// pass2 is lost in transit, both sides retry
+	s1, s2 := jpake1.S.Bytes(), jpake2.S.Bytes()
+	oldX1 := jpake1.X1.Bytes()
+	if err := jpake1.RetryWithFreshEphemerals(); err != nil {
+		t.Fatalf("error retrying jpake1: %v", err)
+	}
+	if err := jpake2.RetryWithFreshEphemerals(); err != nil {
+		t.Fatalf("error retrying jpake2: %v", err)
+	}
+	if jpake1.Stage != 1 || jpake2.Stage != 2 {
+		t.Fatalf("expected stages 1 and 2, got %d and %d", jpake1.Stage, jpake2.Stage)
+	}
+	if !bytes.Equal(s1, jpake1.S.Bytes()) || !bytes.Equal(s2, jpake2.S.Bytes()) {
+		t.Fatalf("expected s to be reused")
+	}
+	if bytes.Equal(oldX1, jpake1.X1.Bytes()) {
+		t.Fatalf("expected x1 to be regenerated")
+	}
+	msg1, err = jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+type renamedCurve25519 struct {
+	Curve25519Curve
+}
+
+func (c renamedCurve25519) Name() string {
+	return "renamed-curve25519"
+}
+
+func TestJpake3PassConfirmationBindsCurve(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[*Curve25519Point, *Curve25519Scalar](Responder, []byte("two"), []byte("password"), renamedCurve25519{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error confirming across curves, instead got nil")
+	}
+}
+
+func TestJpake3PassWeakSessionKey(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	// A = G2 x [x4*s] collapses the shared point to the identity
+	a, err := Curve25519Curve{}.NewPoint().ScalarMult(jpake2.OtherX2G, jpake2.x2s)
+	if err != nil {
+		t.Fatalf("error computing degenerate point: %v", err)
+	}
+	if err := jpake2.computeSharedKey(a); !errors.Is(err, ErrWeakSessionKey) {
+		t.Fatalf("expected ErrWeakSessionKey, instead got: %v", err)
+	}
+	if len(jpake2.SessionKey) != 0 {
+		t.Fatalf("expected no session key, was %x", jpake2.SessionKey)
+	}
+
+	jpake2.config = NewConfig().SetMacFn(func(key, msg []byte) []byte {
+		return make([]byte, 32)
+	})
+	if err := jpake2.computeSharedKey(jpake2.x1G); !errors.Is(err, ErrWeakSessionKey) {
+		t.Fatalf("expected ErrWeakSessionKey for an all zero session key, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassBindConfirmationKeyToPoints(t *testing.T) {
+	config := NewConfig().SetBindConfirmationKeyToPoints(true)
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if !bytes.Equal(jpake1.confirmationKey(), jpake2.confirmationKey()) {
+		t.Fatalf("expected confirmation keys to be equal")
+	}
+	if bytes.Equal(jpake1.confirmationKey(), jpake1.sessionKey) {
+		t.Fatalf("expected confirmation key to differ from the session key")
+	}
+	confirmationKey := jpake1.confirmationKey()
+	otherX2G := jpake1.OtherX2G
+	jpake1.OtherX2G = jpake1.x2G
+	if bytes.Equal(confirmationKey, jpake1.confirmationKey()) {
+		t.Fatalf("expected substituted point to change the confirmation key")
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected error confirming with a substituted point, instead got nil")
+	}
+	jpake1.OtherX2G = otherX2G
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+}
+
+func TestJpake3PassAutoZeroizeEphemerals(t *testing.T) {
+	config := NewConfig().SetAutoZeroizeEphemerals(true)
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if jpake2.X1.Zero() {
+		t.Fatalf("expected scalars to be kept until confirmation")
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	zero := make([]byte, Curve25519ScalarSize)
+	for _, jp := range []*ThreePassJpake[*Curve25519Point, *Curve25519Scalar]{jpake1, jpake2} {
+		for _, s := range []*Curve25519Scalar{jp.X1, jp.X2, jp.S, jp.x2s} {
+			if !bytes.Equal(s.Bytes(), zero) {
+				t.Fatalf("expected scalar to be zeroed, was %x", s.Bytes())
+			}
+		}
+	}
+	key1, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+	key2, err := jpake2.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("expected session key %x to be equal to %x", key1, key2)
+	}
+}
+
+func TestSessionGenerationBytesSeparatesKeys(t *testing.T) {
+	k := []byte("shared point")
+	key := DeriveSessionKeyFromSharedPoint(k, NewConfig())
+	if relabelled := DeriveSessionKeyFromSharedPoint(k, NewConfig().SetSessionGenerationBytes([]byte("DEPLOYMENT_B"))); bytes.Equal(key, relabelled) {
+		t.Fatalf("expected a different label to change the derived key")
+	}
+	if again := DeriveSessionKeyFromSharedPoint(k, NewConfig().SetSessionGenerationBytes([]byte("SESSION"))); !bytes.Equal(key, again) {
+		t.Fatalf("expected the default label to be SESSION, got key %x instead of %x", again, key)
+	}
+}
+
+func TestJpake3PassDeriveSessionKeyFromSharedPoint(t *testing.T) {
+	config := NewConfig().SetSessionGenerationBytes([]byte("OFFLINE"))
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(*msg2); err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	// K = (B - (G4 x [x2*s])) x [x2]
+	k, err := Curve25519Curve{}.NewPoint().ScalarMult(jpake1.OtherX2G, jpake1.x2s)
+	if err != nil {
+		t.Fatalf("error computing shared point: %v", err)
+	}
+	k.Subtract(msg2.B, k)
+	k.ScalarMult(k, jpake1.X2)
+	if key := DeriveSessionKeyFromSharedPoint(k.Bytes(), config); !bytes.Equal(key, jpake1.SessionKey) {
+		t.Fatalf("expected derived key %x to be equal to %x", key, jpake1.SessionKey)
+	}
+}
+
+func TestJpake3PassTypedErrors(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	same, err := InitThreePassJpake(Responder, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init same: %v", err)
+	}
+	if _, err := jpake2.Pass1Message(); !errors.Is(err, ErrRole) {
+		t.Fatalf("expected ErrRole, instead got: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := same.GetPass2Message(*msg1); !errors.Is(err, ErrUserIDCollision) || err.Error() != "could not verify the validity of the received message" {
+		t.Fatalf("expected ErrUserIDCollision, instead got: %v", err)
+	}
+	tampered := *msg1
+	tampered.X1ZKP.T = tampered.X2ZKP.T
+	if _, err := jpake2.GetPass2Message(tampered); !errors.Is(err, ErrZKPVerification) || errors.Is(err, ErrUserIDCollision) {
+		t.Fatalf("expected ErrZKPVerification, instead got: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1([]byte("bad")); !errors.Is(err, ErrSessionConfirmation) {
+		t.Fatalf("expected ErrSessionConfirmation, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassZeroChallenge(t *testing.T) {
+	// a hash which reduces to 0 mod N would make any proof with R = v verify
+	config := NewConfig().SetHashFn(func(in []byte) []byte { return make([]byte, 32) })
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassTamperedZKP(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	r, err := Curve25519Curve{}.NewScalar().SetBigInt(new(big.Int).Add(msg1.X1ZKP.R.BigInt(), big.NewInt(1)))
+	if err != nil {
+		t.Fatalf("error creating scalar: %v", err)
+	}
+	msg1.X1ZKP.R = r
+	if _, err := jpake2.GetPass2Message(*msg1); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassWithSmallOrderX1gPoint(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	// an edwards25519 point of order 8
+	smallOrder, err := new(Curve25519Point).SetBytes([]byte{
+		0x26, 0xe8, 0x95, 0x8f, 0xc2, 0xb2, 0x27, 0xb0, 0x45, 0xc3, 0xf4, 0x89, 0xf2, 0xef, 0x98, 0xf0,
+		0xd5, 0xdf, 0xac, 0x05, 0xd3, 0xc6, 0x33, 0x39, 0xb1, 0x38, 0x02, 0x88, 0x6d, 0x53, 0xfc, 0x05,
+	})
+	if err != nil {
+		t.Fatalf("error decoding small order point: %v", err)
+	}
+	if !(Curve25519Curve{}).IsSmallOrder(smallOrder) || (Curve25519Curve{}).Infinity(smallOrder) {
+		t.Fatalf("expected a small order point other than the identity")
+	}
+	msg1.X1G = smallOrder
+	_, err = jpake2.GetPass2Message(*msg1)
+	if !errors.Is(err, ErrSmallOrderPoint) {
+		t.Fatalf("expected ErrSmallOrderPoint, instead got: %v", err)
+	}
+	if jpake2.Stage != 2 || jpake2.OtherX1G != nil || len(jpake2.SessionKey) != 0 {
+		t.Fatalf("expected the message to be rejected before any state was updated")
+	}
+}
+
+func TestJpake3PassRetryAfterZeroize(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+	jpake1.Destroy()
+	if err := jpake1.RetryWithFreshEphemerals(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage retrying after destroy, instead got: %v", err)
+	}
+	if err := jpake1.Reset(Initiator); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage resetting after destroy, instead got: %v", err)
+	}
+	if jpake1.Stage != StageDestroyed {
+		t.Fatalf("expected stage to remain %s, was %s", StageDestroyed, jpake1.Stage)
+	}
+	if _, err := jpake1.Pass1Message(); err == nil {
+		t.Fatalf("expected error getting pass1 after destroy, instead got nil")
+	}
+
+	config := NewConfig().SetAutoZeroizeEphemerals(true)
+	jpake1, err = InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err = InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+	if err := jpake1.RetryWithFreshEphemerals(); err == nil {
+		t.Fatalf("expected error retrying after s was zeroized, instead got nil")
+	}
+	if jpake1.Stage != StageInitiatorDone {
+		t.Fatalf("expected stage to remain %s, was %s", StageInitiatorDone, jpake1.Stage)
+	}
+	if _, err := jpake1.Pass1Message(); err == nil {
+		t.Fatalf("expected error getting pass1 after s was zeroized, instead got nil")
+	}
+}
+
+func TestJpake3PassDestroy(t *testing.T) {
+	testDestroy[*Curve25519Point, *Curve25519Scalar](t, Curve25519Curve{})
+	testDestroy[*P256Point, *P256Scalar](t, P256Curve{})
+	testDestroy[*P384Point, *P384Scalar](t, P384Curve{})
+	testDestroy[*Secp256k1Point, *Secp256k1Scalar](t, Secp256k1Curve{})
+	testDestroy[*Ristretto255Point, *Ristretto255Scalar](t, Ristretto255Curve{})
+	testDestroy[*Ed448Point, *Ed448Scalar](t, Ed448Curve{})
+}
+
+// scalarWords returns the whole backing array of a scalar held in a big.Int,
+// which Bytes cannot show once the value has been truncated.
+func scalarWords(s any) []big.Word {
+	var w []big.Word
+	switch s := s.(type) {
+	case *P256Scalar:
+		w = s.n.Bits()
+	case *P384Scalar:
+		w = s.n.Bits()
+	}
+	return w[:cap(w)]
+}
+
+func testDestroy[P CurvePoint[P, S], S CurveScalar[S]](t *testing.T, curve Curve[P, S]) {
+	jpake1, err := InitThreePassJpakeWithConfigAndCurve[P, S](Initiator, []byte("one"), []byte("password"), curve, NewConfig())
+	if err != nil {
+		t.Fatalf("%s: error init jpake1: %v", curve.Name(), err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfigAndCurve[P, S](Responder, []byte("two"), []byte("password"), curve, NewConfig())
+	if err != nil {
+		t.Fatalf("%s: error init jpake2: %v", curve.Name(), err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("%s: error getting pass1: %v", curve.Name(), err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("%s: error getting pass2: %v", curve.Name(), err)
+	}
+	if _, err := jpake1.GetPass3Message(*msg2); err != nil {
+		t.Fatalf("%s: error getting pass3: %v", curve.Name(), err)
+	}
+	sessionKey := jpake1.SessionKey
+	scalars := map[string]S{"X1": jpake1.X1, "X2": jpake1.X2, "S": jpake1.S, "x2s": jpake1.x2s}
+	words := map[string][]big.Word{}
+	for name, s := range scalars {
+		words[name] = scalarWords(s)
+	}
+	jpake1.Destroy()
+
+	for name, s := range scalars {
+		if !allZero(s.Bytes()) {
+			t.Fatalf("%s: expected %s to be zeroed, was %x", curve.Name(), name, s.Bytes())
+		}
+		for _, w := range words[name] {
+			if w != 0 {
+				t.Fatalf("%s: expected the words backing %s to be zeroed, was %x", curve.Name(), name, words[name])
+			}
+		}
+	}
+	if !allZero(sessionKey) || len(jpake1.SessionKey) != 0 || len(jpake1.sessionKey) != 0 {
+		t.Fatalf("%s: expected session key to be zeroed", curve.Name())
+	}
+	if _, err := jpake1.Key(); err == nil {
+		t.Fatalf("%s: expected error getting key after destroy, instead got nil", curve.Name())
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1([]byte("confirm")); !errors.Is(err, ErrStage) {
+		t.Fatalf("%s: expected ErrStage after destroy, instead got: %v", curve.Name(), err)
+	}
+}
+
+func TestJpake3PassDeterministicRand(t *testing.T) {
+	pass1 := func() []byte {
+		config := NewConfig().SetRand(math_rand.New(math_rand.NewSource(1)))
+		jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		b, err := msg1.MarshalBinary()
+		if err != nil {
+			t.Fatalf("error marshalling pass1: %v", err)
+		}
+		return b
+	}
+	if first, second := pass1(), pass1(); !bytes.Equal(first, second) {
+		t.Fatalf("expected pass1 %x to be equal to %x", first, second)
+	}
+}
+
+func TestJpake3PassConfigHashFnChangesSessionKey(t *testing.T) {
+	handshake := func(hashFn HashFnType) []byte {
+		config := NewConfig().SetRand(math_rand.New(math_rand.NewSource(1)))
+		if hashFn != nil {
+			config.SetHashFn(hashFn)
+		}
+		jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+		if err != nil {
+			t.Fatalf("error getting conf1: %v", err)
+		}
+		if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+			t.Fatalf("error getting conf2: %v", err)
+		}
+		if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+			t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+		}
+		return jpake1.SessionKey
+	}
+	sha512HashFn := func(in []byte) []byte {
+		hash := sha512.Sum512(in)
+		return hash[:]
+	}
+	if first, second := handshake(nil), handshake(nil); !bytes.Equal(first, second) {
+		t.Fatalf("expected session key %x to be equal to %x", first, second)
+	}
+	if def, custom := handshake(nil), handshake(sha512HashFn); bytes.Equal(def, custom) {
+		t.Fatalf("expected custom hash fn to change session key, both were %x", def)
+	}
+}
+
+func TestJpake3PassPasswordStretch(t *testing.T) {
+	stretch := func(pw, salt []byte) []byte {
+		out := append(append([]byte{}, salt...), pw...)
+		for i := 0; i < 1000; i++ {
+			sum := sha512.Sum512(out)
+			out = sum[:]
+		}
+		return out
+	}
+	secret := func(config *Config) []byte {
+		jp, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+		if err != nil {
+			t.Fatalf("error init jpake: %v", err)
+		}
+		return jp.S.Bytes()
+	}
+	plain := secret(NewConfig())
+	stretched := secret(NewConfig().SetPasswordStretch(stretch, []byte("one|two")))
+	if bytes.Equal(plain, stretched) {
+		t.Fatalf("expected stretching to change s, both were %x", plain)
+	}
+	if again := secret(NewConfig().SetPasswordStretch(stretch, []byte("one|two"))); !bytes.Equal(stretched, again) {
+		t.Fatalf("expected s %x to be equal to %x", again, stretched)
+	}
+	if salted := secret(NewConfig().SetPasswordStretch(stretch, []byte("one|three"))); bytes.Equal(stretched, salted) {
+		t.Fatalf("expected a different salt to change s, both were %x", salted)
+	}
+
+	config := NewConfig().SetPasswordStretch(stretch, []byte("one|two"))
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if !bytes.Equal(jpake1.sessionKey, jpake2.sessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.sessionKey, jpake2.sessionKey)
+	}
+}
+
+func TestJpake3PassClone(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	for i, clone := range []*ThreePassJpake[*Curve25519Point, *Curve25519Scalar]{jpake1.Clone(), jpake1.Clone()} {
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := clone.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1 from clone %d: %v", i, err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := clone.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3 from clone %d: %v", i, err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		conf2, err := clone.ProcessSessionConfirmation1(conf1)
+		if err != nil {
+			t.Fatalf("error getting conf1 from clone %d: %v", i, err)
+		}
+		if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+			t.Fatalf("error getting conf2: %v", err)
+		}
+		if !bytes.Equal(clone.SessionKey, jpake2.SessionKey) {
+			t.Fatalf("expected session key %x to be equal to %x", clone.SessionKey, jpake2.SessionKey)
+		}
+		clone.Destroy()
+	}
+	if jpake1.Stage != StageInit {
+		t.Fatalf("expected original to remain at stage %s, was %s", StageInit, jpake1.Stage)
+	}
+	if jpake1.X1.Zero() || jpake1.X2.Zero() || jpake1.S.Zero() {
+		t.Fatalf("expected original scalars to survive destroying the clones")
+	}
+	if _, err := jpake1.Pass1Message(); err != nil {
+		t.Fatalf("error getting pass1 from original: %v", err)
+	}
+}
+
+func TestJpake3PassSessionKeyFieldIsACopy(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	// scribbling over the exported fields must not affect confirmation
+	zeroizeBytes(jpake1.SessionKey)
+	zeroizeBytes(jpake2.SessionKey)
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	key, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key: %v", err)
+	}
+	if allZero(key) {
+		t.Fatalf("expected key to be unaffected by writes to SessionKey")
+	}
+	key[0] ^= 0xff
+	if again, _ := jpake1.Key(); bytes.Equal(key, again) {
+		t.Fatalf("expected Key to return a copy")
+	}
+}
+
+// concatGrow is the original concat, which grew its output by appending.
+func concatGrow(parts ...[]byte) []byte {
+	msg := []byte{}
+	for _, m := range parts {
+		msg = binary.BigEndian.AppendUint64(msg, uint64(len(m)))
+		msg = append(msg, m...)
+	}
+	return msg
+}
+
+func TestConcat(t *testing.T) {
+	for _, parts := range [][][]byte{
+		nil,
+		{nil},
+		{{}, []byte("a")},
+		{bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 33), []byte("one")},
+		{bytes.Repeat([]byte{3}, 1000), nil, []byte("two")},
+	} {
+		if got, expected := concat(parts...), concatGrow(parts...); !bytes.Equal(got, expected) {
+			t.Fatalf("expected concat %x to be equal to %x", got, expected)
+		}
+	}
+	if b := concat([]byte("ab")); len(b) != cap(b) {
+		t.Fatalf("expected a single exact allocation, len %d cap %d", len(b), cap(b))
+	}
+}
+
+func BenchmarkConcat(b *testing.B) {
+	// the inputs of a ZKP challenge: generator, T, y and a user id
+	generator := bytes.Repeat([]byte{1}, Curve25519PointSize)
+	t := bytes.Repeat([]byte{2}, Curve25519PointSize)
+	y := bytes.Repeat([]byte{3}, Curve25519PointSize)
+	userID := []byte("user@example.com")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		concat(generator, t, y, userID)
+	}
+}
+
+func BenchmarkThreePassFullHandshake(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			b.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			b.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			b.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			b.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			b.Fatalf("error getting pass3: %v", err)
+		}
+		if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+			b.Fatalf("error processing pass3: %v", err)
+		}
+	}
+}
+
+func BenchmarkThreePassPass2(b *testing.B) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		b.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		b.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		b.Fatalf("error getting pass1: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		responder := jpake2.Clone()
+		b.StartTimer()
+		if _, err := responder.GetPass2Message(*msg1); err != nil {
+			b.Fatalf("error getting pass2: %v", err)
+		}
+	}
+}
+
+func TestJpake3PassSessionID(t *testing.T) {
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetSessionID([]byte("session 1")))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetSessionID([]byte("session 1")))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	other, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetSessionID([]byte("session 2")))
+	if err != nil {
+		t.Fatalf("error init other: %v", err)
+	}
+	unbound, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init unbound: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := other.GetPass2Message(*msg1); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification for a different session id, instead got: %v", err)
+	}
+	if _, err := unbound.GetPass2Message(*msg1); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification without a session id, instead got: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	// the confirmation MAC is bound to the session id too
+	jpake1.config.SetSessionID([]byte("session 2"))
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); !errors.Is(err, ErrSessionConfirmation) {
+		t.Fatalf("expected ErrSessionConfirmation for a different session id, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassAssociatedData(t *testing.T) {
+	handshake := func(ad1, ad2 []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar], []byte) {
+		jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetAssociatedData(ad1))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetAssociatedData(ad2))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		return jpake1, jpake2, conf1
+	}
+
+	jpake1, jpake2, conf1 := handshake([]byte("serial 1234, fw 1.0"), []byte("serial 1234, fw 1.0"))
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+	plain := DeriveSessionKeyFromSharedPoint([]byte("k"), NewConfig())
+	if bound := DeriveSessionKeyFromSharedPoint([]byte("k"), NewConfig().SetAssociatedData([]byte("ad"))); bytes.Equal(plain, bound) {
+		t.Fatalf("expected associated data to change the derived key")
+	}
+
+	jpake1, jpake2, conf1 = handshake([]byte("serial 1234, fw 1.0"), []byte("serial 1234, fw 2.0"))
+	if bytes.Equal(jpake1.sessionKey, jpake2.sessionKey) {
+		t.Fatalf("expected different associated data to derive different keys")
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); !errors.Is(err, ErrSessionConfirmation) {
+		t.Fatalf("expected ErrSessionConfirmation, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassConfirmationComparison(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	// a MAC differing only in its last byte, and a truncated MAC, are rejected
+	lastByte := append([]byte{}, conf1...)
+	lastByte[len(lastByte)-1] ^= 1
+	for _, bad := range [][]byte{lastByte, conf1[:len(conf1)-1], nil} {
+		if _, err := jpake1.ProcessSessionConfirmation1(bad); !errors.Is(err, ErrSessionConfirmation) {
+			t.Fatalf("expected ErrSessionConfirmation for conf1 %x, instead got: %v", bad, err)
+		}
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	lastByte = append([]byte{}, conf2...)
+	lastByte[len(lastByte)-1] ^= 1
+	for _, bad := range [][]byte{lastByte, conf2[:len(conf2)-1], nil} {
+		if err := jpake2.ProcessSessionConfirmation2(bad); !errors.Is(err, ErrSessionConfirmation) {
+			t.Fatalf("expected ErrSessionConfirmation for conf2 %x, instead got: %v", bad, err)
+		}
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+}
+
+func TestJpake3PassSessionKeyLength(t *testing.T) {
+	for _, kmac := range []bool{false, true} {
+		var keys [][]byte
+		for _, length := range []int{16, 32, 64} {
+			config := NewConfig().SetSessionKeyLength(length)
+			if kmac {
+				config.SetKMACSessionKeyDerivation([]byte("jpake"))
+			}
+			jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), config)
+			if err != nil {
+				t.Fatalf("error init jpake1: %v", err)
+			}
+			jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), config)
+			if err != nil {
+				t.Fatalf("error init jpake2: %v", err)
+			}
+			runPAKE(t, jpake1, jpake2)
+			key, err := jpake1.Key()
+			if err != nil {
+				t.Fatalf("error getting key: %v", err)
+			}
+			if len(key) != length {
+				t.Fatalf("expected a %d byte session key, got %d bytes", length, len(key))
+			}
+			keys = append(keys, key)
+		}
+		// kmac encodes the output length, so a longer key does not extend a
+		// shorter one
+		if kmac && bytes.HasPrefix(keys[2], keys[1]) {
+			t.Fatalf("expected kmac output length to change the key")
+		}
+	}
+
+	// without kmac a shorter key is a prefix of the default
+	k := []byte("shared point")
+	full := NewConfig().generateSessionKey(k)
+	if short := NewConfig().SetSessionKeyLength(16).generateSessionKey(k); !bytes.Equal(short, full[:16]) {
+		t.Fatalf("expected %x to be a prefix of %x", short, full)
+	}
+	if same := NewConfig().SetSessionKeyLength(32).generateSessionKey(k); !bytes.Equal(same, full) {
+		t.Fatalf("expected %x to be equal to %x", same, full)
+	}
+	long := NewConfig().SetSessionKeyLength(100).generateSessionKey(k)
+	if len(long) != 100 || bytes.Equal(long[:32], long[32:64]) {
+		t.Fatalf("expected 100 distinct bytes, got %x", long)
+	}
+}
+
+func TestJpake3PassConfirmationErrors(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("wrong"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	_, err = jpake1.ProcessSessionConfirmation1(conf1[:len(conf1)-1])
+	if !errors.Is(err, ErrMalformedConfirmation) || !errors.Is(err, ErrMalformedMessage) || errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrMalformedConfirmation for a truncated confirmation, instead got: %v", err)
+	}
+	if reason := NewAbortMessage(err).Reason; reason != AbortMalformedMessage {
+		t.Fatalf("expected abort reason %s, was %s", AbortMalformedMessage, reason)
+	}
+	_, err = jpake1.ProcessSessionConfirmation1(conf1)
+	if !errors.Is(err, ErrPasswordMismatch) || errors.Is(err, ErrMalformedConfirmation) {
+		t.Fatalf("expected ErrPasswordMismatch for a different password, instead got: %v", err)
+	}
+	if !errors.Is(err, ErrSessionConfirmation) {
+		t.Fatalf("expected ErrPasswordMismatch to match ErrSessionConfirmation")
+	}
+}
+
+func TestJpake3PassReset(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if err := jpake1.Reset(Initiator); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage resetting before the exchange completed, instead got: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+	key1, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key1: %v", err)
+	}
+
+	// the sides swap roles for the second handshake
+	if err := jpake1.Reset(Responder); err != nil {
+		t.Fatalf("error resetting jpake1: %v", err)
+	}
+	if err := jpake2.Reset(Initiator); err != nil {
+		t.Fatalf("error resetting jpake2: %v", err)
+	}
+	if jpake1.Stage != StageAwaitingPass1 || jpake2.Stage != StageInit {
+		t.Fatalf("expected stages %s and %s, got %s and %s", StageAwaitingPass1, StageInit, jpake1.Stage, jpake2.Stage)
+	}
+	if len(jpake1.SessionKey) != 0 || jpake1.OtherUserID != nil || !isNil(jpake1.OtherX1G) {
+		t.Fatalf("expected the previous peer values and key to be cleared")
+	}
+	runPAKE(t, jpake2, jpake1)
+	key2, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if bytes.Equal(key1, key2) {
+		t.Fatalf("expected a fresh session key, both were %x", key1)
+	}
+
+	zeroized, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetAutoZeroizeEphemerals(true))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err = InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, zeroized, jpake2)
+	if err := zeroized.Reset(Initiator); err == nil {
+		t.Fatalf("expected error resetting after s was zeroized, instead got nil")
+	}
+}
+
+type recordingObserver struct {
+	events []string
+	keyID  []byte
+}
+
+func (o *recordingObserver) OnStageTransition(from, to Stage) {
+	o.events = append(o.events, fmt.Sprintf("stage %s -> %s", from, to))
+}
+
+func (o *recordingObserver) OnZKPVerify(name string, ok bool) {
+	o.events = append(o.events, fmt.Sprintf("zkp %s %t", name, ok))
+}
+
+func (o *recordingObserver) OnSessionKeyDerived(keyID []byte) {
+	o.events = append(o.events, "session key derived")
+	o.keyID = keyID
+}
+
+func TestJpake3PassObserver(t *testing.T) {
+	observer1, observer2 := &recordingObserver{}, &recordingObserver{}
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetObserver(observer1))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetObserver(observer2))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+
+	expected1 := []string{
+		"stage init -> awaiting pass 2",
+		"zkp X3ZKP true",
+		"zkp X4ZKP true",
+		"zkp XsZKP true",
+		"stage awaiting pass 2 -> awaiting confirmation 1",
+		"session key derived",
+		"stage awaiting confirmation 1 -> initiator done",
+	}
+	expected2 := []string{
+		"zkp X1ZKP true",
+		"zkp X2ZKP true",
+		"stage awaiting pass 1 -> awaiting pass 3",
+		"zkp XsZKP true",
+		"session key derived",
+		"stage awaiting pass 3 -> awaiting confirmation 2",
+		"stage awaiting confirmation 2 -> responder done",
+	}
+	if strings.Join(observer1.events, "\n") != strings.Join(expected1, "\n") {
+		t.Fatalf("expected initiator events:\n%s\ngot:\n%s", strings.Join(expected1, "\n"), strings.Join(observer1.events, "\n"))
+	}
+	if strings.Join(observer2.events, "\n") != strings.Join(expected2, "\n") {
+		t.Fatalf("expected responder events:\n%s\ngot:\n%s", strings.Join(expected2, "\n"), strings.Join(observer2.events, "\n"))
+	}
+	key, err := jpake1.Key()
+	if err != nil {
+		t.Fatalf("error getting key: %v", err)
+	}
+	if len(observer1.keyID) == 0 || !bytes.Equal(observer1.keyID, observer2.keyID) || bytes.Contains(observer1.keyID, key) {
+		t.Fatalf("expected matching key ids which do not contain the key, got %x and %x", observer1.keyID, observer2.keyID)
+	}
+}
+
+func TestJpake3PassUserIDLength(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 257)
+	jpake1, err := InitThreePassJpake(Initiator, long, []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); !errors.Is(err, ErrUserIDLength) {
+		t.Fatalf("expected ErrUserIDLength for an oversized user id, instead got: %v", err)
+	}
+	empty := *msg1
+	empty.UserID = nil
+	if _, err := jpake2.GetPass2Message(empty); !errors.Is(err, ErrUserIDLength) {
+		t.Fatalf("expected ErrUserIDLength for an empty user id, instead got: %v", err)
+	}
+
+	// a raised limit admits the same user id
+	jpake2, err = InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetMaxUserIDLength(len(long)))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+
+	// the initiator applies the limit to the responder's user id
+	jpake1, err = InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), NewConfig().SetMaxUserIDLength(2))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err = InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err = jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(*msg2); !errors.Is(err, ErrUserIDLength) {
+		t.Fatalf("expected ErrUserIDLength for an oversized user id, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassSharedPointX25519(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := jpake1.SharedPointX25519(); err == nil {
+		t.Fatalf("expected error before the shared point is computed, instead got nil")
+	}
+	runPAKE(t, jpake1, jpake2)
+	u1, err := jpake1.SharedPointX25519()
+	if err != nil {
+		t.Fatalf("error getting u-coordinate for jpake1: %v", err)
+	}
+	u2, err := jpake2.SharedPointX25519()
+	if err != nil {
+		t.Fatalf("error getting u-coordinate for jpake2: %v", err)
+	}
+	if len(u1) != 32 || !bytes.Equal(u1, u2) {
+		t.Fatalf("expected u-coordinate %x to be equal to %x", u1, u2)
+	}
+	// the map takes the edwards25519 base point to the X25519 base point u = 9
+	u := (Curve25519Curve{}).NewGeneratorPoint().BytesMontgomery()
+	if !bytes.Equal(u, curve25519.Basepoint) {
+		t.Fatalf("expected the base point to map to %x, got %x", curve25519.Basepoint, u)
+	}
+
+	p1, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Initiator, []byte("one"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	p2, err := InitThreePassJpakeWithConfigAndCurve[*P256Point, *P256Scalar](Responder, []byte("two"), []byte("password"), P256Curve{}, NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, p1, p2)
+	if _, err := p1.SharedPointX25519(); err == nil {
+		t.Fatalf("expected error on p256, instead got nil")
+	}
+}
+
+func TestJpake3PassDegenerateZKPGenerator(t *testing.T) {
+	// an edwards25519 point of order 8
+	order8, _ := hex.DecodeString("26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05")
+	torsion, err := new(Curve25519Point).SetBytes(order8)
+	if err != nil {
+		t.Fatalf("error decoding small order point: %v", err)
+	}
+	curve := Curve25519Curve{}
+	for _, tc := range []struct {
+		name     string
+		offset   *Curve25519Point
+		expected error
+	}{
+		{"identity", curve.NewPoint(), ErrPointAtInfinity},
+		{"small order", torsion, ErrSmallOrderPoint},
+	} {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		// X3G chosen so that G1 + G2 + G3 is the offset
+		sum := curve.NewPoint().Add(msg1.X1G, msg1.X2G)
+		forged := *msg2
+		forged.X3G = curve.NewPoint().Subtract(tc.offset, sum)
+		if _, err := jpake1.GetPass3Message(forged); !errors.Is(err, tc.expected) {
+			t.Fatalf("expected %v for a %s generator in GetPass3Message, instead got: %v", tc.expected, tc.name, err)
+		}
+
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		// the responder's view of G1 chosen the same way
+		sum = curve.NewPoint().Add(jpake2.x1G, jpake2.x2G)
+		jpake2.OtherX1G = curve.NewPoint().Subtract(tc.offset, sum)
+		if _, err := jpake2.ProcessPass3Message(*msg3); !errors.Is(err, tc.expected) {
+			t.Fatalf("expected %v for a %s generator in ProcessPass3Message, instead got: %v", tc.expected, tc.name, err)
+		}
+	}
+}
+
+func TestJpake3PassConfirmationMessage(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	// the initiator owes nothing until it has verified the responder
+	if _, err := jpake1.ConfirmationMessage(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage, instead got: %v", err)
+	}
+
+	out2, err := jpake2.ConfirmationMessage()
+	if err != nil {
+		t.Fatalf("error getting responder confirmation: %v", err)
+	}
+	if !bytes.Equal(out2, conf1) {
+		t.Fatalf("expected confirmation %x to be equal to %x", out2, conf1)
+	}
+	reply, err := jpake1.VerifyConfirmation(out2)
+	if err != nil {
+		t.Fatalf("error verifying responder confirmation: %v", err)
+	}
+	out1, err := jpake1.ConfirmationMessage()
+	if err != nil {
+		t.Fatalf("error getting initiator confirmation: %v", err)
+	}
+	if !bytes.Equal(out1, reply) {
+		t.Fatalf("expected confirmation %x to be equal to %x", out1, reply)
+	}
+	if reply, err := jpake2.VerifyConfirmation(out1); err != nil || reply != nil {
+		t.Fatalf("expected no reply and no error verifying initiator confirmation, got %x and %v", reply, err)
+	}
+	if _, err := jpake2.ConfirmationMessage(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage once the responder is done, instead got: %v", err)
+	}
+	if jpake1.Stage != StageInitiatorDone || jpake2.Stage != StageResponderDone {
+		t.Fatalf("expected stages %s and %s, got %s and %s", StageInitiatorDone, StageResponderDone, jpake1.Stage, jpake2.Stage)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+}
+
+// FuzzThreePassStateMachine drives an initiator and a responder through an
+// arbitrary interleaving of pass and confirmation calls on both sides, with
+// fields of the messages in flight dropped or swapped for fields of other
+// passes. The first byte chooses whether the passwords match; each following
+// byte is an operation, its low three bits the call, bit 3 the side making it
+// and the high bits which mutation to apply.
+func FuzzThreePassStateMachine(f *testing.F) {
+	f.Add([]byte{0, 0x00, 0x09, 0x02, 0x0b, 0x04, 0x0d})
+	f.Add([]byte{1, 0x00, 0x09, 0x02, 0x0b, 0x04, 0x0d})
+	f.Add([]byte{0, 0x00, 0x09, 0x56, 0x02, 0x0b, 0x04, 0x0d})
+	f.Add([]byte{0, 0x00, 0x09, 0x02, 0x03, 0x0b, 0x0c, 0x04, 0x05, 0x0d})
+	f.Fuzz(fuzzThreePassStateMachine)
+}
+
+func fuzzThreePassStateMachine(t *testing.T, ops []byte) {
+	if len(ops) == 0 {
+		return
+	}
+	pw := []byte("hunter2-secret")
+	otherPW := pw
+	if ops[0]&1 == 1 {
+		otherPW = []byte("hunter3-secret")
+	}
+	a, err := InitThreePassJpake(Initiator, []byte("one"), pw)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	b, err := InitThreePassJpake(Responder, []byte("two"), otherPW)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	var m1 ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	var m2 ThreePassVariant2[*Curve25519Point, *Curve25519Scalar]
+	var m3 ThreePassVariant3[*Curve25519Point, *Curve25519Scalar]
+	var conf1, conf2 []byte
+	for _, op := range ops[1:] {
+		jp := a
+		if op&0x08 != 0 {
+			jp = b
+		}
+		switch op & 0x07 {
+		case 0:
+			if msg, err := jp.Pass1Message(); err == nil {
+				m1 = *msg
+			}
+		case 1:
+			if msg, err := jp.GetPass2Message(m1); err == nil {
+				m2 = *msg
+			}
+		case 2:
+			if msg, err := jp.GetPass3Message(m2); err == nil {
+				m3 = *msg
+			}
+		case 3:
+			if conf, err := jp.ProcessPass3Message(m3); err == nil {
+				conf1 = conf
+			}
+		case 4:
+			if conf, err := jp.ProcessSessionConfirmation1(conf1); err == nil {
+				conf2 = conf
+			}
+		case 5:
+			err = jp.ProcessSessionConfirmation2(conf2)
+			if err != nil && strings.Contains(err.Error(), "hunter") {
+				t.Fatalf("error leaks the password: %v", err)
+			}
+		case 6:
+			switch op >> 4 {
+			case 0:
+				m1.X1G = nil
+			case 1:
+				m1.X1G, m1.X2G = m1.X2G, m1.X1G
+			case 2:
+				m1.X1ZKP = m1.X2ZKP
+			case 3:
+				m1.UserID = m2.UserID
+			case 4:
+				m2.B = nil
+			case 5:
+				m2.X3G = m1.X1G
+			case 6:
+				m2.XsZKP = m3.XsZKP
+			case 7:
+				m2.X4ZKP.R = nil
+			case 8:
+				m3.A = nil
+			case 9:
+				m3.A = m2.B
+			case 10:
+				m3.XsZKP.T = m1.X1G
+			case 11:
+				m2.UserID = nil
+			case 12:
+				m1 = ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]{}
+			case 13:
+				m3.XsZKP = m1.X1ZKP
+			case 14:
+				m2.X3G, m2.X4G = m2.X4G, m2.X3G
+			case 15:
+				m1.X2ZKP.T = nil
+			}
+		case 7:
+			switch op >> 4 & 3 {
+			case 0:
+				conf1, conf2 = conf2, conf1
+			case 1:
+				if len(conf1) > 0 {
+					conf1 = append([]byte{conf1[0] ^ 1}, conf1[1:]...)
+				}
+			case 2:
+				if len(conf2) > 0 {
+					conf2 = conf2[:len(conf2)-1]
+				}
+			case 3:
+				conf1, conf2 = nil, nil
+			}
+		}
+	}
+	for _, jp := range []*ThreePassJpake[*Curve25519Point, *Curve25519Scalar]{a, b} {
+		if jp.Stage != StageInitiatorDone && jp.Stage != StageResponderDone {
+			continue
+		}
+		if !bytes.Equal(pw, otherPW) {
+			t.Fatalf("%s completed key confirmation with the wrong password", jp.Stage)
+		}
+		if !bytes.Equal(a.SessionKey, b.SessionKey) {
+			t.Fatalf("%s completed key confirmation with session key %x, peer has %x", jp.Stage, a.SessionKey, b.SessionKey)
+		}
+	}
+}
+
+func TestJpake3PassEqualityProof(t *testing.T) {
+	// runs the three passes only, so each side holds a key but skipped
+	// confirmation
+	exchange := func(pw1, pw2 []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar]) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), pw1)
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), pw2)
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if jpake1.EqualityProof() != nil {
+			t.Fatalf("expected no equality proof before a key is derived")
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		return jpake1, jpake2
+	}
+
+	jpake1, jpake2 := exchange([]byte("password"), []byte("password"))
+	proof1, proof2 := jpake1.EqualityProof(), jpake2.EqualityProof()
+	if !jpake2.VerifyEqualityProof(proof1) || !jpake1.VerifyEqualityProof(proof2) {
+		t.Fatalf("expected equal keys to verify")
+	}
+	if bytes.Equal(proof1, proof2) || jpake1.VerifyEqualityProof(proof1) {
+		t.Fatalf("expected a reflected proof not to verify")
+	}
+
+	restored, err := RestoreThreePassJpake(jpake2.Stage, []byte("two"), []byte("one"), jpake2.sessionKey, 0, jpake2.X1, jpake2.X2, jpake2.S, jpake2.OtherX1G, jpake2.OtherX2G)
+	if err != nil {
+		t.Fatalf("error restoring jpake2: %v", err)
+	}
+	if !restored.VerifyEqualityProof(proof1) || !jpake1.VerifyEqualityProof(restored.EqualityProof()) {
+		t.Fatalf("expected a restored session to verify")
+	}
+
+	jpake1, jpake2 = exchange([]byte("password"), []byte("other password"))
+	if jpake2.VerifyEqualityProof(jpake1.EqualityProof()) || jpake1.VerifyEqualityProof(jpake2.EqualityProof()) {
+		t.Fatalf("expected unequal keys not to verify")
+	}
+	if jpake1.VerifyEqualityProof(nil) {
+		t.Fatalf("expected an empty proof not to verify")
+	}
+}
+
+func TestJpake3PassKeyFingerprint(t *testing.T) {
+	pair := func(pw []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar]) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), pw)
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), pw)
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if _, err := jpake1.KeyFingerprint(); !errors.Is(err, ErrAwaitingConfirmation) {
+			t.Fatalf("expected ErrAwaitingConfirmation, instead got: %v", err)
+		}
+		runPAKE(t, jpake1, jpake2)
+		return jpake1, jpake2
+	}
+	jpake1, jpake2 := pair([]byte("password"))
+	fp1, err := jpake1.KeyFingerprint()
+	if err != nil {
+		t.Fatalf("error getting fingerprint1: %v", err)
+	}
+	fp2, err := jpake2.KeyFingerprint()
+	if err != nil {
+		t.Fatalf("error getting fingerprint2: %v", err)
+	}
+	if len(fp1) != 8 || !bytes.Equal(fp1, fp2) {
+		t.Fatalf("expected equal 8 byte fingerprints, got %x and %x", fp1, fp2)
+	}
+
+	jpake3, _ := pair([]byte("other password"))
+	fp3, err := jpake3.KeyFingerprint()
+	if err != nil {
+		t.Fatalf("error getting fingerprint3: %v", err)
+	}
+	if bytes.Equal(fp1, fp3) {
+		t.Fatalf("expected sessions with different passwords to have different fingerprints")
+	}
+
+	// a mismatched password never reaches confirmation, so has no fingerprint
+	jpake1, err = InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err = InitThreePassJpake(Responder, []byte("two"), []byte("wrong"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake1.ProcessSessionConfirmation1(conf1); err == nil {
+		t.Fatalf("expected confirmation to fail with a wrong password")
+	}
+	if _, err := jpake1.KeyFingerprint(); !errors.Is(err, ErrAwaitingConfirmation) {
+		t.Fatalf("expected ErrAwaitingConfirmation, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassDirectionalKeys(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, _, err := jpake1.DirectionalKeys(); err == nil {
+		t.Fatalf("expected an error before the session key is derived")
+	}
+	runPAKE(t, jpake1, jpake2)
+	send1, recv1, err := jpake1.DirectionalKeys()
+	if err != nil {
+		t.Fatalf("error getting keys1: %v", err)
+	}
+	send2, recv2, err := jpake2.DirectionalKeys()
+	if err != nil {
+		t.Fatalf("error getting keys2: %v", err)
+	}
+	if !bytes.Equal(send1, recv2) || !bytes.Equal(recv1, send2) {
+		t.Fatalf("expected each side's send key to be the other's receive key")
+	}
+	if bytes.Equal(send1, recv1) || bytes.Equal(send1, jpake1.SessionKey) || bytes.Equal(recv1, jpake1.SessionKey) {
+		t.Fatalf("expected the directional keys to differ from each other and the session key")
+	}
+}
+
+func TestJpake3PassRatchet(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := jpake1.Ratchet(); err == nil {
+		t.Fatalf("expected an error before the session key is derived")
+	}
+	runPAKE(t, jpake1, jpake2)
+	seen := map[string]bool{string(jpake1.SessionKey): true}
+	for i := 0; i < 5; i++ {
+		key1, err := jpake1.Ratchet()
+		if err != nil {
+			t.Fatalf("error ratcheting jpake1: %v", err)
+		}
+		key2, err := jpake2.Ratchet()
+		if err != nil {
+			t.Fatalf("error ratcheting jpake2: %v", err)
+		}
+		if !bytes.Equal(key1, key2) {
+			t.Fatalf("expected equal keys after %d ratchets, got %x and %x", i+1, key1, key2)
+		}
+		if seen[string(key1)] {
+			t.Fatalf("expected a fresh key after %d ratchets", i+1)
+		}
+		seen[string(key1)] = true
+		if !bytes.Equal(jpake1.SessionKey, key1) {
+			t.Fatalf("expected SessionKey to follow the ratchet")
+		}
+	}
+
+	// a side that has ratcheted once more no longer agrees
+	key1, err := jpake1.Ratchet()
+	if err != nil {
+		t.Fatalf("error ratcheting jpake1: %v", err)
+	}
+	key2, err := jpake2.Key()
+	if err != nil {
+		t.Fatalf("error getting key2: %v", err)
+	}
+	if bytes.Equal(key1, key2) {
+		t.Fatalf("expected keys to differ after unequal ratchets")
+	}
+}
+
+func TestJpake3PassFromReader(t *testing.T) {
+	pw := []byte("a password read from a reader")
+	jpake1, err := InitThreePassJpakeFromReader(Initiator, []byte("one"), bytes.NewReader(pw), NewConfig())
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), pw)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+
+	// no byte slice on the struct holds the password
+	v := reflect.ValueOf(jpake1).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8 && bytes.Contains(f.Bytes(), pw) {
+			t.Fatalf("expected the password not to be retained, found it in %s", v.Type().Field(i).Name)
+		}
+	}
+	state, err := jpake1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshalling state: %v", err)
+	}
+	if bytes.Contains(state, pw) {
+		t.Fatalf("expected the password not to be retained in the state")
+	}
+
+	_, err = InitThreePassJpakeFromReader(Initiator, []byte("one"), bytes.NewReader(make([]byte, maxReaderPasswordLength+1)), NewConfig())
+	if err == nil {
+		t.Fatalf("expected an error for an overlong password")
+	}
+}
+
+func TestJpake3PassTranscript(t *testing.T) {
+	pair := func() (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar]) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if _, err := jpake1.Transcript(); !errors.Is(err, ErrStage) {
+			t.Fatalf("expected ErrStage, instead got: %v", err)
+		}
+		runPAKE(t, jpake1, jpake2)
+		return jpake1, jpake2
+	}
+	jpake1, jpake2 := pair()
+	transcript1, err := jpake1.Transcript()
+	if err != nil {
+		t.Fatalf("error getting transcript1: %v", err)
+	}
+	transcript2, err := jpake2.Transcript()
+	if err != nil {
+		t.Fatalf("error getting transcript2: %v", err)
+	}
+	if !bytes.Equal(transcript1, transcript2) {
+		t.Fatalf("expected equal transcripts, got %x and %x", transcript1, transcript2)
+	}
+
+	// altering any exchanged value changes the transcript
+	tampered := jpake1.Clone()
+	tampered.transcript[1] = append([]byte{}, tampered.transcript[1]...)
+	tampered.transcript[1][len(tampered.transcript[1])-1] ^= 1
+	transcript3, err := tampered.Transcript()
+	if err != nil {
+		t.Fatalf("error getting tampered transcript: %v", err)
+	}
+	if bytes.Equal(transcript1, transcript3) {
+		t.Fatalf("expected a tampered message to change the transcript")
+	}
+
+	jpake3, _ := pair()
+	transcript4, err := jpake3.Transcript()
+	if err != nil {
+		t.Fatalf("error getting transcript4: %v", err)
+	}
+	if bytes.Equal(transcript1, transcript4) {
+		t.Fatalf("expected separate handshakes to have different transcripts")
+	}
+}
+
+func TestJpake3PassRegeneratePass1Message(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	if _, err := jpake1.RegeneratePass1Message(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage before the first message, instead got: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := jpake1.Pass1Message(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage calling Pass1Message twice, instead got: %v", err)
+	}
+	// the first send was dropped, so send the message again
+	resent, err := jpake1.RegeneratePass1Message()
+	if err != nil {
+		t.Fatalf("error regenerating pass1: %v", err)
+	}
+	c := Codec[*Curve25519Point, *Curve25519Scalar]{}
+	if !bytes.Equal(c.EncodePass1(msg1), c.EncodePass1(resent)) {
+		t.Fatalf("expected the regenerated message to equal the first")
+	}
+	msg2, err := jpake2.GetPass2Message(*resent)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	if _, err := jpake1.RegeneratePass1Message(); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage once the reply was processed, instead got: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error processing confirmation1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error processing confirmation2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected equal session keys")
+	}
+}
+
+func TestJpake3PassSalt(t *testing.T) {
+	handshake := func(salt1, salt2 []byte) error {
+		jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("1234"), NewConfig().SetSalt(salt1))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("1234"), NewConfig().SetSalt(salt2))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+		if err != nil {
+			return err
+		}
+		return jpake2.ProcessSessionConfirmation2(conf2)
+	}
+	if err := handshake([]byte("nonce-1"), []byte("nonce-1")); err != nil {
+		t.Fatalf("expected equal salts to converge, got: %v", err)
+	}
+	if err := handshake([]byte("nonce-1"), []byte("nonce-2")); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch for differing salts, instead got: %v", err)
+	}
+
+	secret := func(config *Config) []byte {
+		jp, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("1234"), config)
+		if err != nil {
+			t.Fatalf("error init jpake: %v", err)
+		}
+		return jp.S.Bytes()
+	}
+	if !bytes.Equal(secret(NewConfig()), secret(NewConfig().SetSalt(nil))) {
+		t.Fatalf("expected an empty salt to leave s unchanged")
+	}
+	if bytes.Equal(secret(NewConfig().SetSalt([]byte("nonce-1"))), secret(NewConfig().SetSalt([]byte("nonce-2")))) {
+		t.Fatalf("expected differing salts to give differing s")
+	}
+}
+
+func TestJpake3PassConfirmationContext(t *testing.T) {
+	handshake := func(ctx1, ctx2 []byte) error {
+		jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("1234"), NewConfig().SetConfirmationContext(ctx1))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("1234"), NewConfig().SetConfirmationContext(ctx2))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		// the context only affects confirmation, not the key itself
+		if !bytes.Equal(jpake1.sessionKey, jpake2.sessionKey) {
+			t.Fatalf("expected equal session keys")
+		}
+		conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+		if err != nil {
+			return err
+		}
+		return jpake2.ProcessSessionConfirmation2(conf2)
+	}
+	if err := handshake([]byte("v2;aes-256-gcm"), []byte("v2;aes-256-gcm")); err != nil {
+		t.Fatalf("expected equal contexts to confirm, got: %v", err)
+	}
+	if err := handshake([]byte("v2;aes-256-gcm"), []byte("v1;aes-128-cbc")); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch for differing contexts, instead got: %v", err)
+	}
+	if err := handshake(nil, []byte("v2;aes-256-gcm")); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch when one side has no context, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassUnsupportedVersion(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if msg1.Version != ProtocolVersion {
+		t.Fatalf("expected version %d, was %d", ProtocolVersion, msg1.Version)
+	}
+	future := *msg1
+	future.Version = ProtocolVersion + 1
+	_, err = jpake2.GetPass2Message(future)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, instead got: %v", err)
+	}
+	if reason := NewAbortMessage(err).Reason; reason != AbortUnexpectedMessage {
+		t.Fatalf("expected abort reason %s, was %s", AbortUnexpectedMessage, reason)
+	}
+	if jpake2.Stage != StageAwaitingPass1 {
+		t.Fatalf("expected stage to remain %s, was %s", StageAwaitingPass1, jpake2.Stage)
+	}
+
+	// the binary encoding is rejected by its version byte before the fields
+	// are read
+	body := (curve25519Codec{}).EncodePass1(msg1)
+	body[1] = ProtocolVersion + 1
+	if _, err := jpake2.ProcessFrame(FramePass1, body); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, instead got: %v", err)
+	}
+	var streamed ThreePassVariant1[*Curve25519Point, *Curve25519Scalar]
+	if _, err := streamed.ReadFrom(bytes.NewReader(body)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, instead got: %v", err)
+	}
+
+	if _, err := jpake2.GetPass2Message(*msg1); err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+
+	two1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init two1: %v", err)
+	}
+	two2, err := InitTwoPassJpake([]byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init two2: %v", err)
+	}
+	twoMsg1, err := two1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting two pass1: %v", err)
+	}
+	if _, err := two2.Pass1Message(); err != nil {
+		t.Fatalf("error getting two pass1: %v", err)
+	}
+	twoMsg1.Version = 0
+	if _, err := two2.GetPass2Message(*twoMsg1); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassUserIDCollision(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	same, err := InitThreePassJpake(Responder, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init same: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if _, err := same.GetPass2Message(*msg1); !errors.Is(err, ErrUserIDCollision) {
+		t.Fatalf("expected ErrUserIDCollision from GetPass2Message, instead got: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+
+	// the proofs no longer verify for the altered ID, so ErrUserIDCollision
+	// shows the check ran before them
+	selfID := *msg2
+	selfID.UserID = []byte("one")
+	if _, err := jpake1.GetPass3Message(selfID); !errors.Is(err, ErrUserIDCollision) {
+		t.Fatalf("expected ErrUserIDCollision from GetPass3Message, instead got: %v", err)
+	}
+	if jpake1.OtherUserID != nil || jpake1.Stage != StageAwaitingPass2 {
+		t.Fatalf("expected a rejected pass 2 to leave the exchange unchanged")
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+
+	restored, err := RestoreThreePassJpake(StageAwaitingPass3, []byte("two"), []byte("two"), nil, 0, jpake2.X1, jpake2.X2, jpake2.S, jpake2.OtherX1G, jpake2.OtherX2G)
+	if err != nil {
+		t.Fatalf("error restoring jpake2: %v", err)
+	}
+	if _, err := restored.ProcessPass3Message(*msg3); !errors.Is(err, ErrUserIDCollision) {
+		t.Fatalf("expected ErrUserIDCollision from ProcessPass3Message, instead got: %v", err)
+	}
+	if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+}
+
+func TestJpake3PassReplayedMessages(t *testing.T) {
+	pair := func() (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], *ThreePassJpake[*Curve25519Point, *Curve25519Scalar]) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		return jpake1, jpake2
+	}
+	jpake1, jpake2 := pair()
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 1, instead got: %v", err)
+	}
+
+	// pass 2 echoing our own points back
+	echoed := *msg2
+	echoed.X3G, echoed.X4G = msg1.X1G, msg1.X2G
+	if _, err := jpake1.GetPass3Message(echoed); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for an echoed pass 2, instead got: %v", err)
+	}
+	// pass 2 from a responder in another exchange is not bound to our pass 1
+	jpake3, jpake4 := pair()
+	otherMsg1, err := jpake3.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	swapped, err := jpake4.GetPass2Message(*otherMsg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(*swapped); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification for a swapped pass 2, instead got: %v", err)
+	}
+
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	if _, err := jpake1.GetPass3Message(*msg2); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 2, instead got: %v", err)
+	}
+	if _, err := jpake2.ProcessPass3Message(*msg3); err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	if _, err := jpake2.ProcessPass3Message(*msg3); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a replayed pass 3, instead got: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1); !errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrReplayedMessage for a late replayed pass 1, instead got: %v", err)
+	}
+	// a new message at the wrong stage is still a stage error
+	if _, err := jpake2.GetPass2Message(*otherMsg1); !errors.Is(err, ErrStage) || errors.Is(err, ErrReplayedMessage) {
+		t.Fatalf("expected ErrStage, instead got: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := NewConfig().Validate(); err != nil {
+		t.Fatalf("expected the default config to be valid, got: %v", err)
+	}
+	for _, tc := range []struct {
+		config *Config
+		text   string
+	}{
+		{NewConfig().SetHashFn(nil), "hash function is nil"},
+		{NewConfig().SetMacFn(nil), "mac function is nil"},
+		{NewConfig().SetRand(nil), "randomness source is nil"},
+		{NewConfig().SetSessionConfirmationBytes(nil), "session confirmation label is empty"},
+		{NewConfig().SetSecretGenerationBytes([]byte{}), "secret generation label is empty"},
+		{NewConfig().SetSessionGenerationBytes(nil), "session generation label is empty"},
+		{NewConfig().SetPointEncoding(&PointEncoding{}), "point encoding is missing a function"},
+		{nil, "nil config"},
+	} {
+		err := tc.config.Validate()
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tc.text) {
+			t.Fatalf("expected ErrInvalidConfig reporting %q, instead got: %v", tc.text, err)
+		}
+		if _, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), []byte("password"), tc.config); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("expected InitThreePassJpakeWithConfig to return ErrInvalidConfig, instead got: %v", err)
+		}
+		if _, err := InitTwoPassJpakeWithConfig([]byte("one"), []byte("password"), tc.config); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("expected InitTwoPassJpakeWithConfig to return ErrInvalidConfig, instead got: %v", err)
+		}
+	}
+}
+
+func TestJpake3PassBlindedUserIDs(t *testing.T) {
+	// the same device ID on both sides would otherwise collide
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("device"), []byte("password"), NewConfig().SetBlindedUserIDs([]byte("session-salt")))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("device"), []byte("password"), NewConfig().SetBlindedUserIDs([]byte("session-salt")))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	if bytes.Contains(msg1.UserID, []byte("device")) {
+		t.Fatalf("expected the sent user ID to be blinded, got %q", msg1.UserID)
+	}
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	if bytes.Equal(msg1.UserID, msg2.UserID) {
+		t.Fatalf("expected the blinded user IDs to differ")
+	}
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error processing confirmation1: %v", err)
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error processing confirmation2: %v", err)
+	}
+	if !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected equal session keys")
+	}
+
+	other, err := InitThreePassJpakeWithConfig(Initiator, []byte("device"), []byte("password"), NewConfig().SetBlindedUserIDs([]byte("other-salt")))
+	if err != nil {
+		t.Fatalf("error init other: %v", err)
+	}
+	if bytes.Equal(other.userID, jpake1.userID) {
+		t.Fatalf("expected a different salt to give a different blinded user ID")
+	}
+	if _, err := InitTwoPassJpakeWithConfig([]byte("device"), []byte("password"), NewConfig().SetBlindedUserIDs(nil)); err == nil {
+		t.Fatalf("expected the two pass variant to reject blinded user IDs")
+	}
+}
+
+func TestJpake3PassAdditionalSecret(t *testing.T) {
+	handshake := func(secret1, secret2 []byte) (*ThreePassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+		jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake1: %v", err)
+		}
+		jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+		if err != nil {
+			t.Fatalf("error init jpake2: %v", err)
+		}
+		if err := jpake1.SetAdditionalSecret(secret1); err != nil {
+			t.Fatalf("error setting secret1: %v", err)
+		}
+		if err := jpake2.SetAdditionalSecret(secret2); err != nil {
+			t.Fatalf("error setting secret2: %v", err)
+		}
+		msg1, err := jpake1.Pass1Message()
+		if err != nil {
+			t.Fatalf("error getting pass1: %v", err)
+		}
+		msg2, err := jpake2.GetPass2Message(*msg1)
+		if err != nil {
+			t.Fatalf("error getting pass2: %v", err)
+		}
+		msg3, err := jpake1.GetPass3Message(*msg2)
+		if err != nil {
+			t.Fatalf("error getting pass3: %v", err)
+		}
+		conf1, err := jpake2.ProcessPass3Message(*msg3)
+		if err != nil {
+			t.Fatalf("error processing pass3: %v", err)
+		}
+		conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+		if err != nil {
+			return jpake1, err
+		}
+		return jpake1, jpake2.ProcessSessionConfirmation2(conf2)
+	}
+	jpake1, err := handshake([]byte("kem shared secret"), []byte("kem shared secret"))
+	if err != nil {
+		t.Fatalf("expected equal additional secrets to converge, got: %v", err)
+	}
+	// the key is not the one the shared point alone would give
+	if bytes.Equal(jpake1.SessionKey, DeriveSessionKeyFromSharedPoint(jpake1.sharedPoint.Bytes(), jpake1.config)) {
+		t.Fatalf("expected the additional secret to change the session key")
+	}
+	if err := jpake1.SetAdditionalSecret([]byte("late")); !errors.Is(err, ErrStage) {
+		t.Fatalf("expected ErrStage setting a secret after the key is derived, instead got: %v", err)
+	}
+	if _, err := handshake([]byte("kem shared secret"), []byte("other kem secret")); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch for differing additional secrets, instead got: %v", err)
+	}
+	if _, err := handshake([]byte("kem shared secret"), nil); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch when one side has no additional secret, instead got: %v", err)
+	}
+}
+
+func TestEmptyPassword(t *testing.T) {
+	if _, err := InitThreePassJpake(Initiator, []byte("one"), nil); !errors.Is(err, ErrEmptyPassword) {
+		t.Fatalf("expected ErrEmptyPassword, instead got: %v", err)
+	}
+	if _, err := InitThreePassJpakeFromReader(Initiator, []byte("one"), bytes.NewReader(nil), NewConfig()); !errors.Is(err, ErrEmptyPassword) {
+		t.Fatalf("expected ErrEmptyPassword from a reader, instead got: %v", err)
+	}
+	if _, err := InitTwoPassJpake([]byte("one"), []byte{}); !errors.Is(err, ErrEmptyPassword) {
+		t.Fatalf("expected ErrEmptyPassword for the two pass variant, instead got: %v", err)
+	}
+
+	config := NewConfig().SetAllowEmptyPassword(true)
+	jpake1, err := InitThreePassJpakeWithConfig(Initiator, []byte("one"), nil, config)
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte{}, config)
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	runPAKE(t, jpake1, jpake2)
+}
+
+func TestVerifyPass1Proofs(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	curve := Curve25519Curve{}
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](*msg1, curve, NewConfig()); err != nil {
+		t.Fatalf("expected pass1 proofs to verify, got: %v", err)
+	}
+
+	tampered := *msg1
+	tampered.X1ZKP = msg1.X2ZKP
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](tampered, curve, NewConfig()); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification for swapped proofs, instead got: %v", err)
+	}
+	tampered = *msg1
+	tampered.UserID = []byte("mallory")
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](tampered, curve, NewConfig()); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification for a changed user ID, instead got: %v", err)
+	}
+	tampered = *msg1
+	tampered.X2ZKP.R, _ = curve.NewScalar().Add(msg1.X2ZKP.R, msg1.X1ZKP.R)
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](tampered, curve, NewConfig()); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification for a tampered R, instead got: %v", err)
+	}
+	tampered = *msg1
+	tampered.X1G = nil
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](tampered, curve, NewConfig()); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("expected ErrMalformedMessage for a missing point, instead got: %v", err)
+	}
+	if err := VerifyPass1Proofs[*Curve25519Point, *Curve25519Scalar](*msg1, curve, NewConfig().SetSessionID([]byte("other"))); !errors.Is(err, ErrZKPVerification) {
+		t.Fatalf("expected ErrZKPVerification under a different session ID, instead got: %v", err)
+	}
+}
+
+func TestJpake3PassDone(t *testing.T) {
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpake(Responder, []byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	notDone := func(step string) {
+		t.Helper()
+		if jpake1.Done() || jpake2.Done() {
+			t.Fatalf("expected neither side to be done after %s, got %t and %t", step, jpake1.Done(), jpake2.Done())
+		}
+	}
+	notDone("init")
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	notDone("pass 1")
+	msg2, err := jpake2.GetPass2Message(*msg1)
+	if err != nil {
+		t.Fatalf("error getting pass2: %v", err)
+	}
+	notDone("pass 2")
+	msg3, err := jpake1.GetPass3Message(*msg2)
+	if err != nil {
+		t.Fatalf("error getting pass3: %v", err)
+	}
+	notDone("pass 3")
+	conf1, err := jpake2.ProcessPass3Message(*msg3)
+	if err != nil {
+		t.Fatalf("error processing pass3: %v", err)
+	}
+	notDone("processing pass 3")
+	if _, err := jpake1.ProcessSessionConfirmation1(append([]byte{conf1[0] ^ 1}, conf1[1:]...)); err == nil {
+		t.Fatalf("expected a corrupted confirmation to fail")
+	}
+	notDone("a failed confirmation")
+	conf2, err := jpake1.ProcessSessionConfirmation1(conf1)
+	if err != nil {
+		t.Fatalf("error getting conf1: %v", err)
+	}
+	if !jpake1.Done() || jpake2.Done() {
+		t.Fatalf("expected only the initiator to be done, got %t and %t", jpake1.Done(), jpake2.Done())
+	}
+	if err := jpake2.ProcessSessionConfirmation2(conf2); err != nil {
+		t.Fatalf("error getting conf2: %v", err)
+	}
+	if !jpake1.Done() || !jpake2.Done() {
+		t.Fatalf("expected both sides to be done")
+	}
+	jpake1.Destroy()
+	if jpake1.Done() {
+		t.Fatalf("expected a destroyed exchange not to be done")
+	}
+}
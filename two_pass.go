@@ -0,0 +1,273 @@
+package jpake
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+type TwoPassVariant1[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	// Version is the sender's ProtocolVersion.
+	Version uint8
+	UserID  []byte
+	X1G     P
+	X2G     P
+	X1ZKP   ZKPMsg[P, S]
+	X2ZKP   ZKPMsg[P, S]
+}
+
+type TwoPassVariant2[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	A     P
+	XsZKP ZKPMsg[P, S]
+}
+
+func (msg *TwoPassVariant1[P, S]) validate() error {
+	if isNil(msg.X1G) {
+		return fmt.Errorf("%w: missing X1G", ErrMalformedMessage)
+	}
+	if isNil(msg.X2G) {
+		return fmt.Errorf("%w: missing X2G", ErrMalformedMessage)
+	}
+	if err := validateZKP("X1ZKP", msg.X1ZKP); err != nil {
+		return err
+	}
+	return validateZKP("X2ZKP", msg.X2ZKP)
+}
+
+func (msg *TwoPassVariant2[P, S]) validate() error {
+	if isNil(msg.A) {
+		return fmt.Errorf("%w: missing A", ErrMalformedMessage)
+	}
+	return validateZKP("XsZKP", msg.XsZKP)
+}
+
+// Two pass variant jpake https://tools.ietf.org/html/rfc8236#section-3
+// Both parties run the same steps: each sends its pass 1 message, answers the
+// peer's pass 1 with its pass 2 message and derives the key from the peer's
+// pass 2. Neither message depends on the other party having spoken first, so
+// they may be exchanged simultaneously, for example through a relay.
+//
+// The two pass variant does not include key confirmation.
+type TwoPassJpake[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	// Variables which can be shared
+	x1G    P
+	x2G    P
+	userID []byte
+
+	// Received Variables
+	OtherX1G    P
+	OtherX2G    P
+	OtherUserID []byte
+
+	// Calculated values
+	x2s        S
+	SessionKey []byte
+
+	// Private Variables
+	X1 S
+	X2 S
+	S  S
+
+	// configuration
+	Stage  TwoPassStage
+	config *Config
+	curve  Curve[P, S]
+}
+
+func InitTwoPassJpake(userID, pw []byte) (*TwoPassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return InitTwoPassJpakeWithConfig(userID, pw, NewConfig())
+}
+
+func InitTwoPassJpakeWithConfig(userID, pw []byte, config *Config) (*TwoPassJpake[*Curve25519Point, *Curve25519Scalar], error) {
+	return InitTwoPassJpakeWithConfigAndCurve[*Curve25519Point, *Curve25519Scalar](userID, pw, Curve25519Curve{}, config)
+}
+
+func InitTwoPassJpakeWithConfigAndCurve[P CurvePoint[P, S], S CurveScalar[S]](userID, pw []byte, curve Curve[P, S], config *Config) (*TwoPassJpake[P, S], error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.checkPassword(pw); err != nil {
+		return nil, err
+	}
+	if config.requireKeyConfirmation {
+		return nil, errors.New("the two pass variant does not support key confirmation")
+	}
+	if config.blindUserIDs {
+		return nil, errors.New("the two pass variant has no roles to blind user ids by")
+	}
+	jp := new(TwoPassJpake[P, S])
+	jp.SessionKey = []byte{} // make sure to invalidate the session key
+	jp.userID = userID
+	jp.config = config
+	jp.curve = curve
+	var err error
+	if jp.X1, err = curve.NewRandomScalar(config.rand, 1); err != nil {
+		return nil, err
+	}
+	if jp.X2, err = curve.NewRandomScalar(config.rand, 1); err != nil {
+		return nil, err
+	}
+	jp.S, err = curve.NewScalarFromSecret(1, config.generateSecret(pw)) // The value of s falls within [1, n-1].
+	if err != nil {
+		return nil, err
+	}
+	if jp.x1G, err = curve.NewPoint().ScalarBaseMult(jp.X1); err != nil {
+		return nil, err
+	}
+	if jp.x2G, err = curve.NewPoint().ScalarBaseMult(jp.X2); err != nil {
+		return nil, err
+	}
+	if jp.x2s, err = curve.NewScalar().Multiply(jp.X2, jp.S); err != nil {
+		return nil, err
+	}
+	jp.Stage = TwoPassStageInit
+	return jp, nil
+}
+
+// checkStage returns an ErrStage error naming the method which should be
+// called instead if the exchange is not at stage.
+func (jp *TwoPassJpake[P, S]) checkStage(method string, stage TwoPassStage) error {
+	if jp.Stage == stage {
+		return nil
+	}
+	if next := jp.Stage.nextCall(); next != "" {
+		return fmt.Errorf("%s called at stage %s (%s expected): %w", method, jp.Stage, next, ErrStage)
+	}
+	return fmt.Errorf("%s called at stage %s (no further calls expected): %w", method, jp.Stage, ErrStage)
+}
+
+// CurrentStage returns the stage the exchange is at.
+func (jp *TwoPassJpake[P, S]) CurrentStage() TwoPassStage {
+	return jp.Stage
+}
+
+func (jp *TwoPassJpake[P, S]) Variant() Variant {
+	return VariantTwoPass
+}
+
+func (jp *TwoPassJpake[P, S]) Pass1Message() (*TwoPassVariant1[P, S], error) {
+	if err := jp.checkStage("Pass1Message", TwoPassStageInit); err != nil {
+		return nil, err
+	}
+	x1ZKP, err := computeZKP(jp.curve, jp.config, jp.userID, jp.X1, jp.curve.NewGeneratorPoint(), jp.x1G)
+	if err != nil {
+		return nil, err
+	}
+	x2ZKP, err := computeZKP(jp.curve, jp.config, jp.userID, jp.X2, jp.curve.NewGeneratorPoint(), jp.x2G)
+	if err != nil {
+		return nil, err
+	}
+
+	jp.Stage = TwoPassStageAwaitingPass1
+	return &TwoPassVariant1[P, S]{
+		Version: ProtocolVersion,
+		UserID:  jp.userID,
+		X1G:     jp.x1G,
+		X2G:     jp.x2G,
+		X1ZKP:   x1ZKP,
+		X2ZKP:   x2ZKP,
+	}, nil
+}
+
+// GetPass2Message verifies the peer's pass 1 message and returns this side's
+// pass 2 message.
+func (jp *TwoPassJpake[P, S]) GetPass2Message(msg TwoPassVariant1[P, S]) (*TwoPassVariant2[P, S], error) {
+	if err := jp.checkStage("GetPass2Message", TwoPassStageAwaitingPass1); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(msg.Version); err != nil {
+		return nil, err
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	if err := jp.config.checkPeerUserID(msg.UserID); err != nil {
+		return nil, rejected(err)
+	}
+	if subtle.ConstantTimeCompare(msg.UserID, jp.userID) == 1 {
+		return nil, rejected(ErrUserIDCollision)
+	}
+	if smallOrder(jp.curve, msg.X1G, msg.X2G) {
+		return nil, rejected(ErrSmallOrderPoint)
+	}
+
+	// validate ZKPs
+	if err := checkZKPs(jp.curve, jp.config, msg.UserID,
+		zkpStatement[P, S]{"X1ZKP", msg.X1ZKP, jp.curve.NewGeneratorPoint(), msg.X1G},
+		zkpStatement[P, S]{"X2ZKP", msg.X2ZKP, jp.curve.NewGeneratorPoint(), msg.X2G},
+	); err != nil {
+		return nil, rejected(err)
+	}
+
+	// A = (G1 + G3 + G4) x [x2*s]
+	generator := ComputePass2ZKPGenerator(jp.curve, jp.x1G, msg.X1G, msg.X2G)
+	if err := checkGenerator(jp.curve, generator); err != nil {
+		return nil, err
+	}
+	a, err := jp.curve.NewPoint().ScalarMult(generator, jp.x2s)
+	if err != nil {
+		return nil, err
+	}
+	xsZKP, err := computeZKP(jp.curve, jp.config, jp.userID, jp.x2s, generator, a)
+	if err != nil {
+		return nil, err
+	}
+
+	jp.OtherUserID = msg.UserID
+	jp.OtherX1G = msg.X1G
+	jp.OtherX2G = msg.X2G
+	jp.Stage = TwoPassStageAwaitingPass2
+	return &TwoPassVariant2[P, S]{
+		A:     a,
+		XsZKP: xsZKP,
+	}, nil
+}
+
+// ProcessPass2Message verifies the peer's pass 2 message and derives the
+// session key.
+func (jp *TwoPassJpake[P, S]) ProcessPass2Message(msg TwoPassVariant2[P, S]) error {
+	if err := jp.checkStage("ProcessPass2Message", TwoPassStageAwaitingPass2); err != nil {
+		return err
+	}
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if smallOrder(jp.curve, msg.A) {
+		return rejected(ErrSmallOrderPoint)
+	}
+	// the peer's generator is (G3 + G1 + G2)
+	zkpGenerator := ComputePass2ZKPGenerator(jp.curve, jp.OtherX1G, jp.x1G, jp.x2G)
+	if err := checkGenerator(jp.curve, zkpGenerator); err != nil {
+		return err
+	}
+	if err := checkZKP(jp.curve, jp.config, jp.OtherUserID, "XsZKP", msg.XsZKP, zkpGenerator, msg.A); err != nil {
+		return rejected(err)
+	}
+	sessionKey, err := computeSharedKey(jp.curve, jp.config, msg.A, jp.OtherX2G, jp.x2s, jp.X2)
+	if err != nil {
+		return err
+	}
+	jp.SessionKey = sessionKey
+	jp.Stage = TwoPassStageDone
+	if jp.config.autoZeroizeEphemerals {
+		zeroize(jp.X1, jp.X2, jp.S, jp.x2s)
+	}
+	return nil
+}
+
+// Destroy overwrites the private scalars and the session key with zeros. The
+// exchange cannot be used afterwards; every method returns an error.
+func (jp *TwoPassJpake[P, S]) Destroy() {
+	zeroize(jp.X1, jp.X2, jp.S, jp.x2s)
+	zeroizeBytes(jp.SessionKey)
+	jp.SessionKey = nil
+	jp.Stage = TwoPassStageDestroyed
+}
+
+// Key returns a copy of the derived session key.
+func (jp *TwoPassJpake[P, S]) Key() ([]byte, error) {
+	if err := jp.checkStage("Key", TwoPassStageDone); err != nil {
+		return nil, err
+	}
+	return append([]byte{}, jp.SessionKey...), nil
+}
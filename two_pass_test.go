@@ -0,0 +1,131 @@
+package jpake
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestJpake2Pass(t *testing.T) {
+	jpake1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitTwoPassJpake([]byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	// both sides publish their first message before seeing the other's
+	msg1a, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake1: %v", err)
+	}
+	msg1b, err := jpake2.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake2: %v", err)
+	}
+	msg2a, err := jpake1.GetPass2Message(*msg1b)
+	if err != nil {
+		t.Fatalf("error getting pass2 for jpake1: %v", err)
+	}
+	msg2b, err := jpake2.GetPass2Message(*msg1a)
+	if err != nil {
+		t.Fatalf("error getting pass2 for jpake2: %v", err)
+	}
+	if err := jpake1.ProcessPass2Message(*msg2b); err != nil {
+		t.Fatalf("error processing pass2 for jpake1: %v", err)
+	}
+	if err := jpake2.ProcessPass2Message(*msg2a); err != nil {
+		t.Fatalf("error processing pass2 for jpake2: %v", err)
+	}
+	if len(jpake1.SessionKey) == 0 || !bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session key %x to be equal to %x", jpake1.SessionKey, jpake2.SessionKey)
+	}
+	if jpake1.Variant() != VariantTwoPass {
+		t.Fatalf("expected two pass variant, was %d", jpake1.Variant())
+	}
+}
+
+func TestJpake2PassDifferentPasswords(t *testing.T) {
+	jpake1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitTwoPassJpake([]byte("two"), []byte("wrong"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1a, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake1: %v", err)
+	}
+	msg1b, err := jpake2.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake2: %v", err)
+	}
+	msg2a, err := jpake1.GetPass2Message(*msg1b)
+	if err != nil {
+		t.Fatalf("error getting pass2 for jpake1: %v", err)
+	}
+	msg2b, err := jpake2.GetPass2Message(*msg1a)
+	if err != nil {
+		t.Fatalf("error getting pass2 for jpake2: %v", err)
+	}
+	if err := jpake1.ProcessPass2Message(*msg2b); err != nil {
+		t.Fatalf("error processing pass2 for jpake1: %v", err)
+	}
+	if err := jpake2.ProcessPass2Message(*msg2a); err != nil {
+		t.Fatalf("error processing pass2 for jpake2: %v", err)
+	}
+	if bytes.Equal(jpake1.SessionKey, jpake2.SessionKey) {
+		t.Fatalf("expected session keys to differ with mismatched passwords")
+	}
+}
+
+func TestJpake2PassSameUserIDs(t *testing.T) {
+	jpake1, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitTwoPassJpake([]byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1a, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake1: %v", err)
+	}
+	if _, err := jpake2.Pass1Message(); err != nil {
+		t.Fatalf("error getting pass1 for jpake2: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1a); err == nil {
+		t.Fatalf("expected error with same user ids, instead got nil")
+	}
+}
+
+func TestJpake2PassRequireKeyConfirmation(t *testing.T) {
+	if _, err := InitTwoPassJpakeWithConfig([]byte("one"), []byte("password"), NewConfig().SetRequireKeyConfirmation(true)); err == nil {
+		t.Fatalf("expected error requiring key confirmation, instead got nil")
+	}
+}
+
+func TestJpake2PassEmptyUserID(t *testing.T) {
+	jpake1, err := InitTwoPassJpake(nil, []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitTwoPassJpake([]byte("two"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1a, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1 for jpake1: %v", err)
+	}
+	if _, err := jpake2.Pass1Message(); err != nil {
+		t.Fatalf("error getting pass1 for jpake2: %v", err)
+	}
+	if _, err := jpake2.GetPass2Message(*msg1a); !errors.Is(err, ErrUserIDLength) {
+		t.Fatalf("expected ErrUserIDLength for an empty user id, instead got: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+func computeZKP[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, userID []byte, x S, generator P, y P) (ZKPMsg[P, S], error) {
+	// Computes a ZKP for x on Generator. We use the Fiat-Shamir heuristic:
+	// https://en.wikipedia.org/wiki/Fiat%E2%80%93Shamir_heuristic
+	// i.e. prove that we know x such that y = x.Generator
+	// Note that we differentiate between the point G on the curve, and the
+	// Generator used to compute the ZKP
+
+	// 1. Pick a random v \in Z_q* and compute t = vG
+	v, err := curve.NewRandomScalar(config.rand, 1)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+
+	t, err := scalarMultGenerator(curve, generator, v)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+
+	// 2. Compute c = H(g, y, t) where H() is a cryptographic hash fn
+	//    Within the hash function, there must be a clear boundary between any two concatenated items.  It is RECOMMENDED that one should always prepend each item with a 4-byte integer that represents the byte length of that item.  OtherInfo may contain multiple subitems.  In that case, the same rule shall apply to ensure a clear boundary between adjacent subitems.
+
+	chal, err := config.zkpChallenge(generator.Bytes(), t.Bytes(), y.Bytes(), userID)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	c := new(big.Int).SetBytes(chal)
+	cS, err := curve.NewScalar().SetBigInt(c.Mod(c, curve.Params().N))
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+
+	// 3. Compute r = v - c.x mod N in the curve's scalar arithmetic. Neither
+	//    c nor v is used afterwards, so they are overwritten in place.
+	if _, err := cS.Multiply(cS, x); err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	r, err := v.Subtract(v, cS)
+	if err != nil {
+		return ZKPMsg[P, S]{}, err
+	}
+	return ZKPMsg[P, S]{
+		T: t,
+		R: r,
+	}, nil
+}
+
+// checkZKP verifies one proof, returning an error which names it and matches
+// both ErrZKPVerification and the specific reason it failed. The reason is
+// also given to an Observer implementing ZKPFailureObserver.
+func checkZKP[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, otherUserID []byte, name string, msgObj ZKPMsg[P, S], generator, y P) error {
+	c, err := verifyZKP(curve, config, otherUserID, msgObj, generator, y)
+	ok := err == nil
+	if config.zkpVerificationObserver != nil {
+		var cBytes []byte
+		if c != nil {
+			cBytes = c.Bytes()
+		}
+		config.zkpVerificationObserver(name, msgObj.T.Bytes(), cBytes, ok)
+	}
+	if config.observer != nil {
+		config.observer.OnZKPVerify(name, ok)
+		if o, isFailureObserver := config.observer.(ZKPFailureObserver); isFailureObserver && !ok {
+			o.OnZKPFailure(name, err)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// verifyZKP returns the derived challenge (nil if verification stopped before
+// it was computed) along with the reason verification failed, if it did.
+func verifyZKP[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, otherUserID []byte, msgObj ZKPMsg[P, S], generator, y P) (*big.Int, error) {
+	c, err := zkpChallenge(curve, config, otherUserID, msgObj, generator, y)
+	if err != nil {
+		return c, err
+	}
+	vcheck, err := scalarMultGenerator(curve, generator, msgObj.R)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrZKPVerification, err)
+	}
+	cS, err := curve.NewScalar().SetBigInt(c)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrZKPVerification, err)
+	}
+	tmp2, err := curve.NewPoint().ScalarMult(y, cS)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrZKPVerification, err)
+	}
+	vcheck = vcheck.Add(vcheck, tmp2)
+	if vcheck.Equal(msgObj.T) != 1 {
+		return c, ErrZKPEquationMismatch
+	}
+	return c, nil
+}
+
+// zkpChallenge runs the checks which do not need the verification equation
+// and derives the challenge c.
+func zkpChallenge[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, otherUserID []byte, msgObj ZKPMsg[P, S], generator, y P) (*big.Int, error) {
+	if curve.Infinity(generator) {
+		return nil, ErrZKPInfinityGenerator
+	}
+	if curve.Infinity(y) {
+		return nil, ErrZKPInfinityY
+	}
+	// validate T is not infinity
+	if curve.Infinity(msgObj.T) {
+		return nil, ErrZKPInfinityT
+	}
+	if smallOrder(curve, generator, y, msgObj.T) {
+		return nil, ErrZKPSmallOrder
+	}
+	// validate R is not zero
+	if msgObj.R.Zero() {
+		return nil, ErrZKPZeroR
+	}
+
+	chal, err := config.zkpChallenge(generator.Bytes(), msgObj.T.Bytes(), y.Bytes(), otherUserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrZKPVerification, err)
+	}
+	c := new(big.Int).SetBytes(chal)
+	c = c.Mod(c, curve.Params().N)
+
+	// if c is zero
+	if c.BitLen() == 0 {
+		return c, ErrZKPZeroChallenge
+	}
+	return c, nil
+}
+
+// zkpStatement is a received proof together with what it proves, y = x.Generator.
+type zkpStatement[P CurvePoint[P, S], S CurveScalar[S]] struct {
+	name      string
+	msg       ZKPMsg[P, S]
+	generator P
+	y         P
+}
+
+// multiScalarMultiplier is implemented by curves which can compute a sum of
+// scalar multiples in one pass. Only prime order groups should implement it:
+// on a curve with a cofactor, torsion components of separate proofs could
+// cancel in the weighted sum checked by checkZKPs, accepting a batch whose
+// proofs do not all verify on their own.
+type multiScalarMultiplier[P, S any] interface {
+	VarTimeMultiScalarMult(scalars []S, points []P) P
+}
+
+// zkpBatchWeightBits is the size of the random weights used in batch
+// verification; an invalid proof passes with probability 2^-128.
+const zkpBatchWeightBits = 128
+
+// checkZKPs verifies every statement, batching the verification equations
+// into one multi-scalar multiplication where the curve supports it. The
+// proofs are checked one at a time when a verification observer is set, so
+// that it sees the result of each, and when the batch fails, so that the
+// first failing proof is reported as by checkZKP.
+func checkZKPs[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], config *Config, otherUserID []byte, statements ...zkpStatement[P, S]) error {
+	if len(statements) > 1 && config.zkpVerificationObserver == nil {
+		if msm, ok := curve.(multiScalarMultiplier[P, S]); ok && batchVerifyZKPs(curve, msm, config, otherUserID, statements) {
+			if config.observer != nil {
+				for _, st := range statements {
+					config.observer.OnZKPVerify(st.name, true)
+				}
+			}
+			return nil
+		}
+	}
+	var failed error
+	for _, st := range statements {
+		if err := checkZKP(curve, config, otherUserID, st.name, st.msg, st.generator, st.y); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// batchVerifyZKPs checks sum(z_i * (r_i.G_i + c_i.y_i - T_i)) == 0 for random
+// weights z_i, with z_0 = 1. Terms sharing a generator are merged.
+func batchVerifyZKPs[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], msm multiScalarMultiplier[P, S], config *Config, otherUserID []byte, statements []zkpStatement[P, S]) bool {
+	n := curve.Params().N
+	var points []P
+	var weights []*big.Int
+	addTerm := func(p P, w *big.Int) {
+		for i, q := range points {
+			if q.Equal(p) == 1 {
+				weights[i].Add(weights[i], w)
+				return
+			}
+		}
+		points = append(points, p)
+		weights = append(weights, w)
+	}
+	bound := new(big.Int).Lsh(big.NewInt(1), zkpBatchWeightBits)
+	bound.Sub(bound, big.NewInt(1))
+	for i, st := range statements {
+		c, err := zkpChallenge(curve, config, otherUserID, st.msg, st.generator, st.y)
+		if err != nil {
+			return false
+		}
+		z := big.NewInt(1)
+		if i > 0 {
+			var err error
+			if z, err = crypto_rand.Int(config.rand, bound); err != nil {
+				return false
+			}
+			z.Add(z, big.NewInt(1))
+		}
+		addTerm(st.generator, new(big.Int).Mul(z, st.msg.R.BigInt()))
+		addTerm(st.y, new(big.Int).Mul(z, c))
+		addTerm(st.msg.T, new(big.Int).Neg(z))
+	}
+	scalars := make([]S, len(weights))
+	for i, w := range weights {
+		s, err := curve.NewScalar().SetBigInt(w.Mod(w, n))
+		if err != nil {
+			return false
+		}
+		scalars[i] = s
+	}
+	return curve.Infinity(msm.VarTimeMultiScalarMult(scalars, points))
+}
+
+// scalarMultGenerator returns s.generator, taking the curve's ScalarBaseMult
+// path when generator is the base point. Every backend serves ScalarBaseMult
+// from precomputed fixed base tables, which are several times faster than a
+// variable base multiplication; a custom Curve may do the same. The base
+// point allocated for the comparison is reused to hold the result.
+func scalarMultGenerator[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], generator P, s S) (P, error) {
+	result := curve.NewGeneratorPoint()
+	if generator.Equal(result) == 1 {
+		return result.ScalarBaseMult(s)
+	}
+	return result.ScalarMult(generator, s)
+}
+
+// checkGenerator rejects a ZKP generator combined from received points which
+// is the identity or of small order, as a proof against either would show
+// nothing about the secret exponent.
+func checkGenerator[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], generator P) error {
+	if curve.Infinity(generator) {
+		return rejected(ErrPointAtInfinity)
+	}
+	if smallOrder(curve, generator) {
+		return rejected(ErrSmallOrderPoint)
+	}
+	return nil
+}
+
+// smallOrder reports whether any of points is of small order, for curves with
+// a cofactor which provide an IsSmallOrder method.
+func smallOrder[P CurvePoint[P, S], S CurveScalar[S]](curve Curve[P, S], points ...P) bool {
+	c, ok := curve.(interface{ IsSmallOrder(P) bool })
+	if !ok {
+		return false
+	}
+	for _, p := range points {
+		if c.IsSmallOrder(p) {
+			return true
+		}
+	}
+	return false
+}
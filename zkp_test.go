@@ -0,0 +1,236 @@
+package jpake
+
+import (
+	crypto_rand "crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func ristretto255Statements(tb testing.TB, config *Config, userID []byte) []zkpStatement[*Ristretto255Point, *Ristretto255Scalar] {
+	curve := Ristretto255Curve{}
+	var generator *Ristretto255Point
+	var statements []zkpStatement[*Ristretto255Point, *Ristretto255Scalar]
+	for _, name := range []string{"X3ZKP", "X4ZKP", "XsZKP"} {
+		x, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+		if err != nil {
+			tb.Fatalf("error generating scalar: %v", err)
+		}
+		g := curve.NewGeneratorPoint()
+		if generator != nil {
+			g = generator
+		}
+		y, err := curve.NewPoint().ScalarMult(g, x)
+		if err != nil {
+			tb.Fatalf("error computing y: %v", err)
+		}
+		msg, err := computeZKP[*Ristretto255Point, *Ristretto255Scalar](curve, config, userID, x, g, y)
+		if err != nil {
+			tb.Fatalf("error computing zkp: %v", err)
+		}
+		statements = append(statements, zkpStatement[*Ristretto255Point, *Ristretto255Scalar]{name, msg, g, y})
+		// the last proof uses a generator other than the base point, as XsZKP does
+		generator = curve.NewPoint().Add(curve.NewGeneratorPoint(), y)
+	}
+	return statements
+}
+
+func TestBatchVerifyZKPs(t *testing.T) {
+	curve := Ristretto255Curve{}
+	config := NewConfig()
+	statements := ristretto255Statements(t, config, []byte("one"))
+	if !batchVerifyZKPs[*Ristretto255Point, *Ristretto255Scalar](curve, curve, config, []byte("one"), statements) {
+		t.Fatalf("expected valid batch to verify")
+	}
+	if batchVerifyZKPs[*Ristretto255Point, *Ristretto255Scalar](curve, curve, config, []byte("two"), statements) {
+		t.Fatalf("expected batch for another user id to fail")
+	}
+	for i := range statements {
+		tampered := append([]zkpStatement[*Ristretto255Point, *Ristretto255Scalar]{}, statements...)
+		r := tampered[i].msg.R.BigInt()
+		r.Add(r, r)
+		r.Mod(r, curve.Params().N)
+		badR, err := curve.NewScalar().SetBigInt(r)
+		if err != nil {
+			t.Fatalf("error setting r: %v", err)
+		}
+		tampered[i].msg = ZKPMsg[*Ristretto255Point, *Ristretto255Scalar]{T: tampered[i].msg.T, R: badR}
+		if batchVerifyZKPs[*Ristretto255Point, *Ristretto255Scalar](curve, curve, config, []byte("one"), tampered) {
+			t.Fatalf("expected batch with bad proof %d to fail", i)
+		}
+		if err := checkZKPs[*Ristretto255Point, *Ristretto255Scalar](curve, config, []byte("one"), tampered...); !errors.Is(err, ErrZKPEquationMismatch) || !strings.HasPrefix(err.Error(), tampered[i].name+": ") {
+			t.Fatalf("expected checkZKPs with bad proof %d to fail naming it, instead got: %v", i, err)
+		}
+	}
+}
+
+func TestCurve25519DoesNotBatch(t *testing.T) {
+	// torsion components could cancel in a weighted sum on a curve with a
+	// cofactor
+	if _, ok := Curve[*Curve25519Point, *Curve25519Scalar](Curve25519Curve{}).(multiScalarMultiplier[*Curve25519Point, *Curve25519Scalar]); ok {
+		t.Fatalf("expected Curve25519Curve to verify proofs individually")
+	}
+}
+
+func BenchmarkVerifyZKPsSequential(b *testing.B) {
+	curve := Ristretto255Curve{}
+	config := NewConfig()
+	statements := ristretto255Statements(b, config, []byte("one"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, st := range statements {
+			if err := checkZKP[*Ristretto255Point, *Ristretto255Scalar](curve, config, []byte("one"), st.name, st.msg, st.generator, st.y); err != nil {
+				b.Fatalf("expected proof to verify")
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyZKPsBatched(b *testing.B) {
+	curve := Ristretto255Curve{}
+	config := NewConfig()
+	statements := ristretto255Statements(b, config, []byte("one"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := checkZKPs[*Ristretto255Point, *Ristretto255Scalar](curve, config, []byte("one"), statements...); err != nil {
+			b.Fatalf("expected proofs to verify")
+		}
+	}
+}
+
+func BenchmarkComputeZKP(b *testing.B) {
+	curve := Curve25519Curve{}
+	config := NewConfig()
+	x, err := curve.NewRandomScalar(config.rand, 1)
+	if err != nil {
+		b.Fatalf("error generating scalar: %v", err)
+	}
+	g := curve.NewGeneratorPoint()
+	y, err := curve.NewPoint().ScalarBaseMult(x)
+	if err != nil {
+		b.Fatalf("error multiplying generator: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := computeZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), x, g, y); err != nil {
+			b.Fatalf("error computing zkp: %v", err)
+		}
+	}
+}
+
+func BenchmarkCheckZKP(b *testing.B) {
+	curve := Curve25519Curve{}
+	config := NewConfig()
+	x, err := curve.NewRandomScalar(config.rand, 1)
+	if err != nil {
+		b.Fatalf("error generating scalar: %v", err)
+	}
+	g := curve.NewGeneratorPoint()
+	y, err := curve.NewPoint().ScalarBaseMult(x)
+	if err != nil {
+		b.Fatalf("error multiplying generator: %v", err)
+	}
+	zkp, err := computeZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), x, g, y)
+	if err != nil {
+		b.Fatalf("error computing zkp: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := checkZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), "zkp", zkp, g, y); err != nil {
+			b.Fatalf("expected proof to verify")
+		}
+	}
+}
+
+type zkpFailureObserver struct {
+	recordingObserver
+	failures map[string]error
+}
+
+func (o *zkpFailureObserver) OnZKPFailure(name string, err error) {
+	o.failures[name] = err
+}
+
+func TestZKPFailureReasons(t *testing.T) {
+	curve := Curve25519Curve{}
+	config := NewConfig()
+	g := curve.NewGeneratorPoint()
+	x, err := curve.NewRandomScalar(crypto_rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("error generating scalar: %v", err)
+	}
+	y, err := curve.NewPoint().ScalarBaseMult(x)
+	if err != nil {
+		t.Fatalf("error computing y: %v", err)
+	}
+	zkp, err := computeZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), x, g, y)
+	if err != nil {
+		t.Fatalf("error computing zkp: %v", err)
+	}
+	// an edwards25519 point of order 8
+	smallOrder, err := new(Curve25519Point).SetBytes([]byte{
+		0x26, 0xe8, 0x95, 0x8f, 0xc2, 0xb2, 0x27, 0xb0, 0x45, 0xc3, 0xf4, 0x89, 0xf2, 0xef, 0x98, 0xf0,
+		0xd5, 0xdf, 0xac, 0x05, 0xd3, 0xc6, 0x33, 0x39, 0xb1, 0x38, 0x02, 0x88, 0x6d, 0x53, 0xfc, 0x05,
+	})
+	if err != nil {
+		t.Fatalf("error decoding small order point: %v", err)
+	}
+	otherR, err := curve.NewScalar().Add(zkp.R, x)
+	if err != nil {
+		t.Fatalf("error adding: %v", err)
+	}
+	identity := curve.NewPoint()
+	for _, tc := range []struct {
+		expected     error
+		msg          ZKPMsg[*Curve25519Point, *Curve25519Scalar]
+		generator, y *Curve25519Point
+	}{
+		{ErrZKPInfinityGenerator, zkp, identity, y},
+		{ErrZKPInfinityY, zkp, g, identity},
+		{ErrZKPInfinityT, ZKPMsg[*Curve25519Point, *Curve25519Scalar]{T: identity, R: zkp.R}, g, y},
+		{ErrZKPSmallOrder, ZKPMsg[*Curve25519Point, *Curve25519Scalar]{T: smallOrder, R: zkp.R}, g, y},
+		{ErrZKPZeroR, ZKPMsg[*Curve25519Point, *Curve25519Scalar]{T: zkp.T, R: curve.NewScalar()}, g, y},
+		{ErrZKPEquationMismatch, ZKPMsg[*Curve25519Point, *Curve25519Scalar]{T: zkp.T, R: otherR}, g, y},
+	} {
+		observer := &zkpFailureObserver{failures: map[string]error{}}
+		config := NewConfig().SetObserver(observer)
+		err := checkZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), "X1ZKP", tc.msg, tc.generator, tc.y)
+		if !errors.Is(err, tc.expected) || !errors.Is(err, ErrZKPVerification) || !strings.HasPrefix(err.Error(), "X1ZKP: ") {
+			t.Fatalf("expected %v naming X1ZKP, instead got: %v", tc.expected, err)
+		}
+		if !errors.Is(observer.failures["X1ZKP"], tc.expected) {
+			t.Fatalf("expected the observer to be told %v, instead got: %v", tc.expected, observer.failures["X1ZKP"])
+		}
+	}
+	if err := checkZKP[*Curve25519Point, *Curve25519Scalar](curve, config, []byte("one"), "X1ZKP", zkp, g, y); err != nil {
+		t.Fatalf("expected the proof to verify, got: %v", err)
+	}
+}
+
+func TestJpake3PassZKPFailureReason(t *testing.T) {
+	observer := &zkpFailureObserver{failures: map[string]error{}}
+	jpake1, err := InitThreePassJpake(Initiator, []byte("one"), []byte("password"))
+	if err != nil {
+		t.Fatalf("error init jpake1: %v", err)
+	}
+	jpake2, err := InitThreePassJpakeWithConfig(Responder, []byte("two"), []byte("password"), NewConfig().SetObserver(observer))
+	if err != nil {
+		t.Fatalf("error init jpake2: %v", err)
+	}
+	msg1, err := jpake1.Pass1Message()
+	if err != nil {
+		t.Fatalf("error getting pass1: %v", err)
+	}
+	tampered := *msg1
+	tampered.X2ZKP.R = tampered.X1ZKP.R
+	_, err = jpake2.GetPass2Message(tampered)
+	// the caller can tell why, but the text reveals nothing
+	if !errors.Is(err, ErrZKPEquationMismatch) || err.Error() != "could not verify the validity of the received message" {
+		t.Fatalf("expected a generic ErrZKPEquationMismatch, instead got: %v", err)
+	}
+	if _, ok := observer.failures["X1ZKP"]; ok || !errors.Is(observer.failures["X2ZKP"], ErrZKPEquationMismatch) {
+		t.Fatalf("expected only X2ZKP to be reported failing, got %v", observer.failures)
+	}
+}